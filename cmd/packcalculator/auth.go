@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	"rpg/internal/packcalculator/auth"
+	"rpg/internal/packcalculator/config"
+)
+
+// newAuthenticator loads the API keys and the bearer token keys which are configured.
+func newAuthenticator(ctx context.Context, cfg config.Config, logger *slog.Logger) (auth.Authenticator, error) {
+	var authenticator auth.Authenticator
+	if cfg.Auth.APIKeysFile != "" {
+		clients, err := auth.LoadKeys(cfg.Auth.APIKeysFile)
+		if err != nil {
+			return auth.Authenticator{}, err
+		}
+		if authenticator.Keys, err = auth.NewKeyStore(clients); err != nil {
+			return auth.Authenticator{}, err
+		}
+		logger.Info("API key authentication enabled", "clients", authenticator.Keys.Len())
+	}
+	if cfg.Auth.JWKS != "" {
+		var err error
+		if authenticator.Verifier, err = auth.NewVerifier(ctx, cfg.VerifierConfig()); err != nil {
+			return auth.Authenticator{}, err
+		}
+		logger.Info("Bearer token authentication enabled", "jwks", cfg.Auth.JWKS, "issuer", cfg.Auth.Issuer, "audience", cfg.Auth.Audience)
+	}
+	return authenticator, nil
+}
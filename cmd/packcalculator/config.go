@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"rpg/internal/packcalculator/config"
+)
+
+// configFlags maps the flags of the serve command to the settings they override.
+var configFlags = map[string]string{
+	"port":                "server.port",
+	"cors-origins":        "cors.allowed_origins",
+	"headroom-multiplier": "solver.headroom_multiplier",
+	"clamp":               "solver.clamp",
+	"log-level":           "logging.level",
+	"otlp-endpoint":       "tracing.otlp_endpoint",
+	"api-keys-file":       "auth.api_keys_file",
+	"jwks":                "auth.jwks",
+	"catalog-dir":         "catalog.dir",
+	"cache-size":          "cache.size",
+	"rate-limit":          "rate_limit.requests.rate",
+	"compute-rate-limit":  "rate_limit.computations.rate",
+	"audit-file":          "audit.file",
+	"history-size":        "history.size",
+	"job-workers":         "jobs.workers",
+	"job-queue-size":      "jobs.queue_size",
+	"webhook-hosts":       "webhooks.allowed_hosts",
+	"webhook-dead-letter": "webhooks.dead_letter_file",
+}
+
+// loadConfig reads the configuration from the file, the environment and the flags, in increasing order of
+// precedence, and validates it. It reports false when the effective configuration was printed instead.
+func loadConfig(args []string) (config.Config, bool, error) {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	path := flags.String("config", os.Getenv("RPG_CONFIG"), "YAML or JSON configuration file (default: $RPG_CONFIG)")
+	printConfig := flags.Bool("print-config", false, "print the effective configuration as YAML and exit")
+	for name, setting := range configFlags {
+		flags.String(name, "", "override "+setting)
+	}
+	if err := flags.Parse(args); err != nil {
+		return config.Config{}, false, err
+	}
+
+	// Apply the file and the environment, then only the flags which were given.
+	cfg, err := config.Load(*path, os.Getenv)
+	if err != nil {
+		return config.Config{}, false, err
+	}
+	flags.Visit(func(f *flag.Flag) {
+		if setting, ok := configFlags[f.Name]; ok && err == nil {
+			err = cfg.Set(setting, f.Value.String())
+		}
+	})
+	if err != nil {
+		return config.Config{}, false, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return config.Config{}, false, err
+	}
+
+	if *printConfig {
+		document, err := cfg.YAML()
+		if err != nil {
+			return config.Config{}, false, err
+		}
+		fmt.Print(string(document))
+		return cfg, false, nil
+	}
+	return cfg, true, nil
+}
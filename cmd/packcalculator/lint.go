@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"rpg/internal/packcalculator/services"
+)
+
+// runLint prints warnings about a pack-size set and returns the exit code, which is 1 when warnings were found.
+func runLint(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	sizesFlag := flags.String("sizes", "", "comma-separated pack sizes")
+	threshold := flags.Int("threshold", 0, "largest order checked for sizes which are never needed (0 skips the check)")
+	asJSON := flags.Bool("json", false, "print the diagnostics as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	// Parse the pack sizes.
+	packSizes, err := parseSizes(*sizesFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error parsing sizes:", err)
+		return 2
+	}
+
+	// Check the pack sizes.
+	diagnostics, err := services.LintPackSizes(context.Background(), packSizes, *threshold)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error linting pack sizes:", err)
+		return 2
+	}
+
+	if *asJSON {
+		if diagnostics == nil {
+			diagnostics = []services.Diagnostic{}
+		}
+		if code := printJSON(diagnostics); code != 0 {
+			return code
+		}
+	} else {
+		for _, diagnostic := range diagnostics {
+			fmt.Printf("warning: %s (%s)\n", diagnostic.Message, diagnostic.Code)
+		}
+	}
+
+	if len(diagnostics) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -1,37 +1,286 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
 
+	"rpg/internal/packcalculator/audit"
+	"rpg/internal/packcalculator/auth"
+	"rpg/internal/packcalculator/buildinfo"
+	"rpg/internal/packcalculator/cache"
+	"rpg/internal/packcalculator/catalog"
+	"rpg/internal/packcalculator/config"
 	"rpg/internal/packcalculator/handlers"
+	"rpg/internal/packcalculator/health"
+	"rpg/internal/packcalculator/history"
+	"rpg/internal/packcalculator/idempotency"
+	"rpg/internal/packcalculator/jobs"
+	"rpg/internal/packcalculator/logging"
+	"rpg/internal/packcalculator/metrics"
+	"rpg/internal/packcalculator/ratelimit"
+	"rpg/internal/packcalculator/server"
+	"rpg/internal/packcalculator/tenant"
+	"rpg/internal/packcalculator/tracing"
+	"rpg/internal/packcalculator/webhook"
 )
 
 func main() {
-	// Create a new router from the "gorilla/mux" package.
+	// Run a subcommand when one is given, otherwise start the server.
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "serve":
+			os.Exit(serve(os.Args[2:]))
+		case "recommend":
+			os.Exit(runRecommend(os.Args[2:]))
+		case "simulate":
+			os.Exit(runSimulate(os.Args[2:]))
+		case "lint":
+			os.Exit(runLint(os.Args[2:]))
+		case "replay":
+			os.Exit(runReplay(os.Args[2:]))
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown command %q. Available commands: serve, recommend, simulate, lint, replay.\n", os.Args[1])
+			os.Exit(2)
+		}
+	}
+
+	os.Exit(serve(nil))
+}
+
+// serve runs the HTTP server until it receives SIGINT or SIGTERM and returns the exit code.
+func serve(args []string) int {
+	// Load and validate the configuration before anything starts.
+	cfg, run, err := loadConfig(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading configuration:", err)
+		return 2
+	}
+	if !run {
+		return 0
+	}
+
+	// Write JSON logs at the configured level and report the effective configuration.
+	level, _ := logging.ParseLevel(cfg.Logging.Level)
+	logger := logging.New(os.Stdout, level)
+	slog.SetDefault(logger)
+	logger.Info("Effective configuration", "config", cfg)
+
+	// Apply the solver defaults.
+	handlers.Solver = cfg.SolverConfig()
+	if _, err := handlers.Solver.ClampStrategy(""); err != nil {
+		logger.Error("Error configuring the clamp", "error", err)
+		return 1
+	}
+
+	// Export traces when an OTLP endpoint is configured, flushing them once the server stopped.
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing.OTLPEndpoint)
+	if err != nil {
+		logger.Error("Error setting up tracing", "error", err)
+		return 1
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Error("Error flushing traces", "error", err)
+		}
+	}()
+
+	// Authenticate with API keys or bearer tokens when either is configured.
+	authenticator, err := newAuthenticator(context.Background(), cfg, logger)
+	if err != nil {
+		logger.Error("Error configuring authentication", "error", err)
+		return 1
+	}
+	handlers.Authenticator = authenticator
+
+	// Select the tenant of each request and keep the catalogs of the tenants apart.
+	tenants, err := tenant.NewRegistry(cfg.Tenants)
+	if err != nil {
+		logger.Error("Error configuring tenants", "error", err)
+		return 1
+	}
+	if cfg.Catalog.Dir != "" {
+		if handlers.Catalog, err = catalog.Open(cfg.Catalog.Dir); err != nil {
+			logger.Error("Error opening the catalog", "error", err)
+			return 1
+		}
+	}
+	logger.Info("Tenants configured", "tenants", tenants.IDs(), "catalog_dir", cfg.Catalog.Dir)
+
+	// Cache the results of '/calculate' by tenant and limit the rate of each client.
+	handlers.Results = cache.New(cfg.Cache.Size)
+	limiter := ratelimit.New(cfg.RateLimitConfig())
+
+	// Keep the recent calculations of each tenant for the history endpoints, identifying their clients like the
+	// rate limiter.
+	handlers.History = history.NewStore(cfg.History.Size)
+	audit.TrustedProxies = cfg.RateLimitConfig().TrustedProxies
+	auditLog := &audit.Log{History: handlers.History, Solver: handlers.Solver}
+
+	// Append a record of every calculation to the audit log when one is configured, restoring the history from
+	// the calculations it already holds.
+	if cfg.Audit.File != "" {
+		if err := loadHistory(handlers.History, cfg.Audit); err != nil {
+			logger.Error("Error loading the calculation history", "error", err)
+			return 1
+		}
+
+		auditFile, err := audit.OpenFile(cfg.Audit.File, cfg.Audit.MaxBytes, cfg.Audit.MaxBackups)
+		if err != nil {
+			logger.Error("Error opening the audit log", "error", err)
+			return 1
+		}
+		defer auditFile.Close()
+		auditFile.SyncInterval = time.Duration(cfg.Audit.SyncInterval)
+		auditLog.Writer = auditFile
+		logger.Info("Audit log enabled", "file", cfg.Audit.File, "history", handlers.History.Len())
+	}
+	handlers.Audit = auditLog
+
+	// Notify the callbacks of finished jobs with signed deliveries, recording those which fail every attempt.
+	webhookConfig := cfg.WebhookConfig()
+	webhookConfig.Logger = logger
+	if cfg.Webhooks.DeadLetterFile != "" {
+		deadLetterFile, err := audit.OpenFile(cfg.Webhooks.DeadLetterFile, cfg.Audit.MaxBytes, cfg.Audit.MaxBackups)
+		if err != nil {
+			logger.Error("Error opening the webhook dead letter file", "error", err)
+			return 1
+		}
+		defer deadLetterFile.Close()
+		webhookConfig.DeadLetter = deadLetterFile
+	}
+	handlers.Webhooks = webhook.New(webhookConfig)
+	defer handlers.Webhooks.Close()
+
+	// Calculate the jobs of '/jobs' on a bounded pool of workers, canceling the unfinished ones at shutdown.
+	jobsConfig := cfg.JobsConfig()
+	jobsConfig.Notify = handlers.NotifyJob
+	jobsConfig.Logger = logger
+	handlers.Jobs = jobs.New(jobsConfig, handlers.RunJob)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownGrace))
+		defer cancel()
+		if err := handlers.Jobs.Close(ctx); err != nil {
+			logger.Warn("Jobs still running at shutdown", "error", err)
+		}
+	}()
+
+	// Replay the responses of retried requests carrying an Idempotency-Key header, on the routes which change state
+	// or calculate, once their scope was checked and inside their audit log and rate limiter.
+	idempotent := idempotency.NewStore(time.Duration(cfg.Idempotency.TTL), cfg.Idempotency.MaxKeys)
+	idempotent.MaxKeysPerClient = cfg.Idempotency.MaxKeysPerClient
+	idempotent.MaxBodyBytes = cfg.Idempotency.MaxBodyBytes
+
+	// Create a new router from the "gorilla/mux" package, with the authenticated API routes on their own subrouter
+	// which selects the tenant and counts its requests.
 	router := mux.NewRouter()
+	api := router.NewRoute().Subrouter()
+	api.Use(authenticator.Middleware, tenants.Middleware, metrics.TenantMiddleware(tenant.ID))
+
+	// Require the calculate scope on the calculation and analysis endpoints.
+	calculate := api.NewRoute().Subrouter()
+	calculate.Use(authenticator.RequireScope(auth.ScopeCalculate))
+
+	// Handle requests to the '/calculate' endpoint using the CalculateHandler function, behind the rate limiter
+	// which charges every request and, through the handler, every fresh calculation to the budgets of its client.
+	// Record every request, including those the rate limiter refuses.
+	calculateHandler := auditLog.Middleware(limiter.Middleware(idempotent.Middleware(http.HandlerFunc(handlers.CalculateHandler))))
+	calculate.Handle("/calculate", calculateHandler).Methods("POST")
+
+	// Handle requests to the '/simulate' endpoint using the SimulateHandler function, behind the rate limiter like
+	// '/calculate', charging every scenario to the computations budget of its client.
+	handlers.MaxSimulationCalculations = cfg.Limits.MaxSimulationCalculations
+	calculate.Handle("/simulate", limiter.Middleware(idempotent.Middleware(http.HandlerFunc(handlers.SimulateHandler)))).Methods("POST")
 
-	// Handle requests to the '/calculate' endpoint using the CalculateHandler function.
-	router.HandleFunc("/calculate", handlers.CalculateHandler).Methods("POST")
+	// Handle requests to the '/analyze' and '/lint' endpoints using the AnalyzeHandler and LintHandler functions,
+	// behind the rate limiter like '/calculate', charging every check to the computations budget of its client.
+	calculate.Handle("/analyze", limiter.Middleware(http.HandlerFunc(handlers.AnalyzeHandler))).Methods("POST")
+	calculate.Handle("/lint", limiter.Middleware(http.HandlerFunc(handlers.LintHandler))).Methods("POST")
 
-	// Use the cors.Default() function to enable CORS with default options.
-	corsHandler := cors.Default().Handler(router)
+	// Serve the tenant's product catalog, requiring the catalog:write scope to change it.
+	calculate.HandleFunc("/catalog/products", handlers.ListProductsHandler).Methods("GET")
+	calculate.HandleFunc("/catalog/products/{sku}", handlers.GetProductHandler).Methods("GET")
+	catalogWrite := api.NewRoute().Subrouter()
+	catalogWrite.Use(authenticator.RequireScope(auth.ScopeCatalogWrite))
+	catalogWrite.Handle("/catalog/products/{sku}", idempotent.Middleware(http.HandlerFunc(handlers.PutProductHandler))).Methods("PUT")
+	catalogWrite.Handle("/catalog/products/{sku}", idempotent.Middleware(http.HandlerFunc(handlers.DeleteProductHandler))).Methods("DELETE")
 
-	// Get the port from the environment variable or use a default value (8080).
-	port := os.Getenv("RPG_BACKEND_PORT")
-	if port == "" {
-		port = "8080"
+	// Queue calculations as jobs, which are charged to the rate limits of their client like '/calculate'.
+	calculate.Handle("/jobs", limiter.Middleware(idempotent.Middleware(http.HandlerFunc(handlers.SubmitJobHandler)))).Methods("POST")
+	calculate.HandleFunc("/jobs/{id}", handlers.GetJobHandler).Methods("GET")
+	calculate.Handle("/jobs/{id}", idempotent.Middleware(http.HandlerFunc(handlers.CancelJobHandler))).Methods("DELETE")
+
+	// Serve the tenant's recent calculations without calculating them again.
+	calculate.HandleFunc("/calculations", handlers.ListCalculationsHandler).Methods("GET")
+	calculate.HandleFunc("/calculations/{id}", handlers.GetCalculationHandler).Methods("GET")
+
+	// Expose liveness, readiness and build information for probes and release tracking, without authentication.
+	checker := health.NewChecker()
+	if cfg.Catalog.Dir != "" {
+		checker.Register("catalog", handlers.Catalog.Check)
 	}
+	router.HandleFunc("/healthz", health.LivenessHandler).Methods("GET")
+	router.HandleFunc("/readyz", checker.ReadinessHandler).Methods("GET")
+	router.HandleFunc("/version", handlers.VersionHandler).Methods("GET")
+
+	// Expose the Prometheus metrics on the '/metrics' endpoint.
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
+
+	// Trace every request, log it with its request ID, record its count and latency and limit its body.
+	router.Use(tracing.Middleware, logging.Middleware(logger), metrics.Middleware, server.MaxBodyBytes(cfg.Limits.MaxBodyBytes))
+
+	// Enable CORS for the configured origins.
+	corsHandler := cors.New(cors.Options{
+		AllowedOrigins: cfg.CORS.AllowedOrigins,
+		AllowedMethods: cfg.CORS.AllowedMethods,
+		AllowedHeaders: cfg.CORS.AllowedHeaders,
+	}).Handler(router)
+
+	// Stop gracefully when the process is asked to terminate.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// Log the information about the server starting.
-	log.Printf("Server starting on port %s...\n", port)
+	serverConfig := cfg.ServerConfig()
+	logger.Info("Server starting", "addr", serverConfig.Addr, "version", buildinfo.Version)
+
+	// Serve with CORS handling until a signal arrives, then drain the in-flight requests.
+	httpServer := server.Server{Config: serverConfig, Handler: corsHandler, Checker: checker, Logger: logger}
+	if err := httpServer.Run(ctx); err != nil {
+		logger.Error("Error running server", "error", err)
+		return 1
+	}
+	return 0
+}
 
-	// Start the HTTP server on the specified port with CORS handling.
-	if err := http.ListenAndServe(":"+port, corsHandler); err != nil {
-		log.Fatalf("Error starting server: %v", err)
+// loadHistory adds the calculations of the audit log and its rotated files to the history, the oldest first.
+func loadHistory(store *history.Store, settings config.Audit) error {
+	for _, path := range audit.Paths(settings.File, settings.MaxBackups) {
+		file, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		err = store.Load(file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
 	}
+	return nil
 }
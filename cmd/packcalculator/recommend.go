@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"rpg/internal/packcalculator/analysis"
+)
+
+// runRecommend recommends pack sizes from historical demand and returns the exit code.
+func runRecommend(args []string) int {
+	flags := flag.NewFlagSet("recommend", flag.ContinueOnError)
+	demandPath := flags.String("demand", "", "CSV file of historical orders as quantity[,frequency] (default: standard input)")
+	candidatesFlag := flags.String("candidates", "", "comma-separated candidate pack sizes")
+	k := flags.Int("k", 3, "number of pack sizes to recommend")
+	top := flags.Int("top", 5, "number of ranked pack-size sets to report")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	// Parse the candidate pack sizes.
+	candidates, err := parseSizes(*candidatesFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error parsing candidates:", err)
+		return 2
+	}
+
+	// Read the historical demand from the file or standard input.
+	var input io.Reader = os.Stdin
+	if *demandPath != "" {
+		file, err := os.Open(*demandPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error opening demand:", err)
+			return 1
+		}
+		defer file.Close()
+		input = file
+	}
+	demand, err := analysis.ReadDemand(input)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error reading demand:", err)
+		return 1
+	}
+
+	// Evaluate the pack-size sets.
+	report, err := analysis.RecommendPackSizes(demand, candidates, *k, *top)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error recommending pack sizes:", err)
+		return 1
+	}
+
+	if *asJSON {
+		return printJSON(report)
+	}
+
+	// Print the ranking and the trade-offs as tables.
+	fmt.Printf("Evaluated %d pack-size sets against %d orders.\n", report.Evaluated, report.Orders)
+	fmt.Printf("Recommended pack sizes: %s\n\nRanked by total surplus, then total packs:\n", formatSizes(report.Best.PackSizes))
+	printRecommendations(report.Ranked)
+	fmt.Println("\nTrade-offs (no other set has both less surplus and fewer packs):")
+	printRecommendations(report.ParetoFront)
+	return 0
+}
+
+// printRecommendations prints the recommendations as an aligned table.
+func printRecommendations(recommendations []analysis.Recommendation) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "PACK SIZES\tTOTAL SURPLUS\tTOTAL PACKS\tAVG SURPLUS\tAVG PACKS\tMAX SURPLUS")
+	for _, r := range recommendations {
+		fmt.Fprintf(writer, "%s\t%d\t%d\t%.2f\t%.2f\t%d\n", formatSizes(r.PackSizes), r.TotalSurplus, r.TotalPacks, r.AverageSurplus, r.AveragePacks, r.MaxSurplus)
+	}
+	writer.Flush()
+}
+
+// printJSON prints the value as indented JSON and returns the exit code.
+func printJSON(value any) int {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		fmt.Fprintln(os.Stderr, "Error encoding JSON:", err)
+		return 1
+	}
+	return 0
+}
+
+// parseSizes parses comma-separated pack sizes.
+func parseSizes(text string) ([]int, error) {
+	var sizes []int
+	for _, field := range strings.Split(text, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		size, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pack size %q", field)
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
+
+// formatSizes formats pack sizes as a comma-separated list.
+func formatSizes(sizes []int) string {
+	fields := make([]string, len(sizes))
+	for i, size := range sizes {
+		fields[i] = strconv.Itoa(size)
+	}
+	return "[" + strings.Join(fields, ", ") + "]"
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"rpg/internal/packcalculator/audit"
+	"rpg/internal/packcalculator/models"
+	"rpg/internal/packcalculator/services"
+)
+
+// runReplay runs the calculations of audit logs against the current build and returns the exit code, which is 1
+// when any result changed.
+func runReplay(args []string) int {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	headroom := flags.Int("headroom-multiplier", services.DefaultHeadroomMultiplier, "headroom multiplier of the fixed clamp for records which hold none")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: packcalculator replay [flags] [audit log ...] (default: standard input)")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	// Read the audit logs in the order given, or standard input.
+	var input io.Reader = os.Stdin
+	if flags.NArg() > 0 {
+		readers := make([]io.Reader, flags.NArg())
+		for i, path := range flags.Args() {
+			file, err := os.Open(path)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error opening audit log:", err)
+				return 1
+			}
+			defer file.Close()
+			readers[i] = file
+		}
+		input = io.MultiReader(readers...)
+	}
+
+	// Replay the calculations.
+	report, err := audit.Replay(context.Background(), services.Solver{HeadroomMultiplier: *headroom}, input)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error replaying audit log:", err)
+		return 1
+	}
+
+	if *asJSON {
+		if code := printJSON(report); code != 0 {
+			return code
+		}
+	} else {
+		// Print every changed calculation with its recorded and current outcome.
+		fmt.Printf("Replayed: %d, skipped: %d, changed: %d\n", report.Replayed, report.Skipped, len(report.Changes))
+		if len(report.Changes) > 0 {
+			writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(writer, "REQUEST ID\tTIME\tTENANT\tORDER\tPACK SIZES\tRECORDED\tCURRENT")
+			for _, change := range report.Changes {
+				record := change.Record
+				fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					record.RequestID, record.Time.Format("2006-01-02T15:04:05Z"), record.Tenant,
+					models.FormatDecimal(record.Request.Order, record.Request.Precision), formatSizes(models.Sizes(record.Request.PackSizes)),
+					formatOutcome(record.Response, record.Error), formatOutcome(change.Result, change.Error))
+			}
+			writer.Flush()
+		}
+	}
+
+	if len(report.Changes) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// formatOutcome describes a response by its packs, or else the error.
+func formatOutcome(response *models.CalculateResponse, errorText string) string {
+	if response == nil {
+		return "error: " + errorText
+	}
+	outcome := ""
+	for i, pack := range response.Packs {
+		if i > 0 {
+			outcome += " + "
+		}
+		outcome += fmt.Sprintf("%dx%d", pack.Quantity, pack.PackSize)
+	}
+	if outcome == "" {
+		return "no packs"
+	}
+	return outcome
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"rpg/internal/packcalculator/analysis"
+	"rpg/internal/packcalculator/models"
+	"rpg/internal/packcalculator/services"
+)
+
+// scenarioFlags collects repeated -scenario flags of the form name=size,size,...
+type scenarioFlags []analysis.Scenario
+
+// String returns the scenarios as given on the command line.
+func (s *scenarioFlags) String() string {
+	names := make([]string, len(*s))
+	for i, scenario := range *s {
+		names[i] = scenario.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// Set parses a single scenario.
+func (s *scenarioFlags) Set(value string) error {
+	name, sizes, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("expected name=size,size,... but got %q", value)
+	}
+	packSizes, err := parseSizes(sizes)
+	if err != nil {
+		return err
+	}
+	*s = append(*s, analysis.Scenario{Name: strings.TrimSpace(name), PackSizes: models.NewPackSizes(packSizes)})
+	return nil
+}
+
+// runSimulate compares pack-size sets over the same orders and returns the exit code.
+func runSimulate(args []string) int {
+	flags := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	ordersPath := flags.String("orders", "", "CSV file of orders as quantity[,frequency] (default: standard input)")
+	var scenarios scenarioFlags
+	flags.Var(&scenarios, "scenario", "pack-size set as name=size,size,... (repeat for each scenario, the first is the baseline)")
+	exactOnly := flags.Bool("exact", false, "only accept packings which match the order exactly")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	// Read the orders from the file or standard input.
+	var input io.Reader = os.Stdin
+	if *ordersPath != "" {
+		file, err := os.Open(*ordersPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error opening orders:", err)
+			return 1
+		}
+		defer file.Close()
+		input = file
+	}
+	demand, err := analysis.ReadDemand(input)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error reading orders:", err)
+		return 1
+	}
+
+	// Run the simulation, weighing each order by its frequency.
+	var policy *models.FulfilmentPolicy
+	if *exactOnly {
+		policy = &models.FulfilmentPolicy{ExactOnly: true}
+	}
+	report, err := analysis.SimulateDemand(context.Background(), services.Solver{}, scenarios, demand, policy)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error simulating scenarios:", err)
+		return 1
+	}
+
+	if *asJSON {
+		return printJSON(report)
+	}
+
+	// Print the metrics of every scenario as a table.
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "SCENARIO\tPACK SIZES\tFULFILLED\tTOTAL SURPLUS\tAVG PACKS\tWORST OVERSHOOT\tIMPOSSIBLE\tNEWLY IMPOSSIBLE QUANTITIES")
+	for _, result := range report.Scenarios {
+		fmt.Fprintf(writer, "%s\t%s\t%d/%d\t%d\t%.2f\t%d (%.1f%%)\t%d\t%d\n",
+			result.Name, formatSizes(models.Sizes(result.PackSizes)), result.Fulfilled, result.Orders, result.TotalSurplus,
+			result.AveragePacks, result.WorstOvershoot, result.WorstOvershootPercent, result.Impossible, len(result.NewlyImpossible))
+	}
+	writer.Flush()
+	return 0
+}
@@ -0,0 +1,154 @@
+package analysis
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/go-playground/validator"
+
+	"rpg/utils"
+)
+
+// MaxGapScan is the largest reduced quantity scanned when searching for gaps between exact quantities.
+const MaxGapScan = 1000000
+
+// MaxListedGaps is the largest number of unreachable quantities listed in a GapReport.
+const MaxListedGaps = 1000
+
+// ErrAnalysisTooLarge is returned when the pack sizes are too large to analyse.
+var ErrAnalysisTooLarge = errors.New("pack sizes are too large to analyse")
+
+// GapReport describes which order quantities a pack-size set can fulfil exactly.
+type GapReport struct {
+	PackSizes        []int `json:"pack_sizes"`
+	GCD              int   `json:"gcd"`                         // GCD is the greatest common divisor; only its multiples can be fulfilled exactly.
+	FrobeniusNumber  *int  `json:"frobenius_number,omitempty"`  // FrobeniusNumber is the largest quantity which cannot be fulfilled exactly, if gcd is 1.
+	ExactFrom        int   `json:"exact_from"`                  // ExactFrom is the quantity from which every multiple of the gcd is fulfilled exactly.
+	UnreachableCount int   `json:"unreachable_count"`           // UnreachableCount counts the multiples of the gcd below ExactFrom which cannot be fulfilled exactly.
+	Unreachable      []int `json:"unreachable,omitempty"`       // Unreachable lists the smallest of those quantities, up to MaxListedGaps.
+	MaxSurplus       int   `json:"max_surplus"`                 // MaxSurplus is the largest surplus any order can incur.
+	MaxSurplusOrder  int   `json:"max_surplus_order,omitempty"` // MaxSurplusOrder is the smallest order incurring MaxSurplus.
+}
+
+// gapInput is validated before the analysis, which grows with the number of sizes times the smallest of them.
+type gapInput struct {
+	PackSizes []int `validate:"required,min=1,max=100,dive,gt=0"`
+}
+
+// AnalyzeGaps reports the exactly fulfillable quantities, the Frobenius number and the largest surplus of the pack sizes.
+// It stops when the context is done.
+func AnalyzeGaps(ctx context.Context, packSizes []int) (GapReport, error) {
+	// Validate the input using the validator package.
+	if err := validator.New().Struct(gapInput{PackSizes: packSizes}); err != nil {
+		return GapReport{}, err.(validator.ValidationErrors)
+	}
+
+	// Reduce the sizes by their gcd; the reduced sizes have a Frobenius number.
+	sizes := uniqueSizes(packSizes)
+	divisor := 0
+	for _, size := range sizes {
+		divisor = utils.GCD(divisor, size)
+	}
+	reduced := make([]int, len(sizes))
+	for i, size := range sizes {
+		reduced[i] = size / divisor
+	}
+	if reduced[0] > MaxGapScan {
+		return GapReport{}, fmt.Errorf("%w: smallest reduced size %d exceeds %d", ErrAnalysisTooLarge, reduced[0], MaxGapScan)
+	}
+
+	// Find the smallest exactly fulfillable quantity in every residue class of the smallest size.
+	smallest, err := residueMinimums(ctx, reduced)
+	if err != nil {
+		return GapReport{}, err
+	}
+	frobenius := -1
+	for _, minimum := range smallest {
+		frobenius = max(frobenius, minimum-len(smallest))
+	}
+	if frobenius > MaxGapScan {
+		return GapReport{}, fmt.Errorf("%w: Frobenius number of the reduced sizes exceeds %d", ErrAnalysisTooLarge, MaxGapScan)
+	}
+
+	// Scan up to the Frobenius number; a quantity is unreachable when it is below the minimum of its class.
+	report := GapReport{PackSizes: sizes, GCD: divisor, ExactFrom: (frobenius + 1) * divisor}
+	previous, widestGap, gapStart := 0, 1, 0
+	for quantity := 1; quantity <= frobenius+1; quantity++ {
+		if quantity < smallest[quantity%len(smallest)] {
+			report.UnreachableCount++
+			if len(report.Unreachable) < MaxListedGaps {
+				report.Unreachable = append(report.Unreachable, quantity*divisor)
+			}
+			continue
+		}
+		if gap := quantity - previous; gap > widestGap {
+			widestGap, gapStart = gap, previous
+		}
+		previous = quantity
+	}
+
+	// An order just above an exact quantity is rounded up to the next one.
+	report.MaxSurplus = widestGap*divisor - 1
+	if report.MaxSurplus > 0 {
+		report.MaxSurplusOrder = gapStart*divisor + 1
+	}
+	if divisor == 1 && frobenius >= 0 {
+		report.FrobeniusNumber = &frobenius
+	}
+
+	return report, nil
+}
+
+// residueMinimums returns, for every residue modulo the smallest size, the smallest sum of sizes in that residue class.
+// It stops when the context is done.
+func residueMinimums(ctx context.Context, sizes []int) ([]int, error) {
+	modulus := sizes[0]
+	distances := make([]int, modulus)
+	for i := range distances {
+		distances[i] = math.MaxInt
+	}
+	distances[0] = 0
+
+	// Run Dijkstra's algorithm over the residues, each size being an edge.
+	queue := &residueQueue{{residue: 0, distance: 0}}
+	for queue.Len() > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		current := heap.Pop(queue).(residueItem)
+		if current.distance > distances[current.residue] {
+			continue
+		}
+		for _, size := range sizes[1:] {
+			next := (current.residue + size) % modulus
+			if distance := current.distance + size; distance < distances[next] {
+				distances[next] = distance
+				heap.Push(queue, residueItem{residue: next, distance: distance})
+			}
+		}
+	}
+	return distances, nil
+}
+
+// residueItem is a residue and its tentative distance.
+type residueItem struct {
+	residue  int
+	distance int
+}
+
+// residueQueue is a min-heap of residues ordered by distance.
+type residueQueue []residueItem
+
+func (q residueQueue) Len() int           { return len(q) }
+func (q residueQueue) Less(i, j int) bool { return q[i].distance < q[j].distance }
+func (q residueQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *residueQueue) Push(x any)        { *q = append(*q, x.(residueItem)) }
+func (q *residueQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,108 @@
+package analysis_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-playground/validator"
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/analysis"
+	"rpg/internal/packcalculator/services"
+)
+
+// TestAnalyzeGaps verifies the Frobenius number and gaps of a pack-size set with gcd 1.
+func TestAnalyzeGaps(t *testing.T) {
+	report, err := analysis.AnalyzeGaps(context.Background(), []int{20, 9, 6, 9})
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, []int{6, 9, 20}, report.PackSizes, "Incorrect pack sizes")
+	assert.Equal(t, 1, report.GCD, "Incorrect gcd")
+	if assert.NotNil(t, report.FrobeniusNumber, "Missing Frobenius number") {
+		assert.Equal(t, 43, *report.FrobeniusNumber, "Incorrect Frobenius number")
+	}
+	assert.Equal(t, 44, report.ExactFrom, "Incorrect exact-from quantity")
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 7, 8, 10, 11, 13, 14, 16, 17, 19, 22, 23, 25, 28, 31, 34, 37, 43}, report.Unreachable, "Incorrect unreachable quantities")
+	assert.Equal(t, 22, report.UnreachableCount, "Incorrect unreachable count")
+	assert.Equal(t, 5, report.MaxSurplus, "Incorrect maximum surplus")
+	assert.Equal(t, 1, report.MaxSurplusOrder, "Incorrect maximum surplus order")
+}
+
+// TestAnalyzeGaps_CommonDivisor verifies that sets with a gcd above 1 report the gcd instead of a Frobenius number.
+func TestAnalyzeGaps_CommonDivisor(t *testing.T) {
+	report, err := analysis.AnalyzeGaps(context.Background(), []int{250, 500, 1000, 2000, 5000})
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 250, report.GCD, "Incorrect gcd")
+	assert.Nil(t, report.FrobeniusNumber, "Unexpected Frobenius number")
+	assert.Equal(t, 0, report.ExactFrom, "Incorrect exact-from quantity")
+	assert.Empty(t, report.Unreachable, "Unexpected unreachable quantities")
+	assert.Equal(t, 249, report.MaxSurplus, "Incorrect maximum surplus")
+	assert.Equal(t, 1, report.MaxSurplusOrder, "Incorrect maximum surplus order")
+}
+
+// TestAnalyzeGaps_MatchesCalculator compares the report with the calculator for every quantity up to the Frobenius number.
+func TestAnalyzeGaps_MatchesCalculator(t *testing.T) {
+	packSizes := []int{23, 31, 53}
+	report, err := analysis.AnalyzeGaps(context.Background(), packSizes)
+	assert.NoError(t, err, "Unexpected error")
+	if !assert.NotNil(t, report.FrobeniusNumber, "Missing Frobenius number") {
+		return
+	}
+
+	unreachable := make(map[int]bool)
+	for _, quantity := range report.Unreachable {
+		unreachable[quantity] = true
+	}
+	var maxSurplus int
+	for quantity := 1; quantity <= *report.FrobeniusNumber+1; quantity++ {
+		response, err := services.CalculatePacks(quantity, append([]int(nil), packSizes...))
+		assert.NoError(t, err, "Unexpected error")
+		assert.Equal(t, unreachable[quantity], response.Surplus > 0, "Incorrect reachability of %d", quantity)
+		maxSurplus = max(maxSurplus, response.Surplus)
+	}
+	assert.Equal(t, maxSurplus, report.MaxSurplus, "Incorrect maximum surplus")
+	assert.Equal(t, len(report.Unreachable), report.UnreachableCount, "Incorrect unreachable count")
+}
+
+// TestAnalyzeGaps_SingleUnit verifies that a set containing 1 fulfils every quantity exactly.
+func TestAnalyzeGaps_SingleUnit(t *testing.T) {
+	report, err := analysis.AnalyzeGaps(context.Background(), []int{1, 7})
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Nil(t, report.FrobeniusNumber, "Unexpected Frobenius number")
+	assert.Equal(t, 0, report.UnreachableCount, "Unexpected unreachable quantities")
+	assert.Equal(t, 0, report.MaxSurplus, "Incorrect maximum surplus")
+}
+
+// TestAnalyzeGaps_InvalidInput verifies that empty and non-positive pack sizes are rejected.
+func TestAnalyzeGaps_InvalidInput(t *testing.T) {
+	_, err := analysis.AnalyzeGaps(context.Background(), nil)
+	assert.IsType(t, validator.ValidationErrors{}, err, "Expected validation error for missing pack sizes")
+
+	_, err = analysis.AnalyzeGaps(context.Background(), []int{250, 0})
+	assert.IsType(t, validator.ValidationErrors{}, err, "Expected validation error for a zero pack size")
+
+	packSizes := make([]int, 101)
+	for i := range packSizes {
+		packSizes[i] = 100 + i
+	}
+	_, err = analysis.AnalyzeGaps(context.Background(), packSizes)
+	assert.IsType(t, validator.ValidationErrors{}, err, "Expected validation error for too many pack sizes")
+}
+
+// TestAnalyzeGaps_Canceled verifies that the analysis stops when its context is done.
+func TestAnalyzeGaps_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := analysis.AnalyzeGaps(ctx, []int{23, 31, 53})
+
+	assert.ErrorIs(t, err, context.Canceled, "Expected the analysis to stop")
+}
+
+// TestAnalyzeGaps_TooLarge verifies that sets whose gaps cannot be scanned are rejected.
+func TestAnalyzeGaps_TooLarge(t *testing.T) {
+	_, err := analysis.AnalyzeGaps(context.Background(), []int{analysis.MaxGapScan + 1, analysis.MaxGapScan + 2})
+	assert.ErrorIs(t, err, analysis.ErrAnalysisTooLarge, "Expected analysis too large error")
+}
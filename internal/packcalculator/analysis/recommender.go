@@ -0,0 +1,241 @@
+package analysis
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator"
+
+	"rpg/internal/packcalculator/services"
+)
+
+// MaxCombinations is the largest number of pack-size sets evaluated by RecommendPackSizes.
+const MaxCombinations = 10000
+
+// ErrTooManyCombinations is returned when the candidate sizes allow too many pack-size sets.
+var ErrTooManyCombinations = errors.New("too many pack-size combinations")
+
+// Demand is a historical order quantity and the number of times it was ordered.
+type Demand struct {
+	Quantity  int `json:"quantity" validate:"gt=0"`
+	Frequency int `json:"frequency" validate:"gt=0"`
+}
+
+// Recommendation is a pack-size set evaluated against the historical demand.
+type Recommendation struct {
+	PackSizes      []int   `json:"pack_sizes"`
+	TotalSurplus   int     `json:"total_surplus"`
+	TotalPacks     int     `json:"total_packs"`
+	AverageSurplus float64 `json:"average_surplus"`
+	AveragePacks   float64 `json:"average_packs"`
+	MaxSurplus     int     `json:"max_surplus"`
+}
+
+// RecommendationReport ranks the evaluated pack-size sets and lists the trade-offs between them.
+type RecommendationReport struct {
+	Best        Recommendation   `json:"best"`
+	Ranked      []Recommendation `json:"ranked"`       // Ranked holds the best sets by surplus, then pack count.
+	ParetoFront []Recommendation `json:"pareto_front"` // ParetoFront holds the sets no other set beats on both surplus and pack count.
+	Evaluated   int              `json:"evaluated"`
+	Orders      int              `json:"orders"`
+}
+
+// recommendInput is validated before any combination is evaluated.
+type recommendInput struct {
+	Demand     []Demand `validate:"required,min=1,dive"`
+	Candidates []int    `validate:"required,min=1,dive,gt=0"`
+	K          int      `validate:"gt=0"`
+	Top        int      `validate:"gte=0"`
+}
+
+// RecommendPackSizes evaluates every set of k candidate sizes against the demand using services.CalculatePacks
+// and recommends the set which minimises the total surplus, then the total number of packs.
+func RecommendPackSizes(demand []Demand, candidates []int, k int, top int) (RecommendationReport, error) {
+	// Validate the input using the validator package.
+	if err := validator.New().Struct(recommendInput{Demand: demand, Candidates: candidates, K: k, Top: top}); err != nil {
+		return RecommendationReport{}, err.(validator.ValidationErrors)
+	}
+
+	// Remove duplicate candidates and keep them in ascending order.
+	sizes := uniqueSizes(candidates)
+	if k > len(sizes) {
+		k = len(sizes)
+	}
+	if combinations := binomial(len(sizes), k); combinations < 0 || combinations > MaxCombinations {
+		return RecommendationReport{}, fmt.Errorf("%w: choosing %d of %d sizes exceeds %d", ErrTooManyCombinations, k, len(sizes), MaxCombinations)
+	}
+
+	// Evaluate every combination of k sizes.
+	var report RecommendationReport
+	for _, order := range demand {
+		report.Orders += order.Frequency
+	}
+	var recommendations []Recommendation
+	var err error
+	forEachCombination(sizes, k, func(packSizes []int) bool {
+		var recommendation Recommendation
+		recommendation, err = Evaluate(demand, packSizes)
+		if err != nil {
+			return false
+		}
+		recommendations = append(recommendations, recommendation)
+		return true
+	})
+	if err != nil {
+		return RecommendationReport{}, err
+	}
+
+	// Rank the sets by surplus first and pack count second, like the calculator itself.
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		return better(recommendations[i], recommendations[j])
+	})
+	report.Evaluated = len(recommendations)
+	report.Best = recommendations[0]
+	report.Ranked = recommendations
+	if top > 0 && top < len(recommendations) {
+		report.Ranked = recommendations[:top]
+	}
+	report.ParetoFront = paretoFront(recommendations)
+
+	return report, nil
+}
+
+// Evaluate calculates every order of the demand with the pack sizes and aggregates the result.
+func Evaluate(demand []Demand, packSizes []int) (Recommendation, error) {
+	recommendation := Recommendation{PackSizes: append([]int(nil), packSizes...)}
+	var orders int
+	for _, order := range demand {
+		// Pass a copy since the calculator sorts the sizes in place.
+		response, err := services.CalculatePacks(order.Quantity, append([]int(nil), packSizes...))
+		if err != nil {
+			return Recommendation{}, err
+		}
+
+		var packs int
+		for _, pack := range response.Packs {
+			packs += pack.Quantity
+		}
+		recommendation.TotalSurplus += response.Surplus * order.Frequency
+		recommendation.TotalPacks += packs * order.Frequency
+		recommendation.MaxSurplus = max(recommendation.MaxSurplus, response.Surplus)
+		orders += order.Frequency
+	}
+	if orders > 0 {
+		recommendation.AverageSurplus = float64(recommendation.TotalSurplus) / float64(orders)
+		recommendation.AveragePacks = float64(recommendation.TotalPacks) / float64(orders)
+	}
+	return recommendation, nil
+}
+
+// ReadDemand reads historical demand as CSV lines of "quantity[,frequency]", skipping a header line.
+func ReadDemand(r io.Reader) ([]Demand, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var demand []Demand
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Skip empty lines and a header whose first column is not a number.
+		if len(record) == 0 || strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		quantity, err := strconv.Atoi(strings.TrimSpace(record[0]))
+		if err != nil && line == 1 {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid quantity %q", line, record[0])
+		}
+
+		// Orders without a frequency were placed once.
+		frequency := 1
+		if len(record) > 1 && strings.TrimSpace(record[1]) != "" {
+			if frequency, err = strconv.Atoi(strings.TrimSpace(record[1])); err != nil {
+				return nil, fmt.Errorf("line %d: invalid frequency %q", line, record[1])
+			}
+		}
+		demand = append(demand, Demand{Quantity: quantity, Frequency: frequency})
+	}
+	return demand, nil
+}
+
+// better reports whether recommendation a ranks above recommendation b.
+func better(a, b Recommendation) bool {
+	if a.TotalSurplus != b.TotalSurplus {
+		return a.TotalSurplus < b.TotalSurplus
+	}
+	if a.TotalPacks != b.TotalPacks {
+		return a.TotalPacks < b.TotalPacks
+	}
+	return a.MaxSurplus < b.MaxSurplus
+}
+
+// paretoFront returns the ranked recommendations which no other recommendation dominates.
+func paretoFront(ranked []Recommendation) []Recommendation {
+	// Walking in order of surplus, a set is on the front when it needs fewer packs than every set before it.
+	var front []Recommendation
+	for _, recommendation := range ranked {
+		if len(front) == 0 || recommendation.TotalPacks < front[len(front)-1].TotalPacks {
+			front = append(front, recommendation)
+		}
+	}
+	return front
+}
+
+// uniqueSizes returns the sizes sorted in ascending order without duplicates.
+func uniqueSizes(sizes []int) []int {
+	unique := append([]int(nil), sizes...)
+	sort.Ints(unique)
+	n := 0
+	for i, size := range unique {
+		if i == 0 || size != unique[n-1] {
+			unique[n] = size
+			n++
+		}
+	}
+	return unique[:n]
+}
+
+// binomial returns the number of ways to choose k of n items, or -1 if it exceeds MaxCombinations.
+func binomial(n, k int) int {
+	result := 1
+	for i := 1; i <= k; i++ {
+		result = result * (n - k + i) / i
+		if result > MaxCombinations {
+			return -1
+		}
+	}
+	return result
+}
+
+// forEachCombination calls fn with every combination of k sizes until fn returns false.
+func forEachCombination(sizes []int, k int, fn func([]int) bool) {
+	combination := make([]int, k)
+	var walk func(start, depth int) bool
+	walk = func(start, depth int) bool {
+		if depth == k {
+			return fn(combination)
+		}
+		for i := start; i <= len(sizes)-(k-depth); i++ {
+			combination[depth] = sizes[i]
+			if !walk(i+1, depth+1) {
+				return false
+			}
+		}
+		return true
+	}
+	walk(0, 0)
+}
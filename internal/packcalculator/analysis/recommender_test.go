@@ -0,0 +1,84 @@
+package analysis_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/analysis"
+)
+
+// TestRecommendPackSizes verifies that the set with the least surplus and fewest packs is recommended.
+func TestRecommendPackSizes(t *testing.T) {
+	demand := []analysis.Demand{
+		{Quantity: 250, Frequency: 10},
+		{Quantity: 500, Frequency: 5},
+		{Quantity: 1000, Frequency: 1},
+	}
+
+	report, err := analysis.RecommendPackSizes(demand, []int{250, 300, 500, 1000}, 2, 3)
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 6, report.Evaluated, "Incorrect number of evaluated sets")
+	assert.Equal(t, 16, report.Orders, "Incorrect number of orders")
+	assert.Equal(t, analysis.Recommendation{
+		PackSizes:      []int{250, 500},
+		TotalSurplus:   0,
+		TotalPacks:     17,
+		AverageSurplus: 0,
+		AveragePacks:   17.0 / 16.0,
+		MaxSurplus:     0,
+	}, report.Best, "Incorrect recommendation")
+	assert.Len(t, report.Ranked, 3, "Incorrect number of ranked sets")
+
+	// Every set on the Pareto front needs fewer packs than the sets with less surplus.
+	for i := 1; i < len(report.ParetoFront); i++ {
+		assert.Greater(t, report.ParetoFront[i].TotalSurplus, report.ParetoFront[i-1].TotalSurplus, "Front is not ordered by surplus")
+		assert.Less(t, report.ParetoFront[i].TotalPacks, report.ParetoFront[i-1].TotalPacks, "Front contains a dominated set")
+	}
+}
+
+// TestRecommendPackSizes_InvalidInput verifies errors for invalid input.
+func TestRecommendPackSizes_InvalidInput(t *testing.T) {
+	demand := []analysis.Demand{{Quantity: 10, Frequency: 1}}
+
+	_, err := analysis.RecommendPackSizes(nil, []int{1, 2}, 1, 0)
+	assert.Error(t, err, "Expected error for empty demand")
+
+	_, err = analysis.RecommendPackSizes(demand, []int{1, -2}, 1, 0)
+	assert.Error(t, err, "Expected error for negative candidates")
+
+	_, err = analysis.RecommendPackSizes(demand, []int{1, 2}, 0, 0)
+	assert.Error(t, err, "Expected error for zero pack sizes to recommend")
+
+	candidates := make([]int, 100)
+	for i := range candidates {
+		candidates[i] = i + 1
+	}
+	_, err = analysis.RecommendPackSizes(demand, candidates, 5, 0)
+	assert.ErrorIs(t, err, analysis.ErrTooManyCombinations, "Expected error for too many combinations")
+}
+
+// TestEvaluate verifies the aggregated metrics of a pack-size set.
+func TestEvaluate(t *testing.T) {
+	demand := []analysis.Demand{{Quantity: 251, Frequency: 2}, {Quantity: 12001, Frequency: 1}}
+
+	recommendation, err := analysis.Evaluate(demand, []int{250, 500, 1000, 2000, 5000})
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 2*249+249, recommendation.TotalSurplus, "Incorrect total surplus")
+	assert.Equal(t, 2*1+4, recommendation.TotalPacks, "Incorrect total packs")
+	assert.Equal(t, 249, recommendation.MaxSurplus, "Incorrect maximum surplus")
+}
+
+// TestReadDemand verifies parsing historical demand from CSV.
+func TestReadDemand(t *testing.T) {
+	demand, err := analysis.ReadDemand(strings.NewReader("quantity,frequency\n251,10\n\n12001\n"))
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, []analysis.Demand{{Quantity: 251, Frequency: 10}, {Quantity: 12001, Frequency: 1}}, demand, "Incorrect demand")
+
+	_, err = analysis.ReadDemand(strings.NewReader("251\nmany\n"))
+	assert.Error(t, err, "Expected error for an invalid quantity")
+}
@@ -0,0 +1,170 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/go-playground/validator"
+
+	"rpg/internal/packcalculator/models"
+	"rpg/internal/packcalculator/services"
+)
+
+// Scenario is a named pack-size set to simulate.
+type Scenario struct {
+	Name      string            `json:"name" validate:"required,max=256"`
+	PackSizes []models.PackSize `json:"pack_sizes" validate:"required,min=1,max=100"`
+}
+
+// SimulationRequest runs the same orders against two to ten scenarios.
+type SimulationRequest struct {
+	Orders    []int                    `json:"orders" validate:"required,min=1,max=10000"`
+	Scenarios []Scenario               `json:"scenarios" validate:"required,min=2,max=10,dive"`
+	Policy    *models.FulfilmentPolicy `json:"policy,omitempty"`
+}
+
+// demandSimulation is validated before SimulateDemand calculates any order.
+type demandSimulation struct {
+	Demand    []Demand   `validate:"required,min=1,dive"`
+	Scenarios []Scenario `validate:"required,min=2,max=10,dive"`
+}
+
+// ScenarioResult aggregates the calculations of every order in a scenario.
+type ScenarioResult struct {
+	Name                  string            `json:"name"`
+	PackSizes             []models.PackSize `json:"pack_sizes"`
+	Orders                int               `json:"orders"`
+	Fulfilled             int               `json:"fulfilled"`
+	TotalSurplus          int               `json:"total_surplus"`
+	TotalShortfall        int               `json:"total_shortfall"`
+	TotalPacks            int               `json:"total_packs"`
+	AveragePacks          float64           `json:"average_packs"`
+	WorstOvershoot        int               `json:"worst_overshoot"`
+	WorstOvershootPercent float64           `json:"worst_overshoot_percent"`
+	Impossible            int               `json:"impossible"`
+	ImpossibleOrders      []int             `json:"impossible_orders,omitempty"` // ImpossibleOrders holds the distinct impossible quantities.
+	NewlyImpossible       []int             `json:"newly_impossible,omitempty"`  // NewlyImpossible holds orders the baseline could fulfil.
+}
+
+// SimulationReport compares the scenarios, using the first one as the baseline.
+type SimulationReport struct {
+	Baseline  string           `json:"baseline"`
+	Scenarios []ScenarioResult `json:"scenarios"`
+}
+
+// Simulate calculates every order with every scenario using the solver and compares the results. It stops with the
+// context's error once the context is done.
+func Simulate(ctx context.Context, solver services.Solver, request SimulationRequest) (SimulationReport, error) {
+	// Validate the request using the validator package.
+	if err := validator.New().Struct(request); err != nil {
+		return SimulationReport{}, err.(validator.ValidationErrors)
+	}
+
+	// Calculate each distinct order once and weigh it by how often it occurs.
+	counts := make(map[int]int)
+	var quantities []int
+	for _, order := range request.Orders {
+		if counts[order] == 0 {
+			quantities = append(quantities, order)
+		}
+		counts[order]++
+	}
+
+	return simulate(ctx, solver, request.Scenarios, quantities, counts, request.Policy)
+}
+
+// SimulateDemand is like Simulate for a distribution of orders, weighing each quantity by its frequency rather
+// than listing every order.
+func SimulateDemand(ctx context.Context, solver services.Solver, scenarios []Scenario, demand []Demand, policy *models.FulfilmentPolicy) (SimulationReport, error) {
+	// Validate the input using the validator package.
+	if err := validator.New().Struct(demandSimulation{Demand: demand, Scenarios: scenarios}); err != nil {
+		return SimulationReport{}, err.(validator.ValidationErrors)
+	}
+
+	// Calculate each distinct quantity once, however often it is listed.
+	counts := make(map[int]int)
+	var quantities []int
+	for _, order := range demand {
+		if counts[order.Quantity] == 0 {
+			quantities = append(quantities, order.Quantity)
+		}
+		counts[order.Quantity] += order.Frequency
+	}
+
+	return simulate(ctx, solver, scenarios, quantities, counts, policy)
+}
+
+// simulate calculates the distinct quantities with every scenario and compares the results with the first.
+func simulate(ctx context.Context, solver services.Solver, scenarios []Scenario, quantities []int, counts map[int]int, policy *models.FulfilmentPolicy) (SimulationReport, error) {
+	sort.Ints(quantities)
+
+	report := SimulationReport{Baseline: scenarios[0].Name}
+	var baselineImpossible map[int]bool
+	for i, scenario := range scenarios {
+		result, err := simulateScenario(ctx, solver, scenario, quantities, counts, policy)
+		if err != nil {
+			return SimulationReport{}, err
+		}
+
+		// Compare the impossible orders with the baseline.
+		if i == 0 {
+			baselineImpossible = make(map[int]bool, len(result.ImpossibleOrders))
+			for _, order := range result.ImpossibleOrders {
+				baselineImpossible[order] = true
+			}
+		} else {
+			for _, order := range result.ImpossibleOrders {
+				if !baselineImpossible[order] {
+					result.NewlyImpossible = append(result.NewlyImpossible, order)
+				}
+			}
+		}
+		report.Scenarios = append(report.Scenarios, result)
+	}
+
+	return report, nil
+}
+
+// simulateScenario calculates the distinct orders with the scenario's pack sizes.
+func simulateScenario(ctx context.Context, solver services.Solver, scenario Scenario, quantities []int, counts map[int]int, policy *models.FulfilmentPolicy) (ScenarioResult, error) {
+	result := ScenarioResult{Name: scenario.Name, PackSizes: scenario.PackSizes}
+	for _, quantity := range quantities {
+		// Stop when the simulation was canceled or timed out.
+		if err := ctx.Err(); err != nil {
+			return ScenarioResult{}, err
+		}
+		count := counts[quantity]
+		result.Orders += count
+
+		// Calculate the order exactly like the '/calculate' endpoint would.
+		response, err := solver.CalculateOrder(ctx, models.CalculateRequest{Order: quantity, PackSizes: scenario.PackSizes, Policy: policy})
+		if errors.Is(err, services.ErrNoFeasiblePacking) {
+			result.Impossible += count
+			result.ImpossibleOrders = append(result.ImpossibleOrders, quantity)
+			continue
+		}
+		if err != nil {
+			return ScenarioResult{}, err
+		}
+
+		var packs int
+		for _, pack := range response.Packs {
+			packs += pack.Quantity
+		}
+		result.Fulfilled += count
+		result.TotalPacks += packs * count
+		result.TotalSurplus += response.Surplus * count
+		result.TotalShortfall += response.Shortfall * count
+		if response.Surplus > result.WorstOvershoot {
+			result.WorstOvershoot = response.Surplus
+		}
+		if quantity > 0 {
+			result.WorstOvershootPercent = max(result.WorstOvershootPercent, float64(response.Surplus)*100/float64(quantity))
+		}
+	}
+	if result.Fulfilled > 0 {
+		result.AveragePacks = float64(result.TotalPacks) / float64(result.Fulfilled)
+	}
+	return result, nil
+}
@@ -0,0 +1,120 @@
+package analysis_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/analysis"
+	"rpg/internal/packcalculator/models"
+	"rpg/internal/packcalculator/services"
+)
+
+// TestSimulate verifies the aggregated metrics of each scenario.
+func TestSimulate(t *testing.T) {
+	request := analysis.SimulationRequest{
+		Orders: []int{251, 251, 500, 12001},
+		Scenarios: []analysis.Scenario{
+			{Name: "current", PackSizes: models.NewPackSizes([]int{250, 500, 1000, 2000, 5000})},
+			{Name: "proposed", PackSizes: models.NewPackSizes([]int{300, 600, 5000})},
+		},
+	}
+
+	report, err := analysis.Simulate(context.Background(), services.Solver{}, request)
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, "current", report.Baseline, "Incorrect baseline")
+	assert.Len(t, report.Scenarios, 2, "Incorrect number of scenarios")
+
+	current := report.Scenarios[0]
+	assert.Equal(t, 4, current.Orders, "Incorrect number of orders")
+	assert.Equal(t, 4, current.Fulfilled, "Incorrect number of fulfilled orders")
+	assert.Equal(t, 249*3, current.TotalSurplus, "Incorrect total surplus")
+	assert.Equal(t, 1+1+1+4, current.TotalPacks, "Incorrect total packs")
+	assert.Equal(t, 249, current.WorstOvershoot, "Incorrect worst overshoot")
+	assert.InDelta(t, 24900.0/251, current.WorstOvershootPercent, 1e-9, "Incorrect worst overshoot percent")
+
+	proposed := report.Scenarios[1]
+	assert.Equal(t, 49*2+100+99, proposed.TotalSurplus, "Incorrect total surplus")
+	assert.Empty(t, proposed.ImpossibleOrders, "Unexpected impossible orders")
+}
+
+// TestSimulate_Impossible verifies that orders impossible under the policy are reported.
+func TestSimulate_Impossible(t *testing.T) {
+	request := analysis.SimulationRequest{
+		Orders: []int{500, 600, 600, 900},
+		Scenarios: []analysis.Scenario{
+			{Name: "current", PackSizes: models.NewPackSizes([]int{250, 500})},
+			{Name: "proposed", PackSizes: models.NewPackSizes([]int{300, 600})},
+		},
+		Policy: &models.FulfilmentPolicy{ExactOnly: true},
+	}
+
+	report, err := analysis.Simulate(context.Background(), services.Solver{}, request)
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 3, report.Scenarios[0].Impossible, "Incorrect number of impossible orders")
+	assert.Equal(t, []int{600, 900}, report.Scenarios[0].ImpossibleOrders, "Incorrect impossible orders")
+	assert.Equal(t, []int{500}, report.Scenarios[1].ImpossibleOrders, "Incorrect impossible orders")
+	assert.Equal(t, []int{500}, report.Scenarios[1].NewlyImpossible, "Incorrect newly impossible orders")
+}
+
+// TestSimulate_InvalidRequest verifies an error is returned for fewer than two scenarios.
+func TestSimulate_InvalidRequest(t *testing.T) {
+	request := analysis.SimulationRequest{
+		Orders:    []int{500},
+		Scenarios: []analysis.Scenario{{Name: "current", PackSizes: models.NewPackSizes([]int{250})}},
+	}
+
+	_, err := analysis.Simulate(context.Background(), services.Solver{}, request)
+
+	assert.Error(t, err, "Expected error for a single scenario")
+}
+
+// TestSimulate_TooManyOrders verifies an error is returned for more orders than a request may list.
+func TestSimulate_TooManyOrders(t *testing.T) {
+	request := analysis.SimulationRequest{
+		Orders: make([]int, 10001),
+		Scenarios: []analysis.Scenario{
+			{Name: "current", PackSizes: models.NewPackSizes([]int{250})},
+			{Name: "proposed", PackSizes: models.NewPackSizes([]int{300})},
+		},
+	}
+
+	_, err := analysis.Simulate(context.Background(), services.Solver{}, request)
+
+	assert.Error(t, err, "Expected error for too many orders")
+}
+
+// TestSimulateDemand verifies that frequencies weigh the orders like repeated orders do.
+func TestSimulateDemand(t *testing.T) {
+	scenarios := []analysis.Scenario{
+		{Name: "current", PackSizes: models.NewPackSizes([]int{250, 500, 1000, 2000, 5000})},
+		{Name: "proposed", PackSizes: models.NewPackSizes([]int{300, 600, 5000})},
+	}
+	expected, err := analysis.Simulate(context.Background(), services.Solver{}, analysis.SimulationRequest{Orders: []int{251, 251, 500, 12001}, Scenarios: scenarios})
+	assert.NoError(t, err, "Unexpected error")
+
+	report, err := analysis.SimulateDemand(context.Background(), services.Solver{}, scenarios, []analysis.Demand{{Quantity: 251, Frequency: 2}, {Quantity: 500, Frequency: 1}, {Quantity: 12001, Frequency: 1}}, nil)
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, expected, report, "Frequencies should weigh the orders")
+}
+
+// TestSimulate_Canceled verifies that a canceled simulation stops with the context's error.
+func TestSimulate_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	request := analysis.SimulationRequest{
+		Orders: []int{251, 500},
+		Scenarios: []analysis.Scenario{
+			{Name: "current", PackSizes: models.NewPackSizes([]int{250, 500})},
+			{Name: "proposed", PackSizes: models.NewPackSizes([]int{300})},
+		},
+	}
+
+	_, err := analysis.Simulate(ctx, services.Solver{}, request)
+
+	assert.ErrorIs(t, err, context.Canceled, "Expected the simulation to stop")
+}
@@ -0,0 +1,186 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"rpg/internal/packcalculator/buildinfo"
+	"rpg/internal/packcalculator/logging"
+	"rpg/internal/packcalculator/models"
+	"rpg/internal/packcalculator/ratelimit"
+	"rpg/internal/packcalculator/services"
+	"rpg/internal/packcalculator/tenant"
+)
+
+// TrustedProxies are the proxies whose X-Forwarded-For entries are believed when identifying anonymous callers, as
+// for the rate limiter. None are trusted unless set at startup.
+var TrustedProxies []netip.Prefix
+
+// IDHeader returns the ID of the record of a request to the client.
+const IDHeader = "X-Calculation-ID"
+
+// Record is a line of the audit log describing a single calculation, a '/calculate' request or a job, and its
+// outcome.
+type Record struct {
+	ID         string                    `json:"id"`
+	Time       time.Time                 `json:"time"`
+	RequestID  string                    `json:"request_id,omitempty"`
+	Client     string                    `json:"client"` // Client identifies the caller like the rate limiter, see Client.
+	Tenant     string                    `json:"tenant"`
+	Version    string                    `json:"version"`
+	Solver     string                    `json:"solver,omitempty"`
+	Clamp      string                    `json:"clamp,omitempty"`               // Clamp is the clamp strategy in effect for the calculation.
+	Headroom   int                       `json:"headroom_multiplier,omitempty"` // Headroom is the multiplier of the fixed clamp in effect.
+	Cached     bool                      `json:"cached,omitempty"`
+	Status     int                       `json:"status"`
+	DurationMS float64                   `json:"duration_ms"`
+	Request    *models.CalculateRequest  `json:"request,omitempty"` // Request is the order as calculated, with the tenant's defaults and catalog applied.
+	Response   *models.CalculateResponse `json:"response,omitempty"`
+	Error      string                    `json:"error,omitempty"`
+}
+
+// Recorder keeps the records of successful calculations, such as the calculation history.
+type Recorder interface {
+	Add(record Record)
+}
+
+// Log records every request it sees, writing it to a writer as one JSON object per line and passing successful
+// calculations to a recorder.
+type Log struct {
+	Writer  io.Writer       // Writer receives the records, if set; an *audit.File rotates them.
+	History Recorder        // History receives the records of successful calculations, if set.
+	Solver  services.Solver // Solver holds the clamp settings of requests which name none.
+
+	mu sync.Mutex
+}
+
+// contextKey keys the values this package stores in a context.
+type contextKey int
+
+const recordKey contextKey = iota
+
+// Annotate adds the calculated order to the audit record of the request, if it is audited. The handler calls it
+// once the order is resolved, telling whether the response comes from the cache.
+func Annotate(ctx context.Context, request models.CalculateRequest, cached bool) {
+	if record, ok := ctx.Value(recordKey).(*Record); ok {
+		record.Request = &request
+		record.Cached = cached
+	}
+}
+
+// Middleware records every request with its response once it was served. Failing to write a record is logged
+// but does not affect the response, which was already sent.
+func (l *Log) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		record := &Record{ID: newID(), Time: start.UTC(), RequestID: logging.RequestID(r.Context()), Version: buildinfo.Version}
+		w.Header().Set(IDHeader, record.ID)
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), recordKey, record)))
+
+		record.DurationMS = float64(time.Since(start).Microseconds()) / 1000
+		record.Client = Client(r)
+		record.Tenant = tenant.ID(r.Context())
+		record.Status = recorder.status
+		describe(record, recorder.body.Bytes())
+		l.Add(r.Context(), *record)
+	})
+}
+
+// Add records a calculation, filling in its solver and clamp settings, so it can be replayed with the same ones.
+// Besides the middleware it records calculations made outside of a request, such as asynchronous jobs.
+func (l *Log) Add(ctx context.Context, record Record) {
+	if record.Request != nil {
+		record.Solver = services.SolverFor(*record.Request)
+		record.Clamp = record.Request.Clamp
+		if record.Clamp == "" {
+			record.Clamp = l.Solver.Clamp
+		}
+		if record.Clamp == "" {
+			record.Clamp = services.ClampFixed
+		}
+		if record.Clamp == services.ClampFixed {
+			record.Headroom = l.Solver.HeadroomMultiplier
+			if record.Headroom == 0 {
+				record.Headroom = services.DefaultHeadroomMultiplier
+			}
+		}
+	}
+
+	if l.History != nil && record.Response != nil && record.Request != nil {
+		l.History.Add(record)
+	}
+	if err := l.write(&record); err != nil {
+		logging.FromContext(ctx).Error("Error writing audit record", "error", err)
+	}
+}
+
+// describe fills the outcome of the record from the response body.
+func describe(record *Record, body []byte) {
+	if record.Status == http.StatusOK {
+		var response models.CalculateResponse
+		if err := json.Unmarshal(body, &response); err == nil {
+			record.Response = &response
+			return
+		}
+	}
+	record.Error = strings.TrimSpace(string(body))
+}
+
+// write appends the record to the log as a single line, if the log has a writer.
+func (l *Log) write(record *Record) error {
+	if l.Writer == nil {
+		return nil
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.Writer.Write(append(line, '\n'))
+	return err
+}
+
+// newID returns a random 128-bit ID in hexadecimal.
+func newID() string {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(id[:])
+}
+
+// Client identifies the caller of the request the same way as the rate limiter: "api_key:shop" or "bearer:shop" for
+// an authenticated principal, or else "ip:" and its IP address behind the TrustedProxies.
+func Client(r *http.Request) string {
+	return ratelimit.ClientKey(r, TrustedProxies)
+}
+
+// responseRecorder keeps the status code and body written by a handler while passing them on.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// WriteHeader records the status code before writing it.
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the body before writing it.
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
@@ -0,0 +1,145 @@
+package audit_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/audit"
+	"rpg/internal/packcalculator/auth"
+	"rpg/internal/packcalculator/models"
+	"rpg/internal/packcalculator/services"
+)
+
+// TestLog_Middleware verifies that a request is recorded with its client, calculation and response.
+func TestLog_Middleware(t *testing.T) {
+	var buffer bytes.Buffer
+	log := &audit.Log{Writer: &buffer, Solver: services.Solver{Clamp: "fixed", HeadroomMultiplier: 20}}
+	request := models.CalculateRequest{Order: 263, PackSizes: models.NewPackSizes([]int{23, 31, 53})}
+	handler := log.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		audit.Annotate(r.Context(), request, true)
+		w.Write([]byte(`{"packs":[{"pack_size":23,"quantity":2},{"pack_size":31,"quantity":7}]}`))
+	}))
+
+	req := httptest.NewRequest("POST", "/calculate", nil)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{Subject: "north-warehouse", Method: auth.MethodAPIKey}))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var record audit.Record
+	assert.NoError(t, json.Unmarshal(buffer.Bytes(), &record), "Expected a JSON line")
+	assert.Equal(t, "api_key:north-warehouse", record.Client, "Incorrect client")
+	assert.Equal(t, "default", record.Tenant, "Incorrect tenant")
+	assert.Equal(t, "astar", record.Solver, "Incorrect solver")
+	assert.Equal(t, "fixed", record.Clamp, "Expected the default clamp")
+	assert.Equal(t, 20, record.Headroom, "Expected the headroom multiplier of the clamp")
+	assert.True(t, record.Cached, "Expected a cached response")
+	assert.Equal(t, http.StatusOK, record.Status, "Incorrect status")
+	assert.Equal(t, 263, record.Request.Order, "Incorrect request")
+	assert.Equal(t, []models.Pack{{PackSize: 23, Quantity: 2}, {PackSize: 31, Quantity: 7}}, record.Response.Packs, "Incorrect response")
+}
+
+// recorder collects the records passed to the history.
+type recorder []audit.Record
+
+func (r *recorder) Add(record audit.Record) { *r = append(*r, record) }
+
+// TestLog_Middleware_History verifies that only successful calculations reach the history, with the ID returned
+// to the client, and that no writer is needed.
+func TestLog_Middleware_History(t *testing.T) {
+	var history recorder
+	log := &audit.Log{History: &history}
+	status := http.StatusOK
+	handler := log.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		audit.Annotate(r.Context(), models.CalculateRequest{Order: 263, PackSizes: models.NewPackSizes([]int{23, 31, 53})}, false)
+		w.WriteHeader(status)
+		w.Write([]byte(`{"packs":[{"pack_size":53,"quantity":5}]}`))
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/calculate", nil))
+	status = http.StatusTooManyRequests
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/calculate", nil))
+
+	assert.Len(t, history, 1, "Expected only the successful calculation")
+	assert.Len(t, history[0].ID, 32, "Expected a random ID")
+	assert.Equal(t, history[0].ID, w.Header().Get(audit.IDHeader), "Expected the ID in the response")
+}
+
+// TestLog_Middleware_Error verifies that refused requests are recorded with their error.
+func TestLog_Middleware_Error(t *testing.T) {
+	var buffer bytes.Buffer
+	log := &audit.Log{Writer: &buffer}
+	handler := log.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Error decoding JSON request", http.StatusBadRequest)
+	}))
+
+	req := httptest.NewRequest("POST", "/calculate", nil)
+	req.RemoteAddr = "192.0.2.1:5000"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var record audit.Record
+	assert.NoError(t, json.Unmarshal(buffer.Bytes(), &record), "Expected a JSON line")
+	assert.Equal(t, "ip:192.0.2.1", record.Client, "Expected the IP address of an anonymous client")
+	assert.Equal(t, http.StatusBadRequest, record.Status, "Incorrect status")
+	assert.Equal(t, "Error decoding JSON request", record.Error, "Incorrect error")
+	assert.Nil(t, record.Request, "Expected no calculation")
+}
+
+// TestClient verifies that callers are identified like the rate limiter identifies them: principals by their method
+// and subject, and anonymous callers by the address behind the trusted proxies.
+func TestClient(t *testing.T) {
+	audit.TrustedProxies = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	defer func() { audit.TrustedProxies = nil }()
+	client := func(principal *auth.Principal, remoteAddr, forwarded string) string {
+		req := httptest.NewRequest("POST", "/calculate", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", forwarded)
+		if principal != nil {
+			req = req.WithContext(auth.WithPrincipal(req.Context(), *principal))
+		}
+		return audit.Client(req)
+	}
+
+	key := client(&auth.Principal{Subject: "shop", Method: auth.MethodAPIKey}, "192.0.2.1:5000", "")
+	token := client(&auth.Principal{Subject: "shop", Method: auth.MethodBearer}, "192.0.2.1:5000", "")
+	assert.Equal(t, "api_key:shop", key, "Incorrect API key client")
+	assert.NotEqual(t, key, token, "Expected an API key and a token of the same name to be different clients")
+
+	assert.Equal(t, "ip:198.51.100.7", client(nil, "10.0.0.1:5000", "203.0.113.9, 198.51.100.7"), "Expected the address behind the trusted proxy")
+	assert.Equal(t, "ip:192.0.2.1", client(nil, "192.0.2.1:5000", "198.51.100.7"), "Expected the forwarded address of an untrusted caller to be ignored")
+}
+
+// TestReplay verifies that calculations are run again and only changed outcomes are reported.
+func TestReplay(t *testing.T) {
+	log := strings.Join([]string{
+		`{"request_id":"same","status":200,"clamp":"fixed","request":{"order":263,"pack_sizes":[23,31,53]},"response":{"packs":[{"pack_size":23,"quantity":2},{"pack_size":31,"quantity":7}]}}`,
+		`{"request_id":"changed","status":200,"clamp":"fixed","request":{"order":263,"pack_sizes":[23,31,53]},"response":{"packs":[{"pack_size":53,"quantity":5}],"surplus":2}}`,
+		`{"request_id":"recorded-headroom","status":200,"clamp":"fixed","headroom_multiplier":1,"request":{"order":500,"pack_sizes":[23,31,53]},"response":{"packs":[{"pack_size":23,"quantity":1},{"pack_size":53,"quantity":9}]}}`,
+		`{"request_id":"now-feasible","status":422,"request":{"order":263,"pack_sizes":[23,31,53]},"error":"no packing"}`,
+		`{"request_id":"still-infeasible","status":422,"request":{"order":1,"pack_sizes":[2],"policy":{"exact_only":true}},"error":"no packing"}`,
+		`{"request_id":"malformed","status":400,"error":"Error decoding JSON request"}`,
+		`{"request_id":"limited","status":429,"request":{"order":263,"pack_sizes":[23,31,53]},"error":"rate limit exceeded"}`,
+	}, "\n")
+
+	report, err := audit.Replay(context.Background(), services.Solver{}, strings.NewReader(log))
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 5, report.Replayed, "Incorrect number of replayed calculations")
+	assert.Equal(t, 2, report.Skipped, "Incorrect number of skipped records")
+	var changed []string
+	for _, change := range report.Changes {
+		changed = append(changed, change.Record.RequestID)
+	}
+	assert.Equal(t, []string{"changed", "now-feasible"}, changed, "Incorrect changes")
+	assert.Equal(t, []models.Pack{{PackSize: 23, Quantity: 2}, {PackSize: 31, Quantity: 7}}, report.Changes[0].Result.Packs, "Incorrect current result")
+
+	_, err = audit.Replay(context.Background(), services.Solver{}, strings.NewReader("{"))
+	assert.Error(t, err, "Expected an error for a malformed log")
+}
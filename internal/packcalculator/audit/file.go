@@ -0,0 +1,156 @@
+package audit
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// File is an append-only file which is rotated once it exceeds its maximum size. Rotated files are renamed with
+// the suffixes ".1", ".2" and so on, ".1" being the most recent, and the oldest beyond MaxBackups are removed.
+type File struct {
+	Path       string
+	MaxBytes   int64 // MaxBytes is the size after which the file is rotated; zero never rotates.
+	MaxBackups int   // MaxBackups is the number of rotated files kept; zero keeps none, deleting the file on rotation.
+
+	// SyncInterval batches the flushes to stable storage: writes are flushed at most this long after they were
+	// made, together with those made meanwhile. Zero flushes every write before it returns.
+	SyncInterval time.Duration
+
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+	pending *time.Timer // pending flushes the writes made since the last flush, if any.
+	err     error       // err is the error of the last flush in the background, reported by the next write.
+}
+
+// OpenFile opens the file for appending, creating it if needed, and returns it rotating at the maximum size.
+func OpenFile(path string, maxBytes int64, maxBackups int) (*File, error) {
+	file := &File{Path: path, MaxBytes: maxBytes, MaxBackups: maxBackups}
+	if err := file.open(); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// Write appends the bytes to the file in a single write and flushes them to stable storage, at once or within the
+// sync interval, rotating the file first when the bytes would take it beyond its maximum size.
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file != nil && f.MaxBytes > 0 && f.size > 0 && f.size+int64(len(p)) > f.MaxBytes {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	if err != nil {
+		return n, err
+	}
+	if f.SyncInterval <= 0 {
+		return n, f.file.Sync()
+	}
+	if f.pending == nil {
+		f.pending = time.AfterFunc(f.SyncInterval, f.flush)
+	}
+	err, f.err = f.err, nil
+	return n, err
+}
+
+// Close flushes the pending writes and closes the file.
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.sync()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	f.file = nil
+	return err
+}
+
+// flush flushes the pending writes in the background, keeping the error for the next write.
+func (f *File) flush() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.pending == nil || f.file == nil {
+		return
+	}
+	f.err = f.sync()
+}
+
+// sync flushes the pending writes, if any; the caller holds the lock.
+func (f *File) sync() error {
+	if f.pending == nil {
+		return nil
+	}
+	f.pending.Stop()
+	f.pending = nil
+	return f.file.Sync()
+}
+
+// open opens the file for appending and reads its size; the caller holds the lock.
+func (f *File) open() error {
+	file, err := os.OpenFile(f.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// rotate closes the file and shifts it and its backups by one suffix; the caller holds the lock.
+func (f *File) rotate() error {
+	if err := f.sync(); err != nil {
+		return err
+	}
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+
+	// Drop the oldest backup, then rename from the oldest to the newest so nothing is overwritten.
+	if err := os.Remove(backupPath(f.Path, f.MaxBackups)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for i := f.MaxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(backupPath(f.Path, i), backupPath(f.Path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if f.MaxBackups == 0 {
+		return os.Remove(f.Path)
+	}
+	return os.Rename(f.Path, backupPath(f.Path, 1))
+}
+
+// backupPath returns the path of the rotated file with the number.
+func backupPath(path string, number int) string {
+	return fmt.Sprintf("%s.%d", path, number)
+}
+
+// Paths returns the paths of the rotated files and the file itself, from the oldest to the most recent.
+func Paths(path string, maxBackups int) []string {
+	paths := make([]string, 0, maxBackups+1)
+	for i := maxBackups; i >= 1; i-- {
+		paths = append(paths, backupPath(path, i))
+	}
+	return append(paths, path)
+}
@@ -0,0 +1,66 @@
+package audit_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/audit"
+)
+
+// TestFile_Rotation verifies that the file is rotated before it exceeds its size and only the newest backups are kept.
+func TestFile_Rotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	file, err := audit.OpenFile(path, 10, 1)
+	assert.NoError(t, err, "Unexpected error")
+	defer file.Close()
+
+	for _, line := range []string{"one\n", "two\n", "three\n", "four\n", "five\n"} {
+		_, err := file.Write([]byte(line))
+		assert.NoError(t, err, "Unexpected error")
+	}
+
+	for name, expected := range map[string]string{"audit.jsonl": "four\nfive\n", "audit.jsonl.1": "three\n"} {
+		content, err := os.ReadFile(filepath.Join(filepath.Dir(path), name))
+		assert.NoError(t, err, "Missing file %s", name)
+		assert.Equal(t, expected, string(content), "Incorrect content of %s", name)
+	}
+	_, err = os.Stat(path + ".2")
+	assert.True(t, os.IsNotExist(err), "Expected backups beyond the maximum to be removed")
+}
+
+// TestFile_Append verifies that reopening the file appends to it.
+func TestFile_Append(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	for _, line := range []string{"one\n", "two\n"} {
+		file, err := audit.OpenFile(path, 0, 0)
+		assert.NoError(t, err, "Unexpected error")
+		_, err = file.Write([]byte(line))
+		assert.NoError(t, err, "Unexpected error")
+		assert.NoError(t, file.Close())
+	}
+
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, "one\ntwo\n", string(content), "Expected the lines to be appended")
+}
+
+// TestFile_SyncInterval verifies that batched writes are readable at once and flushed when the file is closed.
+func TestFile_SyncInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	file, err := audit.OpenFile(path, 0, 0)
+	assert.NoError(t, err, "Unexpected error")
+	file.SyncInterval = time.Hour
+
+	for _, line := range []string{"one\n", "two\n"} {
+		_, err := file.Write([]byte(line))
+		assert.NoError(t, err, "Unexpected error")
+	}
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, "one\ntwo\n", string(content), "Expected the lines to be written before they are flushed")
+	assert.NoError(t, file.Close(), "Expected the pending writes to be flushed")
+}
@@ -0,0 +1,77 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+
+	"rpg/internal/packcalculator/models"
+	"rpg/internal/packcalculator/services"
+)
+
+// Change is an audited calculation whose outcome differs with the current build.
+type Change struct {
+	Record Record                    `json:"record"`           // Record is the audited calculation.
+	Result *models.CalculateResponse `json:"result,omitempty"` // Result is the response of the current build.
+	Error  string                    `json:"error,omitempty"`  // Error is the error of the current build.
+}
+
+// ReplayReport summarises a replay of audit records.
+type ReplayReport struct {
+	Replayed int      `json:"replayed"` // Replayed is the number of calculations run again.
+	Skipped  int      `json:"skipped"`  // Skipped is the number of records without a calculation, such as malformed or rate limited requests.
+	Changes  []Change `json:"changes"`
+}
+
+// Replay runs the calculation of every record read from the reader again and reports those whose outcome changed:
+// a different response, an error instead of a response or a response instead of an error. Error messages and
+// statuses are not compared. Each calculation uses the solver with the clamp strategy and headroom multiplier
+// recorded with it; the solver's own apply to records which hold none.
+func Replay(ctx context.Context, solver services.Solver, reader io.Reader) (ReplayReport, error) {
+	report := ReplayReport{Changes: []Change{}}
+	decoder := json.NewDecoder(reader)
+	for line := 1; ; line++ {
+		var record Record
+		err := decoder.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			return report, nil
+		}
+		if err != nil {
+			return report, fmt.Errorf("record %d: %v", line, err)
+		}
+		if record.Request == nil || record.Status == http.StatusTooManyRequests {
+			report.Skipped++
+			continue
+		}
+
+		request := *record.Request
+		if request.Clamp == "" {
+			request.Clamp = record.Clamp
+		}
+		recorded := solver
+		if record.Headroom > 0 {
+			recorded.HeadroomMultiplier = record.Headroom
+		}
+		result, err := recorded.CalculateOrder(ctx, request)
+		report.Replayed++
+
+		switch {
+		case err != nil && record.Response != nil:
+			report.Changes = append(report.Changes, Change{Record: record, Error: err.Error()})
+		case err == nil && (record.Response == nil || !reflect.DeepEqual(normalize(result), normalize(*record.Response))):
+			report.Changes = append(report.Changes, Change{Record: record, Result: &result})
+		}
+	}
+}
+
+// normalize returns the response as it reads after a JSON round trip, so empty and missing lists compare equal.
+func normalize(response models.CalculateResponse) models.CalculateResponse {
+	var normalized models.CalculateResponse
+	encoded, _ := json.Marshal(response)
+	json.Unmarshal(encoded, &normalized)
+	return normalized
+}
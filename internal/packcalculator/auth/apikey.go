@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator"
+	"gopkg.in/yaml.v3"
+)
+
+// APIKeyHeader carries the API key of a request.
+const APIKeyHeader = "X-API-Key"
+
+// Errors returned when a request is refused.
+var (
+	ErrMissingKey    = errors.New("missing API key")
+	ErrUnknownKey    = errors.New("unknown API key")
+	ErrRateLimited   = errors.New("rate limit exceeded")
+	ErrQuotaExceeded = errors.New("daily quota exceeded")
+)
+
+// ErrInvalidKeyFile is returned when the API key file cannot be read or fails validation.
+var ErrInvalidKeyFile = errors.New("invalid API key file")
+
+// Client is a holder of an API key. Only the SHA-256 hash of the key is stored.
+type Client struct {
+	Name       string   `json:"name" yaml:"name" validate:"required"`
+	KeySHA256  string   `json:"key_sha256" yaml:"key_sha256" validate:"required,hexadecimal,len=64"`
+	RateLimit  float64  `json:"rate_limit" yaml:"rate_limit" validate:"gte=0"`                     // RateLimit is the sustained requests per second, or 0 for no limit.
+	Burst      int      `json:"burst" yaml:"burst" validate:"gte=0"`                               // Burst is the number of requests allowed at once, at least 1 when rate limited.
+	DailyQuota int      `json:"daily_quota" yaml:"daily_quota" validate:"gte=0"`                   // DailyQuota is the number of requests per UTC day, or 0 for no quota.
+	Tenant     string   `json:"tenant,omitempty" yaml:"tenant,omitempty"`                          // Tenant is the tenant the client acts for, if any.
+	Scopes     []string `json:"scopes,omitempty" yaml:"scopes,omitempty" validate:"dive,required"` // Scopes are the granted scopes; none grants ScopeCalculate.
+}
+
+// keyFile is the content of an API key file.
+type keyFile struct {
+	Clients []Client `json:"clients" yaml:"clients" validate:"dive"`
+}
+
+// HashKey returns the hexadecimal SHA-256 hash of the key, as stored in the key file.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// LoadKeys reads the clients from a YAML or JSON file; files ending in ".json" are read as JSON.
+func LoadKeys(path string) ([]Client, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKeyFile, err)
+	}
+
+	var file keyFile
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&file)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidKeyFile, path, err)
+	}
+	return file.Clients, nil
+}
+
+// clientState is a client with the limiter of its requests.
+type clientState struct {
+	client  Client
+	limiter *Limiter
+}
+
+// KeyStore authenticates requests by their API key and enforces the rate limit and daily quota of each client.
+type KeyStore struct {
+	Clock func() time.Time // Clock returns the current time; nil uses time.Now.
+
+	clients map[string]*clientState
+}
+
+// NewKeyStore validates the clients and returns a store for them.
+func NewKeyStore(clients []Client) (*KeyStore, error) {
+	// Validate the input using the validator package.
+	validate := validator.New()
+	store := &KeyStore{clients: make(map[string]*clientState, len(clients))}
+	names := make(map[string]bool, len(clients))
+	for i, client := range clients {
+		if err := validate.Struct(client); err != nil {
+			return nil, fmt.Errorf("%w: client %d: %v", ErrInvalidKeyFile, i+1, err)
+		}
+		hash := strings.ToLower(client.KeySHA256)
+		if names[client.Name] || store.clients[hash] != nil {
+			return nil, fmt.Errorf("%w: client %q or its key is listed twice", ErrInvalidKeyFile, client.Name)
+		}
+		names[client.Name] = true
+
+		state := &clientState{client: client, limiter: NewLimiter(client.RateLimit, client.Burst, client.DailyQuota)}
+		store.clients[hash] = state
+	}
+	return store, nil
+}
+
+// Len returns the number of clients.
+func (s *KeyStore) Len() int {
+	return len(s.clients)
+}
+
+// Allow authenticates the key and counts a request against its client's limits. A refused request returns how long
+// the client should wait before retrying, or zero when retrying cannot help.
+func (s *KeyStore) Allow(key string) (Client, time.Duration, error) {
+	if key == "" {
+		return Client{}, 0, ErrMissingKey
+	}
+	state, ok := s.clients[HashKey(key)]
+	if !ok {
+		return Client{}, 0, ErrUnknownKey
+	}
+
+	retryAfter, err := state.limiter.Allow(s.now())
+	return state.client, retryAfter, err
+}
+
+// Principal returns the caller the client authenticates as.
+func (c Client) Principal() Principal {
+	scopes := c.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{ScopeCalculate}
+	}
+	return Principal{Subject: c.Name, Tenant: c.Tenant, Scopes: scopes, Method: MethodAPIKey}
+}
+
+// now returns the current time of the store's clock.
+func (s *KeyStore) now() time.Time {
+	if s.Clock == nil {
+		return time.Now()
+	}
+	return s.Clock()
+}
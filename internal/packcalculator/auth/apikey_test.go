@@ -0,0 +1,138 @@
+package auth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/auth"
+)
+
+// clock is a settable time for the key store.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time { return c.now }
+
+// newStore returns a key store for the clients, driven by a clock starting at noon UTC.
+func newStore(t *testing.T, clients ...auth.Client) (*auth.KeyStore, *clock) {
+	store, err := auth.NewKeyStore(clients)
+	assert.NoError(t, err, "Unexpected error")
+	c := &clock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	store.Clock = c.Now
+	return store, c
+}
+
+// serve sends a request with the API key to a router requiring keys on '/calculate' only.
+func serve(store *auth.KeyStore, path, key string) *httptest.ResponseRecorder {
+	router := mux.NewRouter()
+	api := router.NewRoute().Subrouter()
+	api.Use(auth.Authenticator{Keys: store}.Middleware)
+	api.HandleFunc("/calculate", func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := auth.FromContext(r.Context())
+		w.Write([]byte(principal.Subject))
+	})
+	router.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {})
+
+	req := httptest.NewRequest("POST", path, nil)
+	if key != "" {
+		req.Header.Set(auth.APIKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// TestLoadKeys verifies that clients are read from YAML and JSON files and unknown keys are rejected.
+func TestLoadKeys(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "keys.yaml")
+	assert.NoError(t, os.WriteFile(yamlPath, []byte(`
+clients:
+  - name: north-warehouse
+    key_sha256: `+auth.HashKey("north-secret")+`
+    rate_limit: 5
+    daily_quota: 10000
+`), 0o600))
+	jsonPath := filepath.Join(dir, "keys.json")
+	assert.NoError(t, os.WriteFile(jsonPath, []byte(`{"clients": [{"name": "south", "key_sha256": "`+auth.HashKey("south-secret")+`"}]}`), 0o600))
+	badPath := filepath.Join(dir, "bad.yaml")
+	assert.NoError(t, os.WriteFile(badPath, []byte("clients:\n  - name: x\n    key: plain\n"), 0o600))
+
+	clients, err := auth.LoadKeys(yamlPath)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, []auth.Client{{Name: "north-warehouse", KeySHA256: auth.HashKey("north-secret"), RateLimit: 5, DailyQuota: 10000}}, clients)
+
+	clients, err = auth.LoadKeys(jsonPath)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, "south", clients[0].Name, "Incorrect client")
+
+	_, err = auth.LoadKeys(badPath)
+	assert.ErrorIs(t, err, auth.ErrInvalidKeyFile, "Expected an error for an unknown field")
+}
+
+// TestNewKeyStore_Invalid verifies that malformed and duplicate clients are rejected.
+func TestNewKeyStore_Invalid(t *testing.T) {
+	_, err := auth.NewKeyStore([]auth.Client{{Name: "plain", KeySHA256: "secret"}})
+	assert.ErrorIs(t, err, auth.ErrInvalidKeyFile, "Expected an error for a key which is not a hash")
+
+	_, err = auth.NewKeyStore([]auth.Client{
+		{Name: "a", KeySHA256: auth.HashKey("one")},
+		{Name: "b", KeySHA256: auth.HashKey("one")},
+	})
+	assert.ErrorIs(t, err, auth.ErrInvalidKeyFile, "Expected an error for a duplicate key")
+}
+
+// TestMiddleware_Authentication verifies that only known keys reach the handler, tagged with their client.
+func TestMiddleware_Authentication(t *testing.T) {
+	store, _ := newStore(t, auth.Client{Name: "north-warehouse", KeySHA256: auth.HashKey("north-secret")})
+
+	w := serve(store, "/calculate", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "Expected a missing key to be refused")
+	assert.Contains(t, w.Header().Get("WWW-Authenticate"), auth.APIKeyHeader)
+
+	w = serve(store, "/calculate", "guess")
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "Expected an unknown key to be refused")
+
+	w = serve(store, "/calculate", "north-secret")
+	assert.Equal(t, http.StatusOK, w.Code, "Expected a known key to be allowed")
+	assert.Equal(t, "north-warehouse", w.Body.String(), "Incorrect client in the context")
+
+	w = serve(store, "/healthz", "")
+	assert.Equal(t, http.StatusOK, w.Code, "Expected routes outside the subrouter to stay open")
+}
+
+// TestMiddleware_RateLimit verifies that requests beyond the burst are refused until a token is available.
+func TestMiddleware_RateLimit(t *testing.T) {
+	store, c := newStore(t, auth.Client{Name: "north-warehouse", KeySHA256: auth.HashKey("north-secret"), RateLimit: 0.5, Burst: 2})
+
+	assert.Equal(t, http.StatusOK, serve(store, "/calculate", "north-secret").Code)
+	assert.Equal(t, http.StatusOK, serve(store, "/calculate", "north-secret").Code)
+	w := serve(store, "/calculate", "north-secret")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code, "Expected the burst to be exhausted")
+	assert.Equal(t, "2", w.Header().Get("Retry-After"), "Incorrect retry delay")
+
+	c.now = c.now.Add(2 * time.Second)
+	assert.Equal(t, http.StatusOK, serve(store, "/calculate", "north-secret").Code, "Expected a token after the delay")
+}
+
+// TestMiddleware_DailyQuota verifies that the quota is refused until midnight UTC and then renewed.
+func TestMiddleware_DailyQuota(t *testing.T) {
+	store, c := newStore(t, auth.Client{Name: "north-warehouse", KeySHA256: auth.HashKey("north-secret"), DailyQuota: 2})
+
+	assert.Equal(t, http.StatusOK, serve(store, "/calculate", "north-secret").Code)
+	assert.Equal(t, http.StatusOK, serve(store, "/calculate", "north-secret").Code)
+	w := serve(store, "/calculate", "north-secret")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code, "Expected the quota to be exhausted")
+	assert.Equal(t, "43200", w.Header().Get("Retry-After"), "Expected to retry at midnight")
+
+	c.now = c.now.Add(12 * time.Hour)
+	assert.Equal(t, http.StatusOK, serve(store, "/calculate", "north-secret").Code, "Expected a new quota on the next day")
+}
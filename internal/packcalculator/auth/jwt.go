@@ -0,0 +1,339 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DefaultTenantClaim is the claim holding the tenant of a token when none is configured.
+const DefaultTenantClaim = "tenant_id"
+
+// maxJWKSBytes bounds the size of a fetched key set.
+const maxJWKSBytes = 1 << 20
+
+// fetchTimeout bounds a fetch of the key set shared by the verifications waiting for it, whatever the HTTP client.
+const fetchTimeout = 10 * time.Second
+
+// MinRefreshInterval bounds how often a key set URL is fetched again for a token signed by an unknown key.
+var MinRefreshInterval = time.Minute
+
+// MinRetryInterval bounds how often a key set URL is fetched again after a fetch failed.
+var MinRetryInterval = 5 * time.Second
+
+// ErrInvalidToken is returned for bearer tokens which fail verification.
+var ErrInvalidToken = errors.New("invalid bearer token")
+
+// ErrInvalidJWKS is returned when the key set cannot be read or holds no usable key.
+var ErrInvalidJWKS = errors.New("invalid JWKS")
+
+// signingMethods are the asymmetric algorithms accepted for tokens.
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// VerifierConfig configures how bearer tokens are verified.
+type VerifierConfig struct {
+	JWKS        string        // JWKS is the path or the http(s) URL of the JSON Web Key Set.
+	Issuer      string        // Issuer is the required "iss" claim, if set.
+	Audience    string        // Audience is the required "aud" claim, if set.
+	TenantClaim string        // TenantClaim is the claim holding the tenant; empty uses DefaultTenantClaim.
+	Leeway      time.Duration // Leeway tolerates clock skew when checking the expiry.
+	HTTPClient  *http.Client  // HTTPClient fetches a JWKS URL; nil uses a client with a 10 second timeout.
+}
+
+// Verifier verifies bearer JWTs against a JSON Web Key Set, fetching a remote set again when a token is signed
+// by a key it does not know yet.
+type Verifier struct {
+	config VerifierConfig
+	parser *jwt.Parser
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time  // fetched is when the key set was last fetched successfully.
+	failed  time.Time  // failed is when a fetch last failed.
+	pending *fetchCall // pending is the fetch of the key set in flight, if any.
+}
+
+// fetchCall is a fetch of the key set which concurrent verifications of unknown keys wait for.
+type fetchCall struct {
+	done chan struct{}
+	err  error
+}
+
+// NewVerifier reads the key set and returns a verifier for it.
+func NewVerifier(ctx context.Context, config VerifierConfig) (*Verifier, error) {
+	if config.TenantClaim == "" {
+		config.TenantClaim = DefaultTenantClaim
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	options := []jwt.ParserOption{jwt.WithValidMethods(signingMethods), jwt.WithExpirationRequired(), jwt.WithLeeway(config.Leeway)}
+	if config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(config.Issuer))
+	}
+	if config.Audience != "" {
+		options = append(options, jwt.WithAudience(config.Audience))
+	}
+
+	verifier := &Verifier{config: config, parser: jwt.NewParser(options...)}
+	if err := verifier.refresh(ctx); err != nil {
+		return nil, err
+	}
+	return verifier, nil
+}
+
+// Verify checks the signature, expiry, issuer and audience of the token and returns its principal.
+func (v *Verifier) Verify(ctx context.Context, token string) (Principal, error) {
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(token, claims, func(parsed *jwt.Token) (any, error) {
+		kid, _ := parsed.Header["kid"].(string)
+		return v.key(ctx, kid)
+	})
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	subject, _ := claims.GetSubject()
+	tenant, _ := claims[v.config.TenantClaim].(string)
+	return Principal{Subject: subject, Tenant: tenant, Scopes: tokenScopes(claims), Method: MethodBearer}, nil
+}
+
+// key returns the public key of the ID, fetching a remote key set again when the ID is unknown. The key set is
+// fetched without holding the lock, once for all the verifications waiting for it.
+func (v *Verifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	if key, ok := v.lookup(kid); ok {
+		v.mu.Unlock()
+		return key, nil
+	}
+	call := v.pending
+	if call == nil && (!isURL(v.config.JWKS) || time.Since(v.fetched) < MinRefreshInterval || time.Since(v.failed) < MinRetryInterval) {
+		v.mu.Unlock()
+		return nil, fmt.Errorf("unknown key ID %q", kid)
+	}
+	if call == nil {
+		// Fetch the key set and swap it in, waking the verifications which waited for it. The fetch serves them all,
+		// so it outlives the verification which started it and is bounded by its own timeout instead.
+		call = &fetchCall{done: make(chan struct{})}
+		v.pending = call
+		go v.fetchFor(context.WithoutCancel(ctx), call)
+	}
+	v.mu.Unlock()
+
+	// Wait for the fetch, unless the verification is canceled meanwhile.
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if call.err != nil {
+		return nil, call.err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if key, ok := v.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key ID %q", kid)
+}
+
+// fetchFor fetches the key set for the call and swaps it in. Only a successful fetch delays the next one by
+// MinRefreshInterval; a failed one is retried after MinRetryInterval.
+func (v *Verifier) fetchFor(ctx context.Context, call *fetchCall) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	started := time.Now()
+	keys, err := v.fetch(ctx)
+	v.mu.Lock()
+	if err == nil {
+		v.keys, v.fetched = keys, started
+	} else {
+		v.failed = time.Now()
+	}
+	call.err, v.pending = err, nil
+	v.mu.Unlock()
+	close(call.done)
+}
+
+// lookup returns the key of the ID; a token without an ID matches the only key of a set. The caller holds the lock.
+func (v *Verifier) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+	key, ok := v.keys[kid]
+	return key, ok
+}
+
+// refresh reads the key set.
+func (v *Verifier) refresh(ctx context.Context) error {
+	fetched := time.Now()
+	keys, err := v.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.keys, v.fetched = keys, fetched
+	return nil
+}
+
+// fetch reads the key set from its file or URL; the caller does not hold the lock.
+func (v *Verifier) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	var content []byte
+	var err error
+	if isURL(v.config.JWKS) {
+		content, err = v.download(ctx)
+	} else {
+		content, err = os.ReadFile(v.config.JWKS)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidJWKS, err)
+	}
+
+	keys, err := parseJWKS(content)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidJWKS, v.config.JWKS, err)
+	}
+	return keys, nil
+}
+
+// download fetches the key set from its URL.
+func (v *Verifier) download(ctx context.Context) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, v.config.JWKS, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := v.config.HTTPClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", v.config.JWKS, response.Status)
+	}
+	return io.ReadAll(io.LimitReader(response.Body, maxJWKSBytes))
+}
+
+// jsonWebKey is a public key of a JSON Web Key Set.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS returns the RSA and EC signing keys of the set by their ID.
+func parseJWKS(content []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(content, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, webKey := range set.Keys {
+		// Skip encryption keys and key types which cannot verify the accepted algorithms.
+		if webKey.Use != "" && webKey.Use != "sig" {
+			continue
+		}
+		var key crypto.PublicKey
+		var err error
+		switch webKey.Kty {
+		case "RSA":
+			key, err = rsaKey(webKey)
+		case "EC":
+			key, err = ecKey(webKey)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %v", webKey.Kid, err)
+		}
+		keys[webKey.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no RSA or EC signing keys")
+	}
+	return keys, nil
+}
+
+// rsaKey decodes the modulus and exponent of an RSA key.
+func rsaKey(webKey jsonWebKey) (*rsa.PublicKey, error) {
+	modulus, err := base64.RawURLEncoding.DecodeString(webKey.N)
+	if err != nil {
+		return nil, fmt.Errorf("modulus: %v", err)
+	}
+	exponent, err := base64.RawURLEncoding.DecodeString(webKey.E)
+	if err != nil || len(exponent) == 0 || len(exponent) > 4 {
+		return nil, errors.New("invalid exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(modulus), E: int(new(big.Int).SetBytes(exponent).Int64())}, nil
+}
+
+// ecKey decodes the curve and coordinates of an EC key.
+func ecKey(webKey jsonWebKey) (*ecdsa.PublicKey, error) {
+	curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+	curve, ok := curves[webKey.Crv]
+	if !ok {
+		return nil, fmt.Errorf("unsupported curve %q", webKey.Crv)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(webKey.X)
+	if err != nil {
+		return nil, fmt.Errorf("x: %v", err)
+	}
+	y, err := base64.RawURLEncoding.DecodeString(webKey.Y)
+	if err != nil {
+		return nil, fmt.Errorf("y: %v", err)
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+}
+
+// tokenScopes returns the scopes of the space-separated "scope" claim or the "scp" list.
+func tokenScopes(claims jwt.MapClaims) []string {
+	if scope, ok := claims["scope"].(string); ok {
+		return strings.Fields(scope)
+	}
+	switch scp := claims["scp"].(type) {
+	case string:
+		return strings.Fields(scp)
+	case []any:
+		var scopes []string
+		for _, item := range scp {
+			if scope, ok := item.(string); ok {
+				scopes = append(scopes, scope)
+			}
+		}
+		return scopes
+	}
+	return nil
+}
+
+// isURL reports whether the key set location is an http(s) URL rather than a file.
+func isURL(location string) bool {
+	return strings.HasPrefix(location, "https://") || strings.HasPrefix(location, "http://")
+}
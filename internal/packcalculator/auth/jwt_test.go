@@ -0,0 +1,378 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/auth"
+)
+
+// encode returns the unpadded base64url encoding of the integer.
+func encode(value *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(value.Bytes())
+}
+
+// rsaJWK returns the JSON Web Key of the RSA key.
+func rsaJWK(kid string, key *rsa.PrivateKey) map[string]string {
+	return map[string]string{"kty": "RSA", "kid": kid, "use": "sig", "n": encode(key.N), "e": encode(big.NewInt(int64(key.E)))}
+}
+
+// ecJWK returns the JSON Web Key of the EC key.
+func ecJWK(kid string, key *ecdsa.PrivateKey) map[string]string {
+	return map[string]string{"kty": "EC", "kid": kid, "crv": "P-256", "x": encode(key.X), "y": encode(key.Y)}
+}
+
+// jwks returns the JSON Web Key Set of the keys.
+func jwks(t *testing.T, keys ...map[string]string) []byte {
+	content, err := json.Marshal(map[string]any{"keys": keys})
+	assert.NoError(t, err)
+	return content
+}
+
+// sign returns a token with the claims, signed by the key with the ID.
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	assert.NoError(t, err)
+	return signed
+}
+
+// validClaims returns the claims of a token for the test issuer and audience, expiring in an hour.
+func validClaims(extra jwt.MapClaims) jwt.MapClaims {
+	claims := jwt.MapClaims{
+		"iss":       "https://id.example.com",
+		"aud":       "rpg",
+		"sub":       "user-1",
+		"tenant_id": "north",
+		"scope":     "calculate catalog:write",
+		"exp":       time.Now().Add(time.Hour).Unix(),
+	}
+	for name, value := range extra {
+		claims[name] = value
+	}
+	return claims
+}
+
+// TestVerifier_File verifies tokens against a key set read from a file.
+func TestVerifier_File(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(path, jwks(t, rsaJWK("rsa-1", rsaKey), ecJWK("ec-1", ecKey)), 0o600))
+
+	verifier, err := auth.NewVerifier(context.Background(), auth.VerifierConfig{JWKS: path, Issuer: "https://id.example.com", Audience: "rpg"})
+	assert.NoError(t, err, "Unexpected error")
+
+	// Subtest: the claims of a valid token become the principal.
+	t.Run("Valid", func(t *testing.T) {
+		principal, err := verifier.Verify(context.Background(), sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, validClaims(nil)))
+		assert.NoError(t, err, "Unexpected error")
+		assert.Equal(t, auth.Principal{Subject: "user-1", Tenant: "north", Scopes: []string{"calculate", "catalog:write"}, Method: auth.MethodBearer}, principal)
+	})
+
+	// Subtest: EC keys and scopes given as a list are accepted.
+	t.Run("EC and scp", func(t *testing.T) {
+		token := sign(t, jwt.SigningMethodES256, "ec-1", ecKey, validClaims(jwt.MapClaims{"scope": nil, "scp": []string{"admin"}}))
+		principal, err := verifier.Verify(context.Background(), token)
+		assert.NoError(t, err, "Unexpected error")
+		assert.Equal(t, []string{"admin"}, principal.Scopes, "Incorrect scopes")
+	})
+
+	// Subtest: tokens failing a check are refused.
+	invalid := map[string]string{
+		"Expired":        sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, validClaims(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()})),
+		"No expiry":      sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, validClaims(jwt.MapClaims{"exp": nil})),
+		"Wrong audience": sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, validClaims(jwt.MapClaims{"aud": "billing"})),
+		"Wrong issuer":   sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, validClaims(jwt.MapClaims{"iss": "https://evil.example.com"})),
+		"Unknown key":    sign(t, jwt.SigningMethodRS256, "rsa-2", rsaKey, validClaims(nil)),
+		"Wrong key type": sign(t, jwt.SigningMethodRS256, "ec-1", rsaKey, validClaims(nil)),
+		"Symmetric":      sign(t, jwt.SigningMethodHS256, "rsa-1", []byte("secret"), validClaims(nil)),
+		"Malformed":      "not.a.token",
+	}
+	for name, token := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := verifier.Verify(context.Background(), token)
+			assert.ErrorIs(t, err, auth.ErrInvalidToken)
+		})
+	}
+}
+
+// TestVerifier_URL verifies that a key set URL is fetched again when a token is signed by a new key.
+func TestVerifier_URL(t *testing.T) {
+	oldKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	// Serve the old key first and both keys once the new key was rolled out.
+	var fetches atomic.Int32
+	var rotated atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if rotated.Load() {
+			w.Write(jwks(t, ecJWK("old", oldKey), ecJWK("new", newKey)))
+			return
+		}
+		w.Write(jwks(t, ecJWK("old", oldKey)))
+	}))
+	defer server.Close()
+
+	previous := auth.MinRefreshInterval
+	auth.MinRefreshInterval = 0
+	defer func() { auth.MinRefreshInterval = previous }()
+
+	verifier, err := auth.NewVerifier(context.Background(), auth.VerifierConfig{JWKS: server.URL, Audience: "rpg"})
+	assert.NoError(t, err, "Unexpected error")
+
+	_, err = verifier.Verify(context.Background(), sign(t, jwt.SigningMethodES256, "old", oldKey, validClaims(nil)))
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, int32(1), fetches.Load(), "Known keys must not be fetched again")
+
+	rotated.Store(true)
+	_, err = verifier.Verify(context.Background(), sign(t, jwt.SigningMethodES256, "new", newKey, validClaims(nil)))
+	assert.NoError(t, err, "Expected the new key to be fetched")
+	assert.Equal(t, int32(2), fetches.Load(), "Expected a single fetch for the new key")
+}
+
+// TestVerifier_ConcurrentFetch verifies that verifications of a new key share a single fetch, which does not hold
+// up the verification of known keys.
+func TestVerifier_ConcurrentFetch(t *testing.T) {
+	oldKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	// Serve the old key first and hold the fetch of both keys until released.
+	var fetches atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) == 1 {
+			w.Write(jwks(t, ecJWK("old", oldKey)))
+			return
+		}
+		close(started)
+		<-release
+		w.Write(jwks(t, ecJWK("old", oldKey), ecJWK("new", newKey)))
+	}))
+	defer server.Close()
+
+	previous := auth.MinRefreshInterval
+	auth.MinRefreshInterval = 0
+	defer func() { auth.MinRefreshInterval = previous }()
+
+	verifier, err := auth.NewVerifier(context.Background(), auth.VerifierConfig{JWKS: server.URL, Audience: "rpg"})
+	assert.NoError(t, err, "Unexpected error")
+
+	token := sign(t, jwt.SigningMethodES256, "new", newKey, validClaims(nil))
+	errs := make(chan error, 10)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			_, err := verifier.Verify(context.Background(), token)
+			errs <- err
+		}()
+	}
+	<-started
+
+	_, err = verifier.Verify(context.Background(), sign(t, jwt.SigningMethodES256, "old", oldKey, validClaims(nil)))
+	assert.NoError(t, err, "Known keys must be verified while the key set is fetched")
+
+	close(release)
+	for i := 0; i < cap(errs); i++ {
+		assert.NoError(t, <-errs, "Expected the new key to be fetched")
+	}
+	assert.Equal(t, int32(2), fetches.Load(), "Expected a single fetch for the new key")
+}
+
+// TestVerifier_CanceledFetch verifies that the verification which started a fetch of the key set going away does
+// not fail the verifications waiting for the same fetch.
+func TestVerifier_CanceledFetch(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	// Serve the old key first and hold the fetch of the new key until released.
+	var fetches atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) == 1 {
+			w.Write(jwks(t, ecJWK("old", key)))
+			return
+		}
+		close(started)
+		<-release
+		w.Write(jwks(t, ecJWK("old", key), ecJWK("new", key)))
+	}))
+	defer server.Close()
+
+	previous := auth.MinRefreshInterval
+	auth.MinRefreshInterval = 0
+	defer func() { auth.MinRefreshInterval = previous }()
+
+	verifier, err := auth.NewVerifier(context.Background(), auth.VerifierConfig{JWKS: server.URL, Audience: "rpg"})
+	assert.NoError(t, err, "Unexpected error")
+	token := sign(t, jwt.SigningMethodES256, "new", key, validClaims(nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := verifier.Verify(ctx, token)
+		first <- err
+	}()
+	<-started
+	second := make(chan error, 1)
+	go func() {
+		_, err := verifier.Verify(context.Background(), token)
+		second <- err
+	}()
+
+	cancel()
+	assert.Error(t, <-first, "Expected the canceled verification to fail")
+	close(release)
+	assert.NoError(t, <-second, "Expected the waiting verification to get the fetched key")
+	assert.Equal(t, int32(2), fetches.Load(), "Expected a single fetch for the new key")
+}
+
+// TestVerifier_FailedFetch verifies that a failed fetch of the key set is retried after MinRetryInterval rather
+// than MinRefreshInterval.
+func TestVerifier_FailedFetch(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	// Serve the old key first, fail once and serve both keys afterwards.
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch fetches.Add(1) {
+		case 1:
+			w.Write(jwks(t, ecJWK("old", key)))
+		case 2:
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		default:
+			w.Write(jwks(t, ecJWK("old", key), ecJWK("new", key)))
+		}
+	}))
+	defer server.Close()
+
+	previousRefresh, previousRetry := auth.MinRefreshInterval, auth.MinRetryInterval
+	auth.MinRefreshInterval, auth.MinRetryInterval = 0, time.Hour
+	defer func() { auth.MinRefreshInterval, auth.MinRetryInterval = previousRefresh, previousRetry }()
+
+	verifier, err := auth.NewVerifier(context.Background(), auth.VerifierConfig{JWKS: server.URL, Audience: "rpg"})
+	assert.NoError(t, err, "Unexpected error")
+	token := sign(t, jwt.SigningMethodES256, "new", key, validClaims(nil))
+
+	_, err = verifier.Verify(context.Background(), token)
+	assert.ErrorIs(t, err, auth.ErrInvalidToken, "Expected the failed fetch to fail the verification")
+	_, err = verifier.Verify(context.Background(), token)
+	assert.Error(t, err, "Expected no fetch before MinRetryInterval")
+	assert.Equal(t, int32(2), fetches.Load(), "Expected the failed fetch to back off")
+
+	auth.MinRetryInterval = 0
+	_, err = verifier.Verify(context.Background(), token)
+	assert.NoError(t, err, "Expected the fetch to be retried")
+	assert.Equal(t, int32(3), fetches.Load(), "Expected a single retry")
+}
+
+// TestNewVerifier_Errors verifies that unusable key sets are reported.
+func TestNewVerifier_Errors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"keys": [{"kty": "oct", "k": "c2VjcmV0"}]}`), 0o600))
+
+	_, err := auth.NewVerifier(context.Background(), auth.VerifierConfig{JWKS: path})
+	assert.ErrorIs(t, err, auth.ErrInvalidJWKS, "Expected an error for a set without signing keys")
+
+	_, err = auth.NewVerifier(context.Background(), auth.VerifierConfig{JWKS: filepath.Join(t.TempDir(), "missing.json")})
+	assert.ErrorIs(t, err, auth.ErrInvalidJWKS, "Expected an error for a missing file")
+}
+
+// TestAuthenticator_Scopes verifies that each endpoint requires its scope and the admin scope grants all.
+func TestAuthenticator_Scopes(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(path, jwks(t, ecJWK("ec-1", key)), 0o600))
+	verifier, err := auth.NewVerifier(context.Background(), auth.VerifierConfig{JWKS: path, Audience: "rpg"})
+	assert.NoError(t, err)
+	store, err := auth.NewKeyStore([]auth.Client{{Name: "north-warehouse", KeySHA256: auth.HashKey("north-secret"), Tenant: "north"}})
+	assert.NoError(t, err)
+
+	// Route each path behind its scope, echoing the tenant of the caller.
+	authenticator := auth.Authenticator{Keys: store, Verifier: verifier}
+	router := mux.NewRouter()
+	api := router.NewRoute().Subrouter()
+	api.Use(authenticator.Middleware)
+	echo := func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(auth.Tenant(r.Context()))) }
+	for path, scope := range map[string]string{"/calculate": auth.ScopeCalculate, "/catalog": auth.ScopeCatalogWrite, "/admin": auth.ScopeAdmin} {
+		scoped := api.NewRoute().Subrouter()
+		scoped.Use(authenticator.RequireScope(scope))
+		scoped.HandleFunc(path, echo)
+	}
+
+	serve := func(path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, nil)
+		req.Header = header
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	bearer := func(scope string) http.Header {
+		return http.Header{"Authorization": {"Bearer " + sign(t, jwt.SigningMethodES256, "ec-1", key, validClaims(jwt.MapClaims{"scope": scope}))}}
+	}
+
+	w := serve("/calculate", http.Header{})
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "Expected credentials to be required")
+	assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+
+	w = serve("/calculate", http.Header{"Authorization": {"Bearer not.a.token"}})
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "Expected an invalid token to be refused")
+
+	w = serve("/calculate", bearer("calculate"))
+	assert.Equal(t, http.StatusOK, w.Code, "Expected the calculate scope to be granted")
+	assert.Equal(t, "north", w.Body.String(), "Incorrect tenant in the context")
+
+	w = serve("/catalog", bearer("calculate"))
+	assert.Equal(t, http.StatusForbidden, w.Code, "Expected the catalog:write scope to be required")
+	assert.Contains(t, w.Header().Get("WWW-Authenticate"), `scope="catalog:write"`)
+
+	for _, path := range []string{"/calculate", "/catalog", "/admin"} {
+		assert.Equal(t, http.StatusOK, serve(path, bearer("admin")).Code, "Expected the admin scope to grant %s", path)
+	}
+
+	// API keys without scopes may only calculate.
+	keyHeader := http.Header{}
+	keyHeader.Set(auth.APIKeyHeader, "north-secret")
+	unknownKeyHeader := http.Header{}
+	unknownKeyHeader.Set(auth.APIKeyHeader, "guess")
+	assert.Equal(t, http.StatusOK, serve("/calculate", keyHeader).Code, "Expected an API key to calculate")
+	assert.Equal(t, http.StatusForbidden, serve("/admin", keyHeader).Code, "Expected an API key not to administer")
+	assert.Equal(t, http.StatusUnauthorized, serve("/calculate", unknownKeyHeader).Code, "Expected an unknown key to be refused")
+}
+
+// TestAuthenticator_Disabled verifies that the API stays open without keys or a key set.
+func TestAuthenticator_Disabled(t *testing.T) {
+	authenticator := auth.Authenticator{}
+	handler := authenticator.Middleware(authenticator.RequireScope(auth.ScopeAdmin)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/calculate", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
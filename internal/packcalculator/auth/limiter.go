@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Limiter enforces a rate limit and a daily quota on requests.
+type Limiter struct {
+	rateLimit  float64
+	dailyQuota int
+
+	mu      sync.Mutex
+	limiter *rate.Limiter
+	day     time.Time
+	used    int
+}
+
+// NewLimiter returns a limiter allowing rateLimit requests per second with the burst and dailyQuota requests per
+// UTC day. A zero rate limit or quota does not limit; a zero burst allows the rate limit rounded up, at least 1.
+func NewLimiter(rateLimit float64, burst, dailyQuota int) *Limiter {
+	limiter := &Limiter{rateLimit: rateLimit, dailyQuota: dailyQuota, limiter: rate.NewLimiter(rate.Inf, 0)}
+	if rateLimit > 0 {
+		if burst == 0 {
+			burst = int(math.Max(1, math.Ceil(rateLimit)))
+		}
+		limiter.limiter = rate.NewLimiter(rate.Limit(rateLimit), burst)
+	}
+	return limiter
+}
+
+// Allow counts a request at the time against the limits. A refused request returns ErrRateLimited or
+// ErrQuotaExceeded with how long to wait before retrying.
+func (l *Limiter) Allow(now time.Time) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Start a new quota at midnight UTC.
+	day := now.UTC().Truncate(24 * time.Hour)
+	if !day.Equal(l.day) {
+		l.day, l.used = day, 0
+	}
+	if l.dailyQuota > 0 && l.used >= l.dailyQuota {
+		return day.Add(24 * time.Hour).Sub(now), fmt.Errorf("%w: %d requests per day", ErrQuotaExceeded, l.dailyQuota)
+	}
+
+	// Take a token, giving it back when the request has to wait for it.
+	reservation := l.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return delay, fmt.Errorf("%w: %g requests per second", ErrRateLimited, l.rateLimit)
+	}
+
+	l.used++
+	return 0, nil
+}
+
+// RetryAfter formats a delay as the seconds of a Retry-After header, rounded up.
+func RetryAfter(delay time.Duration) string {
+	return fmt.Sprint(int(math.Ceil(delay.Seconds())))
+}
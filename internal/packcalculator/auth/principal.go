@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"rpg/internal/packcalculator/logging"
+)
+
+// Scopes separating the endpoints.
+const (
+	ScopeCalculate    = "calculate"     // ScopeCalculate grants the calculation and analysis endpoints.
+	ScopeCatalogWrite = "catalog:write" // ScopeCatalogWrite grants changing the catalog.
+	ScopeAdmin        = "admin"         // ScopeAdmin grants the administrative endpoints.
+)
+
+// Methods a principal authenticates with.
+const (
+	MethodAPIKey = "api_key" // MethodAPIKey authenticates with the X-API-Key header.
+	MethodBearer = "bearer"  // MethodBearer authenticates with a JWT in the Authorization header.
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Subject string   // Subject is the client name of an API key or the subject of a token.
+	Tenant  string   // Tenant is the tenant the caller acts for, if any.
+	Scopes  []string // Scopes are the scopes granted to the caller.
+	Method  string   // Method is how the caller authenticated.
+}
+
+// HasScope reports whether the principal was granted the scope. The admin scope grants every scope.
+func (p Principal) HasScope(scope string) bool {
+	for _, granted := range p.Scopes {
+		if granted == scope || granted == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// contextKey keys the values this package stores in a context.
+type contextKey int
+
+const principalKey contextKey = iota
+
+// WithPrincipal returns a copy of the context carrying the principal.
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey, principal)
+}
+
+// FromContext returns the principal of the context and whether the request was authenticated.
+func FromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey).(Principal)
+	return principal, ok
+}
+
+// Tenant returns the tenant of the authenticated caller of the context, if any.
+func Tenant(ctx context.Context) string {
+	principal, _ := FromContext(ctx)
+	return principal.Tenant
+}
+
+// Authenticator authenticates requests with a bearer token or an API key. Without either the API is open.
+type Authenticator struct {
+	Keys     *KeyStore // Keys accepts API keys; nil refuses them.
+	Verifier *Verifier // Verifier accepts bearer tokens; nil refuses them.
+}
+
+// Enabled reports whether requests must authenticate.
+func (a Authenticator) Enabled() bool {
+	return a.Keys != nil || a.Verifier != nil
+}
+
+// Middleware refuses unauthenticated requests with 401 Unauthorized and API keys beyond their client's limits with
+// 429 Too Many Requests and a Retry-After header. Authenticated requests carry their principal in the context.
+func (a Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		principal, ok := a.authenticate(w, r)
+		if !ok {
+			return
+		}
+
+		// Tag the request and its log lines with the principal.
+		ctx := WithPrincipal(r.Context(), principal)
+		logger := logging.FromContext(ctx).With("client", principal.Subject)
+		next.ServeHTTP(w, r.WithContext(logging.WithLogger(ctx, logger)))
+	})
+}
+
+// authenticate returns the principal of the request, or writes the response refusing it and returns false.
+func (a Authenticator) authenticate(w http.ResponseWriter, r *http.Request) (Principal, bool) {
+	// Prefer a bearer token when one is given.
+	if token, ok := bearerToken(r); ok && a.Verifier != nil {
+		principal, err := a.Verifier.Verify(r.Context(), token)
+		if err != nil {
+			// If the token is invalid, return an Unauthorized response.
+			logging.FromContext(r.Context()).Info("Invalid bearer token", "error", err)
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return Principal{}, false
+		}
+		return principal, true
+	}
+
+	if a.Keys != nil {
+		client, retryAfter, err := a.Keys.Allow(r.Header.Get(APIKeyHeader))
+		switch {
+		case errors.Is(err, ErrRateLimited) || errors.Is(err, ErrQuotaExceeded):
+			// If the client exceeded its limits, return a Too Many Requests response saying when to retry.
+			logging.FromContext(r.Context()).Warn("Request refused", "client", client.Name, "error", err)
+			w.Header().Set("Retry-After", RetryAfter(retryAfter))
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return Principal{}, false
+		case err == nil:
+			return client.Principal(), true
+		case errors.Is(err, ErrUnknownKey) || a.Verifier == nil:
+			// If the key is missing or unknown, return an Unauthorized response.
+			w.Header().Set("WWW-Authenticate", `ApiKey header="`+APIKeyHeader+`"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return Principal{}, false
+		}
+	}
+
+	// Ask for a token when neither credential was accepted.
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	http.Error(w, "missing bearer token or API key", http.StatusUnauthorized)
+	return Principal{}, false
+}
+
+// RequireScope returns a middleware refusing authenticated requests without the scope with 403 Forbidden.
+// Requests pass unchecked when authentication is disabled.
+func (a Authenticator) RequireScope(scope string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if principal, ok := FromContext(r.Context()); a.Enabled() && (!ok || !principal.HasScope(scope)) {
+				// If the scope was not granted, return a Forbidden response naming it.
+				w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+scope+`"`)
+				http.Error(w, "missing scope "+scope, http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// bearerToken returns the token of the Authorization header, if it carries one.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
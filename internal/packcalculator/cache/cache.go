@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	"rpg/internal/packcalculator/models"
+)
+
+// Cache keeps the encoded responses of the most recently used calculations, up to its capacity.
+type Cache struct {
+	capacity int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // order lists the entries from the most to the least recently used.
+}
+
+// entry is a cached response with its key.
+type entry struct {
+	key  string
+	body []byte
+}
+
+// New returns a cache holding up to capacity responses. A capacity of zero or less caches nothing.
+func New(capacity int) *Cache {
+	return &Cache{capacity: capacity, entries: make(map[string]*list.Element), order: list.New()}
+}
+
+// Key returns the key of the tenant's calculation request. The tenant is part of the key, so a tenant never receives
+// a response computed for another, and so are the resolved pack sizes, so a changed product is computed again.
+func Key(tenant string, request models.CalculateRequest) (string, error) {
+	encoded, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	hash.Write([]byte(tenant))
+	hash.Write([]byte{0})
+	hash.Write(encoded)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Get returns the response of the key and marks it as recently used.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*entry).body, true
+}
+
+// Put stores the response of the key, evicting the least recently used response when the cache is full.
+func (c *Cache) Put(key string, body []byte) {
+	if c.capacity <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*entry).body = body
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&entry{key: key, body: body})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry).key)
+	}
+}
+
+// Len returns the number of cached responses.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,53 @@
+package cache_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/cache"
+	"rpg/internal/packcalculator/models"
+)
+
+// TestKey verifies that keys differ by tenant and by every field of the request.
+func TestKey(t *testing.T) {
+	request := models.CalculateRequest{Order: 263, PackSizes: models.NewPackSizes([]int{23, 31, 53})}
+	north, err := cache.Key("north", request)
+	assert.NoError(t, err, "Unexpected error")
+
+	again, _ := cache.Key("north", request)
+	south, _ := cache.Key("south", request)
+	request.Clamp = "safe"
+	clamped, _ := cache.Key("north", request)
+
+	assert.Equal(t, north, again, "Expected equal requests to share a key")
+	assert.NotEqual(t, north, south, "Expected tenants to have separate keys")
+	assert.NotEqual(t, north, clamped, "Expected the clamp to be part of the key")
+}
+
+// TestCache verifies that the least recently used response is evicted once the cache is full.
+func TestCache(t *testing.T) {
+	c := cache.New(2)
+	c.Put("a", []byte("1"))
+	c.Put("b", []byte("2"))
+	_, ok := c.Get("a")
+	assert.True(t, ok, "Expected a cached response")
+
+	c.Put("c", []byte("3"))
+
+	_, ok = c.Get("b")
+	assert.False(t, ok, "Expected the least recently used response to be evicted")
+	body, ok := c.Get("a")
+	assert.True(t, ok, "Expected a recently used response to be kept")
+	assert.Equal(t, []byte("1"), body, "Incorrect response")
+	assert.Equal(t, 2, c.Len(), "Incorrect size")
+}
+
+// TestCache_Disabled verifies that a cache without capacity keeps nothing.
+func TestCache_Disabled(t *testing.T) {
+	c := cache.New(0)
+	c.Put("a", []byte("1"))
+
+	_, ok := c.Get("a")
+	assert.False(t, ok, "Expected nothing to be cached")
+}
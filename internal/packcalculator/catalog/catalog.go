@@ -0,0 +1,206 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-playground/validator"
+
+	"rpg/internal/packcalculator/models"
+)
+
+// Errors returned by the catalog.
+var (
+	ErrProductNotFound = errors.New("product not found")
+	ErrInvalidProduct  = errors.New("invalid product")
+	ErrCatalogFull     = errors.New("catalog full")
+)
+
+// Product is a stock-keeping unit with the pack sizes it ships in, counted in pieces.
+type Product struct {
+	SKU       string            `json:"sku" validate:"required,max=64,printascii"`
+	Name      string            `json:"name,omitempty" validate:"max=256"`
+	PackSizes []models.PackSize `json:"pack_sizes" validate:"required,min=1,dive"`
+}
+
+// Store holds a separate catalog of products for each tenant. With a directory each catalog is persisted to its
+// own file, named after the tenant, so no tenant can read or overwrite another's products.
+type Store struct {
+	dir string
+
+	mu       sync.RWMutex
+	catalogs map[string]map[string]Product
+}
+
+// NewStore returns a store keeping the catalogs in memory.
+func NewStore() *Store {
+	return &Store{catalogs: make(map[string]map[string]Product)}
+}
+
+// Open returns a store persisting the catalogs in the directory, creating it if needed and loading the catalogs
+// it already holds.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	store := NewStore()
+	store.dir = dir
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var products []Product
+		if err := json.Unmarshal(content, &products); err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		catalog := make(map[string]Product, len(products))
+		for _, product := range products {
+			catalog[product.SKU] = product
+		}
+		store.catalogs[strings.TrimSuffix(filepath.Base(file), ".json")] = catalog
+	}
+	return store, nil
+}
+
+// Check reports whether the directory of the store can still be read, so readiness fails when it is lost. A store
+// in memory is always ready.
+func (s *Store) Check(ctx context.Context) error {
+	if s.dir == "" {
+		return nil
+	}
+	if _, err := os.ReadDir(s.dir); err != nil {
+		return fmt.Errorf("catalog directory: %w", err)
+	}
+	return nil
+}
+
+// List returns the products of the tenant ordered by SKU.
+func (s *Store) List(tenant string) []Product {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedProducts(s.catalogs[tenant])
+}
+
+// Get returns the product of the tenant with the SKU.
+func (s *Store) Get(tenant, sku string) (Product, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	product, ok := s.catalogs[tenant][sku]
+	if !ok {
+		return Product{}, fmt.Errorf("%w: %q", ErrProductNotFound, sku)
+	}
+	return product, nil
+}
+
+// Put validates the product and creates or replaces it in the tenant's catalog, refusing a new product once the
+// catalog holds maxProducts; zero does not limit. It reports whether the product was created.
+func (s *Store) Put(tenant string, product Product, maxProducts int) (bool, error) {
+	if err := validate(product); err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	catalog := s.catalogs[tenant]
+	_, exists := catalog[product.SKU]
+	if !exists && maxProducts > 0 && len(catalog) >= maxProducts {
+		return false, fmt.Errorf("%w: at most %d products", ErrCatalogFull, maxProducts)
+	}
+
+	// Write a copy so that the stored catalog only changes once it was persisted.
+	updated := make(map[string]Product, len(catalog)+1)
+	for sku, existing := range catalog {
+		updated[sku] = existing
+	}
+	updated[product.SKU] = product
+	if err := s.save(tenant, updated); err != nil {
+		return false, err
+	}
+	s.catalogs[tenant] = updated
+	return !exists, nil
+}
+
+// Delete removes the product with the SKU from the tenant's catalog.
+func (s *Store) Delete(tenant, sku string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	catalog := s.catalogs[tenant]
+	if _, ok := catalog[sku]; !ok {
+		return fmt.Errorf("%w: %q", ErrProductNotFound, sku)
+	}
+
+	updated := make(map[string]Product, len(catalog))
+	for existing, product := range catalog {
+		if existing != sku {
+			updated[existing] = product
+		}
+	}
+	if err := s.save(tenant, updated); err != nil {
+		return err
+	}
+	s.catalogs[tenant] = updated
+	return nil
+}
+
+// save writes the tenant's catalog to its file, replacing the previous file atomically; the caller holds the lock.
+func (s *Store) save(tenant string, catalog map[string]Product) error {
+	if s.dir == "" {
+		return nil
+	}
+	content, err := json.MarshalIndent(sortedProducts(catalog), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(s.dir, tenant+".json")
+	temporary, err := os.CreateTemp(s.dir, tenant+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporary.Name())
+	if _, err := temporary.Write(content); err != nil {
+		temporary.Close()
+		return err
+	}
+	if err := temporary.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temporary.Name(), path)
+}
+
+// validate checks the product, whose pack sizes must be counted in pieces.
+func validate(product Product) error {
+	// Validate the input using the validator package.
+	if err := validator.New().Struct(product); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProduct, err)
+	}
+	for _, packSize := range product.PackSizes {
+		if packSize.Unit != models.UnitPiece {
+			return fmt.Errorf("%w: pack size %d has unit %q, catalog pack sizes are counted in pieces", ErrInvalidProduct, packSize.Size, packSize.Unit)
+		}
+	}
+	return nil
+}
+
+// sortedProducts returns the products of the catalog ordered by SKU.
+func sortedProducts(catalog map[string]Product) []Product {
+	products := make([]Product, 0, len(catalog))
+	for _, product := range catalog {
+		products = append(products, product)
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].SKU < products[j].SKU })
+	return products
+}
@@ -0,0 +1,105 @@
+package catalog_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/catalog"
+	"rpg/internal/packcalculator/health"
+	"rpg/internal/packcalculator/models"
+)
+
+// widget is a product with three pack sizes.
+var widget = catalog.Product{SKU: "WIDGET-1", Name: "Widget", PackSizes: models.NewPackSizes([]int{23, 31, 53})}
+
+// TestStore_Isolation verifies that every tenant sees and changes only its own catalog.
+func TestStore_Isolation(t *testing.T) {
+	store := catalog.NewStore()
+
+	created, err := store.Put("north", widget, 0)
+	assert.NoError(t, err, "Unexpected error")
+	assert.True(t, created, "Expected the product to be created")
+
+	_, err = store.Get("south", widget.SKU)
+	assert.ErrorIs(t, err, catalog.ErrProductNotFound, "Expected another tenant not to see the product")
+	assert.Empty(t, store.List("south"), "Expected another tenant's catalog to be empty")
+	assert.ErrorIs(t, store.Delete("south", widget.SKU), catalog.ErrProductNotFound, "Expected another tenant not to delete the product")
+
+	product, err := store.Get("north", widget.SKU)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, widget, product, "Incorrect product")
+
+	created, err = store.Put("north", widget, 0)
+	assert.NoError(t, err, "Unexpected error")
+	assert.False(t, created, "Expected the product to be replaced")
+
+	assert.NoError(t, store.Delete("north", widget.SKU), "Unexpected error")
+	assert.Empty(t, store.List("north"), "Expected the product to be deleted")
+}
+
+// TestStore_Put verifies that invalid products and products beyond the tenant's maximum are refused.
+func TestStore_Put(t *testing.T) {
+	store := catalog.NewStore()
+
+	_, err := store.Put("north", catalog.Product{SKU: "EMPTY"}, 0)
+	assert.ErrorIs(t, err, catalog.ErrInvalidProduct, "Expected an error for a product without pack sizes")
+
+	_, err = store.Put("north", catalog.Product{SKU: "FLOUR", PackSizes: []models.PackSize{{Size: 25, Unit: models.UnitKilogram}}}, 0)
+	assert.ErrorIs(t, err, catalog.ErrInvalidProduct, "Expected an error for a pack size which is not in pieces")
+
+	_, err = store.Put("north", widget, 1)
+	assert.NoError(t, err, "Unexpected error")
+	_, err = store.Put("north", widget, 1)
+	assert.NoError(t, err, "Expected a full catalog to replace its products")
+	_, err = store.Put("north", catalog.Product{SKU: "GADGET", PackSizes: models.NewPackSizes([]int{5})}, 1)
+	assert.ErrorIs(t, err, catalog.ErrCatalogFull, "Expected a full catalog to refuse new products")
+}
+
+// TestOpen verifies that catalogs are persisted to one file per tenant and loaded again.
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	store, err := catalog.Open(dir)
+	assert.NoError(t, err, "Unexpected error")
+	_, err = store.Put("north", widget, 0)
+	assert.NoError(t, err, "Unexpected error")
+	_, err = store.Put("south", catalog.Product{SKU: "GADGET", PackSizes: models.NewPackSizes([]int{5})}, 0)
+	assert.NoError(t, err, "Unexpected error")
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	assert.ElementsMatch(t, []string{filepath.Join(dir, "north.json"), filepath.Join(dir, "south.json")}, files, "Expected one file per tenant")
+
+	reopened, err := catalog.Open(dir)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, []catalog.Product{widget}, reopened.List("north"), "Incorrect catalog after reopening")
+	assert.Len(t, reopened.List("south"), 1, "Incorrect catalog after reopening")
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0o600))
+	_, err = catalog.Open(dir)
+	assert.Error(t, err, "Expected an error for a corrupt catalog")
+}
+
+// TestStore_Check verifies that readiness fails once the catalog directory cannot be read.
+func TestStore_Check(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "catalog")
+	store, err := catalog.Open(dir)
+	assert.NoError(t, err, "Unexpected error")
+	checker := health.NewChecker()
+	checker.Register("catalog", store.Check)
+	readiness := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		checker.ReadinessHandler(w, httptest.NewRequest("GET", "/readyz", nil))
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, readiness().Code, "Expected ready with a readable directory")
+
+	assert.NoError(t, os.RemoveAll(dir))
+	w := readiness()
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "Expected unavailable without the directory")
+	assert.Contains(t, w.Body.String(), "catalog directory", "Expected the failing check to be reported")
+}
@@ -0,0 +1,563 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator"
+	"gopkg.in/yaml.v3"
+
+	"rpg/internal/packcalculator/auth"
+	"rpg/internal/packcalculator/idempotency"
+	"rpg/internal/packcalculator/jobs"
+	"rpg/internal/packcalculator/logging"
+	"rpg/internal/packcalculator/ratelimit"
+	"rpg/internal/packcalculator/server"
+	"rpg/internal/packcalculator/services"
+	"rpg/internal/packcalculator/tenant"
+	"rpg/internal/packcalculator/webhook"
+)
+
+// ErrInvalidConfig is returned when the configuration cannot be read or fails validation.
+var ErrInvalidConfig = errors.New("invalid configuration")
+
+// Duration is a time.Duration written as a Go duration string such as "30s" in files and environment variables.
+type Duration time.Duration
+
+// MarshalText writes the duration as a Go duration string.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText parses a Go duration string.
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Config is the configuration of the server.
+type Config struct {
+	Server  Server  `json:"server" yaml:"server"`
+	CORS    CORS    `json:"cors" yaml:"cors"`
+	Solver  Solver  `json:"solver" yaml:"solver"`
+	Limits  Limits  `json:"limits" yaml:"limits"`
+	Logging Logging `json:"logging" yaml:"logging"`
+	Tracing Tracing `json:"tracing" yaml:"tracing"`
+	Auth    Auth    `json:"auth" yaml:"auth"`
+	Catalog Catalog `json:"catalog" yaml:"catalog"`
+
+	Cache     Cache     `json:"cache" yaml:"cache"`
+	RateLimit RateLimit `json:"rate_limit" yaml:"rate_limit"`
+	Audit     Audit     `json:"audit" yaml:"audit"`
+	History   History   `json:"history" yaml:"history"`
+	Jobs      Jobs      `json:"jobs" yaml:"jobs"`
+	Webhooks  Webhooks  `json:"webhooks" yaml:"webhooks"`
+
+	Idempotency Idempotency `json:"idempotency" yaml:"idempotency"`
+
+	Tenants []tenant.Settings `json:"tenants" yaml:"tenants" validate:"dive"` // Tenants are the tenants besides the default tenant.
+}
+
+// Server configures the listener, timeouts and shutdown of the HTTP server.
+type Server struct {
+	Port              int      `json:"port" yaml:"port" validate:"gte=1,lte=65535"`
+	ReadTimeout       Duration `json:"read_timeout" yaml:"read_timeout" validate:"gt=0"`
+	ReadHeaderTimeout Duration `json:"read_header_timeout" yaml:"read_header_timeout" validate:"gt=0"`
+	WriteTimeout      Duration `json:"write_timeout" yaml:"write_timeout" validate:"gt=0"`
+	IdleTimeout       Duration `json:"idle_timeout" yaml:"idle_timeout" validate:"gt=0"`
+	ShutdownDelay     Duration `json:"shutdown_delay" yaml:"shutdown_delay" validate:"gte=0"`
+	ShutdownGrace     Duration `json:"shutdown_grace" yaml:"shutdown_grace" validate:"gt=0"`
+}
+
+// CORS configures which browser origins may call the API.
+type CORS struct {
+	AllowedOrigins []string `json:"allowed_origins" yaml:"allowed_origins" validate:"required,min=1,dive,required"`
+	AllowedMethods []string `json:"allowed_methods" yaml:"allowed_methods" validate:"required,min=1,dive,required"`
+	AllowedHeaders []string `json:"allowed_headers" yaml:"allowed_headers" validate:"dive,required"`
+}
+
+// Solver configures the defaults of the calculator.
+type Solver struct {
+	HeadroomMultiplier int    `json:"headroom_multiplier" yaml:"headroom_multiplier" validate:"gte=1"`
+	Clamp              string `json:"clamp" yaml:"clamp" validate:"oneof=fixed safe off"`
+	MaxNodes           int    `json:"max_nodes" yaml:"max_nodes" validate:"gte=1"`
+	MaxParcels         int    `json:"max_parcels" yaml:"max_parcels" validate:"gte=1"`
+}
+
+// Limits bounds the requests the server accepts.
+type Limits struct {
+	MaxBodyBytes              int64 `json:"max_body_bytes" yaml:"max_body_bytes" validate:"gte=1"`
+	MaxSimulationCalculations int   `json:"max_simulation_calculations" yaml:"max_simulation_calculations" validate:"gte=0"` // MaxSimulationCalculations bounds the distinct orders times the scenarios of a simulation; zero does not bound them.
+}
+
+// Logging configures the structured logs.
+type Logging struct {
+	Level string `json:"level" yaml:"level"`
+}
+
+// Tracing configures the export of traces.
+type Tracing struct {
+	OTLPEndpoint string `json:"otlp_endpoint" yaml:"otlp_endpoint" validate:"omitempty,url"`
+}
+
+// Auth configures how clients authenticate. Without API keys or a JWKS the API is open.
+type Auth struct {
+	APIKeysFile string   `json:"api_keys_file" yaml:"api_keys_file" validate:"omitempty,file"` // APIKeysFile lists the clients with API keys.
+	JWKS        string   `json:"jwks" yaml:"jwks" validate:"omitempty,url|file"`               // JWKS is the path or URL of the keys signing bearer tokens.
+	Issuer      string   `json:"issuer" yaml:"issuer"`                                         // Issuer is the required issuer of bearer tokens.
+	Audience    string   `json:"audience" yaml:"audience" validate:"required_with=JWKS"`       // Audience is the required audience of bearer tokens.
+	TenantClaim string   `json:"tenant_claim" yaml:"tenant_claim" validate:"required"`         // TenantClaim is the claim holding the tenant of a bearer token.
+	Leeway      Duration `json:"leeway" yaml:"leeway" validate:"gte=0"`                        // Leeway tolerates clock skew when checking the expiry of bearer tokens.
+}
+
+// Catalog configures where the product catalogs are kept.
+type Catalog struct {
+	Dir string `json:"dir" yaml:"dir"` // Dir persists one catalog file per tenant; empty keeps the catalogs in memory.
+}
+
+// Cache configures the result cache of '/calculate'.
+type Cache struct {
+	Size int `json:"size" yaml:"size" validate:"gte=0"` // Size is the number of responses kept; zero disables the cache.
+}
+
+// RateLimit configures the budgets of each client of '/calculate', identified by its credentials or its IP address.
+type RateLimit struct {
+	Requests       Budget   `json:"requests" yaml:"requests"`                                    // Requests is charged for every request, including cache hits.
+	Computations   Budget   `json:"computations" yaml:"computations"`                            // Computations is charged in addition for every fresh calculation.
+	TrustedProxies []string `json:"trusted_proxies" yaml:"trusted_proxies" validate:"dive,cidr"` // TrustedProxies are the CIDRs of the proxies setting X-Forwarded-For.
+}
+
+// Budget is a token bucket refilled at Rate tokens per second up to Burst tokens. A zero rate does not limit.
+type Budget struct {
+	Rate  float64 `json:"rate" yaml:"rate" validate:"gte=0"`
+	Burst int     `json:"burst" yaml:"burst" validate:"gte=0"`
+}
+
+// Audit configures the audit log of '/calculate'.
+type Audit struct {
+	File       string `json:"file" yaml:"file"`                                // File receives one JSON line per request; empty disables the audit log.
+	MaxBytes   int64  `json:"max_bytes" yaml:"max_bytes" validate:"gte=0"`     // MaxBytes is the size after which the file is rotated; zero never rotates.
+	MaxBackups int    `json:"max_backups" yaml:"max_backups" validate:"gte=0"` // MaxBackups is the number of rotated files kept; at least 1 when files rotate.
+
+	SyncInterval Duration `json:"sync_interval" yaml:"sync_interval" validate:"gte=0"` // SyncInterval batches the flushes to disk; zero flushes every record.
+}
+
+// History configures the calculation history served by '/calculations'.
+type History struct {
+	Size int `json:"size" yaml:"size" validate:"gte=0"` // Size is the number of recent calculations kept; zero disables the history.
+}
+
+// Jobs configures the worker pool of '/jobs'.
+type Jobs struct {
+	Workers   int      `json:"workers" yaml:"workers" validate:"gte=0"`       // Workers is the number of jobs calculated at once; zero disables jobs.
+	QueueSize int      `json:"queue_size" yaml:"queue_size" validate:"gte=0"` // QueueSize is the number of jobs waiting for a worker.
+	Retention Duration `json:"retention" yaml:"retention" validate:"gte=0"`   // Retention is how long finished jobs can be read.
+}
+
+// Webhooks configures the callbacks notified of finished jobs.
+type Webhooks struct {
+	MaxAttempts    int      `json:"max_attempts" yaml:"max_attempts" validate:"gte=1"`           // MaxAttempts is the number of attempts before a delivery is dead-lettered.
+	Backoff        Duration `json:"backoff" yaml:"backoff" validate:"gte=0"`                     // Backoff is the delay before the first retry, doubled for every further retry.
+	MaxBackoff     Duration `json:"max_backoff" yaml:"max_backoff" validate:"gte=0"`             // MaxBackoff bounds the delay between retries.
+	Timeout        Duration `json:"timeout" yaml:"timeout" validate:"gt=0"`                      // Timeout bounds each attempt.
+	Concurrency    int      `json:"concurrency" yaml:"concurrency" validate:"gte=1"`             // Concurrency is the number of attempts made at once.
+	MaxPending     int      `json:"max_pending" yaml:"max_pending" validate:"gte=0"`             // MaxPending is the number of deliveries in progress; zero does not bound it.
+	AllowedHosts   []string `json:"allowed_hosts" yaml:"allowed_hosts" validate:"dive,required"` // AllowedHosts are the hosts callbacks may be sent to; none refuses callbacks.
+	DeadLetterFile string   `json:"dead_letter_file" yaml:"dead_letter_file"`                    // DeadLetterFile receives a JSON line per failed delivery; empty only logs them.
+}
+
+// Idempotency configures how long the responses of requests with an Idempotency-Key header are replayed.
+type Idempotency struct {
+	TTL              Duration `json:"ttl" yaml:"ttl" validate:"gt=0"`                                  // TTL is how long a response is replayed for its key.
+	MaxKeys          int      `json:"max_keys" yaml:"max_keys" validate:"gte=0"`                       // MaxKeys is the number of responses kept; zero ignores the header.
+	MaxKeysPerClient int      `json:"max_keys_per_client" yaml:"max_keys_per_client" validate:"gte=0"` // MaxKeysPerClient is the number of responses kept for each tenant and client; zero does not limit it.
+	MaxBodyBytes     int      `json:"max_body_bytes" yaml:"max_body_bytes" validate:"gte=0"`           // MaxBodyBytes is the size of the largest response kept; zero does not limit it.
+}
+
+// Default returns the configuration used when nothing else is configured.
+func Default() Config {
+	defaults := server.DefaultConfig()
+	return Config{
+		Server: Server{
+			Port:              8080,
+			ReadTimeout:       Duration(defaults.ReadTimeout),
+			ReadHeaderTimeout: Duration(defaults.ReadHeaderTimeout),
+			WriteTimeout:      Duration(defaults.WriteTimeout),
+			IdleTimeout:       Duration(defaults.IdleTimeout),
+			ShutdownDelay:     Duration(defaults.ShutdownDelay),
+			ShutdownGrace:     Duration(defaults.ShutdownGrace),
+		},
+		CORS: CORS{
+			AllowedOrigins: []string{"*"},
+			AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "HEAD"},
+			AllowedHeaders: []string{"Origin", "Accept", "Content-Type", "X-Requested-With", logging.RequestIDHeader, auth.APIKeyHeader, "Authorization", tenant.Header, idempotency.KeyHeader},
+		},
+		Solver:  Solver{HeadroomMultiplier: services.DefaultHeadroomMultiplier, Clamp: services.ClampFixed, MaxNodes: services.DefaultMaxNodes, MaxParcels: services.DefaultMaxParcels},
+		Limits:  Limits{MaxBodyBytes: 1 << 20, MaxSimulationCalculations: 1000},
+		Logging: Logging{Level: "info"},
+		Auth:    Auth{TenantClaim: auth.DefaultTenantClaim, Leeway: Duration(30 * time.Second)},
+		Cache:   Cache{Size: 10000},
+		RateLimit: RateLimit{
+			Requests:       Budget{Rate: 50, Burst: 100},
+			Computations:   Budget{Rate: 10, Burst: 20},
+			TrustedProxies: []string{},
+		},
+		Audit:   Audit{MaxBytes: 100 << 20, MaxBackups: 10, SyncInterval: Duration(time.Second)},
+		History: History{Size: 10000},
+		Jobs:    Jobs{Workers: 4, QueueSize: 100, Retention: Duration(time.Hour)},
+		Webhooks: Webhooks{
+			MaxAttempts:  6,
+			Backoff:      Duration(time.Second),
+			MaxBackoff:   Duration(5 * time.Minute),
+			Timeout:      Duration(10 * time.Second),
+			Concurrency:  8,
+			MaxPending:   1000,
+			AllowedHosts: []string{},
+		},
+		Idempotency: Idempotency{TTL: Duration(24 * time.Hour), MaxKeys: 10000, MaxKeysPerClient: 1000, MaxBodyBytes: 64 << 10},
+		Tenants:     []tenant.Settings{},
+	}
+}
+
+// Load returns the defaults overridden by the file, if a path is given, and then by the environment.
+// Files ending in ".json" are read as JSON and any other file as YAML; unknown keys are rejected.
+func Load(path string, getenv func(string) string) (Config, error) {
+	config := Default()
+	if path != "" {
+		if err := config.readFile(path); err != nil {
+			return Config{}, err
+		}
+	}
+	if err := config.applyEnv(getenv); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// readFile overrides the configuration with the settings of the file.
+func (c *Config) readFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(c)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		if err = decoder.Decode(c); errors.Is(err, io.EOF) {
+			// An empty file keeps the defaults.
+			err = nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidConfig, path, err)
+	}
+	return nil
+}
+
+// envSetting overrides a setting from an environment variable.
+type envSetting struct {
+	name string
+	set  func(value string) error
+}
+
+// applyEnv overrides the configuration with the environment variables which are set.
+func (c *Config) applyEnv(getenv func(string) string) error {
+	settings := []envSetting{
+		{"RPG_BACKEND_PORT", intSetter(&c.Server.Port)},
+		{"RPG_READ_TIMEOUT", c.Server.ReadTimeout.set},
+		{"RPG_READ_HEADER_TIMEOUT", c.Server.ReadHeaderTimeout.set},
+		{"RPG_WRITE_TIMEOUT", c.Server.WriteTimeout.set},
+		{"RPG_IDLE_TIMEOUT", c.Server.IdleTimeout.set},
+		{"RPG_SHUTDOWN_DELAY", c.Server.ShutdownDelay.set},
+		{"RPG_SHUTDOWN_GRACE", c.Server.ShutdownGrace.set},
+		{"RPG_CORS_ORIGINS", listSetter(&c.CORS.AllowedOrigins)},
+		{"RPG_HEADROOM_MULTIPLIER", intSetter(&c.Solver.HeadroomMultiplier)},
+		{"RPG_CLAMP", stringSetter(&c.Solver.Clamp)},
+		{"RPG_MAX_NODES", intSetter(&c.Solver.MaxNodes)},
+		{"RPG_MAX_PARCELS", intSetter(&c.Solver.MaxParcels)},
+		{"RPG_MAX_BODY_BYTES", int64Setter(&c.Limits.MaxBodyBytes)},
+		{"RPG_MAX_SIMULATION_CALCULATIONS", intSetter(&c.Limits.MaxSimulationCalculations)},
+		{"RPG_LOG_LEVEL", stringSetter(&c.Logging.Level)},
+		{"RPG_OTLP_ENDPOINT", stringSetter(&c.Tracing.OTLPEndpoint)},
+		{"RPG_API_KEYS_FILE", stringSetter(&c.Auth.APIKeysFile)},
+		{"RPG_JWKS", stringSetter(&c.Auth.JWKS)},
+		{"RPG_JWT_ISSUER", stringSetter(&c.Auth.Issuer)},
+		{"RPG_JWT_AUDIENCE", stringSetter(&c.Auth.Audience)},
+		{"RPG_JWT_TENANT_CLAIM", stringSetter(&c.Auth.TenantClaim)},
+		{"RPG_CATALOG_DIR", stringSetter(&c.Catalog.Dir)},
+		{"RPG_CACHE_SIZE", intSetter(&c.Cache.Size)},
+		{"RPG_RATE_LIMIT", floatSetter(&c.RateLimit.Requests.Rate)},
+		{"RPG_TRUSTED_PROXIES", listSetter(&c.RateLimit.TrustedProxies)},
+		{"RPG_COMPUTE_RATE_LIMIT", floatSetter(&c.RateLimit.Computations.Rate)},
+		{"RPG_AUDIT_FILE", stringSetter(&c.Audit.File)},
+		{"RPG_AUDIT_SYNC_INTERVAL", c.Audit.SyncInterval.set},
+		{"RPG_HISTORY_SIZE", intSetter(&c.History.Size)},
+		{"RPG_JOB_WORKERS", intSetter(&c.Jobs.Workers)},
+		{"RPG_JOB_QUEUE_SIZE", intSetter(&c.Jobs.QueueSize)},
+		{"RPG_WEBHOOK_ALLOWED_HOSTS", listSetter(&c.Webhooks.AllowedHosts)},
+		{"RPG_WEBHOOK_DEAD_LETTER_FILE", stringSetter(&c.Webhooks.DeadLetterFile)},
+	}
+	for _, setting := range settings {
+		value := getenv(setting.name)
+		if value == "" {
+			continue
+		}
+		if err := setting.set(value); err != nil {
+			return fmt.Errorf("%w: %s=%q: %v", ErrInvalidConfig, setting.name, value, err)
+		}
+	}
+	return nil
+}
+
+// Set overrides a setting by its dotted name, such as "server.port", as given on the command line.
+func (c *Config) Set(name, value string) error {
+	setters := map[string]func(string) error{
+		"server.port":                  intSetter(&c.Server.Port),
+		"cors.allowed_origins":         listSetter(&c.CORS.AllowedOrigins),
+		"solver.headroom_multiplier":   intSetter(&c.Solver.HeadroomMultiplier),
+		"solver.clamp":                 stringSetter(&c.Solver.Clamp),
+		"solver.max_nodes":             intSetter(&c.Solver.MaxNodes),
+		"solver.max_parcels":           intSetter(&c.Solver.MaxParcels),
+		"logging.level":                stringSetter(&c.Logging.Level),
+		"tracing.otlp_endpoint":        stringSetter(&c.Tracing.OTLPEndpoint),
+		"auth.api_keys_file":           stringSetter(&c.Auth.APIKeysFile),
+		"auth.jwks":                    stringSetter(&c.Auth.JWKS),
+		"auth.issuer":                  stringSetter(&c.Auth.Issuer),
+		"auth.audience":                stringSetter(&c.Auth.Audience),
+		"catalog.dir":                  stringSetter(&c.Catalog.Dir),
+		"cache.size":                   intSetter(&c.Cache.Size),
+		"rate_limit.requests.rate":     floatSetter(&c.RateLimit.Requests.Rate),
+		"rate_limit.computations.rate": floatSetter(&c.RateLimit.Computations.Rate),
+		"rate_limit.trusted_proxies":   listSetter(&c.RateLimit.TrustedProxies),
+		"audit.file":                   stringSetter(&c.Audit.File),
+		"audit.sync_interval":          c.Audit.SyncInterval.set,
+		"history.size":                 intSetter(&c.History.Size),
+		"jobs.workers":                 intSetter(&c.Jobs.Workers),
+		"jobs.queue_size":              intSetter(&c.Jobs.QueueSize),
+		"webhooks.allowed_hosts":       listSetter(&c.Webhooks.AllowedHosts),
+		"webhooks.dead_letter_file":    stringSetter(&c.Webhooks.DeadLetterFile),
+	}
+	set, ok := setters[name]
+	if !ok {
+		return fmt.Errorf("%w: unknown setting %q", ErrInvalidConfig, name)
+	}
+	if err := set(value); err != nil {
+		return fmt.Errorf("%w: %s=%q: %v", ErrInvalidConfig, name, value, err)
+	}
+	return nil
+}
+
+// Validate checks every setting and describes all invalid ones by their name in the file.
+func (c Config) Validate() error {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		return strings.Split(field.Tag.Get("yaml"), ",")[0]
+	})
+
+	var problems []string
+	if err := validate.Struct(c); err != nil {
+		for _, fieldError := range err.(validator.ValidationErrors) {
+			// Drop the name of the root struct from the namespace, keeping e.g. "server.port".
+			name := fieldError.Namespace()[strings.Index(fieldError.Namespace(), ".")+1:]
+			rule := fieldError.Tag()
+			if fieldError.Param() != "" {
+				rule += "=" + fieldError.Param()
+			}
+			problems = append(problems, fmt.Sprintf("%s must satisfy %s, got %v", name, rule, fieldError.Value()))
+		}
+	}
+	if _, err := logging.ParseLevel(c.Logging.Level); err != nil {
+		problems = append(problems, fmt.Sprintf("logging.level must be debug, info, warn or error, got %q", c.Logging.Level))
+	}
+	// A rotated file without backups would be deleted, losing every record it held.
+	if (c.Audit.File != "" || c.Webhooks.DeadLetterFile != "") && c.Audit.MaxBytes > 0 && c.Audit.MaxBackups < 1 {
+		problems = append(problems, fmt.Sprintf("audit.max_backups must be at least 1 when files rotate, got %d", c.Audit.MaxBackups))
+	}
+	// Check the tenant IDs once every field is valid, so field problems are not reported twice.
+	if _, err := tenant.NewRegistry(c.Tenants); err != nil && len(problems) == 0 {
+		problems = append(problems, "tenants: "+err.Error())
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidConfig, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// ServerConfig returns the settings of the HTTP server.
+func (c Config) ServerConfig() server.Config {
+	return server.Config{
+		Addr:              ":" + strconv.Itoa(c.Server.Port),
+		ReadTimeout:       time.Duration(c.Server.ReadTimeout),
+		ReadHeaderTimeout: time.Duration(c.Server.ReadHeaderTimeout),
+		WriteTimeout:      time.Duration(c.Server.WriteTimeout),
+		IdleTimeout:       time.Duration(c.Server.IdleTimeout),
+		ShutdownDelay:     time.Duration(c.Server.ShutdownDelay),
+		ShutdownGrace:     time.Duration(c.Server.ShutdownGrace),
+	}
+}
+
+// VerifierConfig returns the settings verifying bearer tokens.
+func (c Config) VerifierConfig() auth.VerifierConfig {
+	return auth.VerifierConfig{
+		JWKS:        c.Auth.JWKS,
+		Issuer:      c.Auth.Issuer,
+		Audience:    c.Auth.Audience,
+		TenantClaim: c.Auth.TenantClaim,
+		Leeway:      time.Duration(c.Auth.Leeway),
+	}
+}
+
+// SolverConfig returns the settings of the calculations of orders.
+func (c Config) SolverConfig() services.Solver {
+	return services.Solver{Clamp: c.Solver.Clamp, HeadroomMultiplier: c.Solver.HeadroomMultiplier, MaxNodes: c.Solver.MaxNodes, MaxParcels: c.Solver.MaxParcels}
+}
+
+// RateLimitConfig returns the budgets of the rate limiter and the proxies it trusts.
+func (c Config) RateLimitConfig() ratelimit.Config {
+	var proxies []netip.Prefix
+	for _, cidr := range c.RateLimit.TrustedProxies {
+		if prefix, err := netip.ParsePrefix(cidr); err == nil {
+			proxies = append(proxies, prefix.Masked())
+		}
+	}
+	return ratelimit.Config{
+		Requests:       ratelimit.Budget{Rate: c.RateLimit.Requests.Rate, Burst: c.RateLimit.Requests.Burst},
+		Computations:   ratelimit.Budget{Rate: c.RateLimit.Computations.Rate, Burst: c.RateLimit.Computations.Burst},
+		TrustedProxies: proxies,
+	}
+}
+
+// JobsConfig returns the settings of the job queue.
+func (c Config) JobsConfig() jobs.Config {
+	return jobs.Config{Workers: c.Jobs.Workers, QueueSize: c.Jobs.QueueSize, Retention: time.Duration(c.Jobs.Retention)}
+}
+
+// WebhookConfig returns the delivery settings of the webhook dispatcher, without its dead letter writer.
+func (c Config) WebhookConfig() webhook.Config {
+	secrets := make(map[string]string, len(c.Tenants))
+	for _, settings := range c.Tenants {
+		if settings.WebhookSecret != "" {
+			secrets[settings.ID] = settings.WebhookSecret
+		}
+	}
+	return webhook.Config{
+		Secrets:      secrets,
+		MaxAttempts:  c.Webhooks.MaxAttempts,
+		Backoff:      time.Duration(c.Webhooks.Backoff),
+		MaxBackoff:   time.Duration(c.Webhooks.MaxBackoff),
+		Timeout:      time.Duration(c.Webhooks.Timeout),
+		Concurrency:  c.Webhooks.Concurrency,
+		MaxPending:   c.Webhooks.MaxPending,
+		AllowedHosts: c.Webhooks.AllowedHosts,
+	}
+}
+
+// redactedSecret replaces the secrets of a configuration which is logged or printed.
+const redactedSecret = "REDACTED"
+
+// Redacted returns a copy of the configuration with its secrets replaced, to be logged or printed.
+func (c Config) Redacted() Config {
+	c.Tenants = append([]tenant.Settings(nil), c.Tenants...)
+	for i := range c.Tenants {
+		if c.Tenants[i].WebhookSecret != "" {
+			c.Tenants[i].WebhookSecret = redactedSecret
+		}
+	}
+	return c
+}
+
+// LogValue logs the configuration with its secrets redacted.
+func (c Config) LogValue() slog.Value {
+	// Convert to a type without methods, which would log the configuration again.
+	type config Config
+	return slog.AnyValue(config(c.Redacted()))
+}
+
+// YAML returns the configuration as a YAML document with its secrets redacted.
+func (c Config) YAML() ([]byte, error) {
+	return yaml.Marshal(c.Redacted())
+}
+
+// set parses a Go duration string into the duration.
+func (d *Duration) set(value string) error {
+	return d.UnmarshalText([]byte(value))
+}
+
+// intSetter returns a function parsing an integer into the target.
+func intSetter(target *int) func(string) error {
+	return func(value string) error {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("not an integer")
+		}
+		*target = parsed
+		return nil
+	}
+}
+
+// int64Setter returns a function parsing a 64-bit integer into the target.
+func int64Setter(target *int64) func(string) error {
+	return func(value string) error {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return errors.New("not an integer")
+		}
+		*target = parsed
+		return nil
+	}
+}
+
+// floatSetter returns a function parsing a decimal number into the target.
+func floatSetter(target *float64) func(string) error {
+	return func(value string) error {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return errors.New("not a number")
+		}
+		*target = parsed
+		return nil
+	}
+}
+
+// stringSetter returns a function storing the value in the target.
+func stringSetter(target *string) func(string) error {
+	return func(value string) error {
+		*target = value
+		return nil
+	}
+}
+
+// listSetter returns a function storing the comma-separated values in the target.
+func listSetter(target *[]string) func(string) error {
+	return func(value string) error {
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*target = items
+		return nil
+	}
+}
@@ -0,0 +1,242 @@
+package config_test
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/config"
+	"rpg/internal/packcalculator/tenant"
+)
+
+// writeFile writes the content to a file of the given name in a temporary directory.
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// environment returns a getenv function backed by the map.
+func environment(variables map[string]string) func(string) string {
+	return func(name string) string { return variables[name] }
+}
+
+// TestDefault verifies that the defaults are valid and match the previous behaviour.
+func TestDefault(t *testing.T) {
+	cfg := config.Default()
+
+	assert.NoError(t, cfg.Validate(), "Defaults must be valid")
+	assert.Equal(t, ":8080", cfg.ServerConfig().Addr, "Incorrect default address")
+	assert.Equal(t, []string{"*"}, cfg.CORS.AllowedOrigins, "Incorrect default origins")
+	assert.Equal(t, 50, cfg.Solver.HeadroomMultiplier, "Incorrect default headroom multiplier")
+	assert.Equal(t, "fixed", cfg.Solver.Clamp, "Incorrect default clamp")
+	assert.Equal(t, "tenant_id", cfg.Auth.TenantClaim, "Incorrect default tenant claim")
+}
+
+// TestLoad_YAML verifies that a YAML file overrides the defaults and the environment overrides the file.
+func TestLoad_YAML(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+server:
+  port: 9090
+  write_timeout: 1m
+cors:
+  allowed_origins: [https://shop.example.com]
+logging:
+  level: debug
+`)
+
+	cfg, err := config.Load(path, environment(map[string]string{"RPG_BACKEND_PORT": "9191", "RPG_SHUTDOWN_GRACE": "5s", "RPG_CLAMP": "safe"}))
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.NoError(t, cfg.Validate(), "Unexpected validation error")
+	assert.Equal(t, 9191, cfg.Server.Port, "Environment must override the file")
+	assert.Equal(t, time.Minute, cfg.ServerConfig().WriteTimeout, "Incorrect write timeout")
+	assert.Equal(t, 5*time.Second, cfg.ServerConfig().ShutdownGrace, "Incorrect grace period")
+	assert.Equal(t, 10*time.Second, cfg.ServerConfig().ReadTimeout, "Unset settings must keep their defaults")
+	assert.Equal(t, []string{"https://shop.example.com"}, cfg.CORS.AllowedOrigins, "Incorrect origins")
+	assert.Equal(t, "debug", cfg.Logging.Level, "Incorrect log level")
+	assert.Equal(t, "safe", cfg.Solver.Clamp, "Incorrect clamp")
+}
+
+// TestLoad_JSON verifies that JSON files are read by their extension.
+func TestLoad_JSON(t *testing.T) {
+	path := writeFile(t, "config.json", `{"solver": {"headroom_multiplier": 10}, "limits": {"max_body_bytes": 4096}}`)
+
+	cfg, err := config.Load(path, environment(nil))
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 10, cfg.Solver.HeadroomMultiplier, "Incorrect headroom multiplier")
+	assert.Equal(t, int64(4096), cfg.Limits.MaxBodyBytes, "Incorrect body limit")
+}
+
+// TestLoad_Errors verifies that unreadable settings are reported with their source.
+func TestLoad_Errors(t *testing.T) {
+	// Subtest: unknown keys are rejected rather than silently ignored.
+	t.Run("Unknown key", func(t *testing.T) {
+		_, err := config.Load(writeFile(t, "config.yaml", "server:\n  prot: 9090\n"), environment(nil))
+		assert.ErrorIs(t, err, config.ErrInvalidConfig)
+		assert.ErrorContains(t, err, "prot")
+	})
+
+	// Subtest: invalid environment values name the variable.
+	t.Run("Invalid environment", func(t *testing.T) {
+		_, err := config.Load("", environment(map[string]string{"RPG_IDLE_TIMEOUT": "soon"}))
+		assert.ErrorIs(t, err, config.ErrInvalidConfig)
+		assert.ErrorContains(t, err, "RPG_IDLE_TIMEOUT")
+	})
+
+	// Subtest: a missing file is an error.
+	t.Run("Missing file", func(t *testing.T) {
+		_, err := config.Load(filepath.Join(t.TempDir(), "missing.yaml"), environment(nil))
+		assert.ErrorIs(t, err, config.ErrInvalidConfig)
+	})
+}
+
+// TestSet verifies that command line overrides apply by setting name.
+func TestSet(t *testing.T) {
+	cfg := config.Default()
+
+	assert.NoError(t, cfg.Set("server.port", "7070"))
+	assert.NoError(t, cfg.Set("cors.allowed_origins", "https://a.example.com, https://b.example.com"))
+	assert.NoError(t, cfg.Set("solver.clamp", "off"))
+	assert.NoError(t, cfg.Set("audit.file", "/var/log/rpg/audit.jsonl"))
+	assert.Equal(t, 7070, cfg.Server.Port, "Incorrect port")
+	assert.Equal(t, "off", cfg.Solver.Clamp, "Incorrect clamp")
+	assert.Equal(t, "/var/log/rpg/audit.jsonl", cfg.Audit.File, "Incorrect audit log")
+	assert.Equal(t, []string{"https://a.example.com", "https://b.example.com"}, cfg.CORS.AllowedOrigins, "Incorrect origins")
+
+	assert.ErrorIs(t, cfg.Set("server.port", "http"), config.ErrInvalidConfig, "Expected an error for a non-numeric port")
+	assert.ErrorIs(t, cfg.Set("server.colour", "blue"), config.ErrInvalidConfig, "Expected an error for an unknown setting")
+}
+
+// TestValidate verifies that every invalid setting is reported by its name in the file.
+func TestValidate(t *testing.T) {
+	cfg := config.Default()
+	cfg.Server.Port = 0
+	cfg.Solver.HeadroomMultiplier = 0
+	cfg.Solver.Clamp = "fast"
+	cfg.Logging.Level = "loud"
+	cfg.Tracing.OTLPEndpoint = "not a url"
+	cfg.Auth.APIKeysFile = filepath.Join(t.TempDir(), "missing.yaml")
+	cfg.Auth.JWKS = "https://id.example.com/.well-known/jwks.json"
+	cfg.RateLimit.TrustedProxies = []string{"10.0.0.0/33"}
+
+	err := cfg.Validate()
+
+	assert.ErrorIs(t, err, config.ErrInvalidConfig)
+	for _, name := range []string{"server.port", "solver.headroom_multiplier", "solver.clamp", "logging.level", "tracing.otlp_endpoint", "auth.api_keys_file", "auth.audience", "rate_limit.trusted_proxies"} {
+		assert.ErrorContains(t, err, name, "Missing problem")
+	}
+}
+
+// TestValidate_AuditBackups verifies that rotated files must keep a backup, as rotating without one deletes them.
+func TestValidate_AuditBackups(t *testing.T) {
+	cfg := config.Default()
+	cfg.Audit.MaxBackups = 0
+	assert.NoError(t, cfg.Validate(), "Expected no backups to be valid without a file")
+
+	cfg.Audit.File = "audit.log"
+	assert.ErrorContains(t, cfg.Validate(), "audit.max_backups", "Expected a rotated file without backups to be invalid")
+
+	cfg.Audit.MaxBytes = 0
+	assert.NoError(t, cfg.Validate(), "Expected no backups to be valid for a file which never rotates")
+
+	cfg.Audit.File, cfg.Audit.MaxBytes = "", 1<<20
+	cfg.Webhooks.DeadLetterFile = "dead-letters.log"
+	assert.ErrorContains(t, cfg.Validate(), "audit.max_backups", "Expected a rotated dead letter file without backups to be invalid")
+}
+
+// TestValidate_JWKS verifies that the key set of bearer tokens may be a URL or an existing file.
+func TestValidate_JWKS(t *testing.T) {
+	cfg := config.Default()
+	cfg.Auth.Audience = "rpg"
+
+	cfg.Auth.JWKS = "https://id.example.com/.well-known/jwks.json"
+	assert.NoError(t, cfg.Validate(), "Expected a URL to be valid")
+
+	cfg.Auth.JWKS = writeFile(t, "jwks.json", `{"keys": []}`)
+	assert.NoError(t, cfg.Validate(), "Expected an existing file to be valid")
+
+	cfg.Auth.JWKS = "jwks.json"
+	assert.ErrorContains(t, cfg.Validate(), "auth.jwks", "Expected a missing file to be invalid")
+}
+
+// TestYAML verifies that the printed configuration can be loaded again.
+func TestYAML(t *testing.T) {
+	cfg := config.Default()
+	cfg.Server.Port = 9999
+	document, err := cfg.YAML()
+	assert.NoError(t, err, "Unexpected error")
+
+	loaded, err := config.Load(writeFile(t, "effective.yaml", string(document)), environment(nil))
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, cfg, loaded, "Configuration did not round-trip")
+}
+
+// TestRedacted verifies that the webhook secrets of the tenants are neither logged nor printed, while the
+// configuration keeps them.
+func TestRedacted(t *testing.T) {
+	cfg := config.Default()
+	cfg.Tenants = []tenant.Settings{{ID: "north", WebhookSecret: "north-secret"}, {ID: "south"}}
+
+	var logs bytes.Buffer
+	slog.New(slog.NewJSONHandler(&logs, nil)).Info("Effective configuration", "config", cfg)
+	document, err := cfg.YAML()
+
+	assert.NoError(t, err, "Unexpected error")
+	for name, output := range map[string]string{"log": logs.String(), "YAML": string(document)} {
+		assert.NotContains(t, output, "north-secret", "Expected the %s to redact the secret", name)
+		assert.Contains(t, output, "REDACTED", "Expected the %s to mark the secret", name)
+	}
+	assert.Equal(t, "north-secret", cfg.Tenants[0].WebhookSecret, "Expected the configuration to keep the secret")
+	assert.Equal(t, map[string]string{"north": "north-secret"}, cfg.WebhookConfig().Secrets, "Incorrect webhook secrets")
+}
+
+// TestLoad_Tenants verifies that tenants are read from the file and that invalid or duplicate tenants are reported.
+func TestLoad_Tenants(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+tenants:
+  - id: north
+    clamp: safe
+    max_order: 100000
+    daily_quota: 5000
+`)
+
+	cfg, err := config.Load(path, environment(map[string]string{"RPG_CATALOG_DIR": "/var/lib/rpg/catalog"}))
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.NoError(t, cfg.Validate(), "Unexpected validation error")
+	assert.Equal(t, "north", cfg.Tenants[0].ID, "Incorrect tenant")
+	assert.Equal(t, 100000, cfg.Tenants[0].MaxOrder, "Incorrect order limit")
+	assert.Equal(t, "/var/lib/rpg/catalog", cfg.Catalog.Dir, "Incorrect catalog directory")
+
+	cfg.Tenants[0].Clamp = "fast"
+	assert.ErrorContains(t, cfg.Validate(), "tenants[0].clamp", "Expected an unknown clamp to be invalid")
+
+	cfg.Tenants[0].Clamp = ""
+	cfg.Tenants = append(cfg.Tenants, cfg.Tenants[0])
+	assert.ErrorContains(t, cfg.Validate(), "listed twice", "Expected a duplicate tenant to be invalid")
+}
+
+// TestLoad_RateLimit verifies that the budgets are read from the environment and passed to the rate limiter.
+func TestLoad_RateLimit(t *testing.T) {
+	cfg, err := config.Load("", environment(map[string]string{"RPG_RATE_LIMIT": "2.5", "RPG_COMPUTE_RATE_LIMIT": "0", "RPG_CACHE_SIZE": "500"}))
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 2.5, cfg.RateLimitConfig().Requests.Rate, "Incorrect request rate")
+	assert.Equal(t, 100, cfg.RateLimitConfig().Requests.Burst, "Unset settings must keep their defaults")
+	assert.Equal(t, 0.0, cfg.RateLimitConfig().Computations.Rate, "Incorrect computation rate")
+	assert.Equal(t, 500, cfg.Cache.Size, "Incorrect cache size")
+
+	_, err = config.Load("", environment(map[string]string{"RPG_RATE_LIMIT": "fast"}))
+	assert.ErrorContains(t, err, "RPG_RATE_LIMIT", "Expected an error for a rate which is not a number")
+
+	cfg.Cache.Size = -1
+	assert.ErrorContains(t, cfg.Validate(), "cache.size", "Expected a negative cache size to be invalid")
+}
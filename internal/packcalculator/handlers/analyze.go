@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-playground/validator"
+
+	"rpg/internal/packcalculator/analysis"
+	"rpg/internal/packcalculator/logging"
+	"rpg/internal/packcalculator/ratelimit"
+	"rpg/internal/packcalculator/tenant"
+)
+
+// AnalyzeRequest is the body of the '/analyze' endpoint.
+type AnalyzeRequest struct {
+	PackSizes []int `json:"pack_sizes"`
+}
+
+// AnalyzeHandler handles the '/analyze' endpoint.
+func AnalyzeHandler(w http.ResponseWriter, r *http.Request) {
+	// Check if the request method is POST, return Method Not Allowed if not.
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method. Use POST.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Decode the JSON request body into a struct.
+	var request AnalyzeRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		// If there is an error decoding JSON, return a Bad Request response.
+		http.Error(w, "Error decoding JSON request", http.StatusBadRequest)
+		return
+	}
+	if err := tenant.FromContext(r.Context()).CheckPackSizes(len(request.PackSizes)); err != nil {
+		// If the pack sizes exceed the limits of the tenant, return a Bad Request response explaining why.
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Charge the analysis to the computations budget of the client.
+	if !ratelimit.Compute(w, r) {
+		return
+	}
+
+	// Call the AnalyzeGaps function to analyse the pack sizes, stopping when the client goes away.
+	report, err := analysis.AnalyzeGaps(r.Context(), request.PackSizes)
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		// If the pack sizes are invalid, return a Bad Request response.
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, analysis.ErrAnalysisTooLarge) {
+		// If the pack sizes cannot be analysed, return an Unprocessable Entity response.
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		// If the analysis was canceled, such as by the client going away, return a Service Unavailable response.
+		logging.FromContext(r.Context()).Warn("Analysis canceled", "error", err)
+		http.Error(w, "Analysis canceled", http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		// If an error occurs during the analysis, return an Internal Server Error response.
+		http.Error(w, "Error analysing pack sizes", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, report)
+}
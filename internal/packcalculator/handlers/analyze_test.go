@@ -0,0 +1,91 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/analysis"
+	"rpg/internal/packcalculator/handlers"
+)
+
+// TestAnalyzeHandler_ValidRequest tests the handling of a valid gap analysis request.
+func TestAnalyzeHandler_ValidRequest(t *testing.T) {
+	// Create a test HTTP request analysing the custom pack sizes.
+	requestBody := `{"pack_sizes": [23, 31, 53]}`
+	req, err := http.NewRequest("POST", "/analyze", bytes.NewBufferString(requestBody))
+	assert.NoError(t, err)
+
+	// Create a fake HTTP response.
+	w := httptest.NewRecorder()
+
+	// Call AnalyzeHandler.
+	handlers.AnalyzeHandler(w, req)
+
+	// Verify that the response status code is 200 OK.
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Parse the JSON response and check its structure.
+	var report analysis.GapReport
+	err = json.Unmarshal(w.Body.Bytes(), &report)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.GCD, "unexpected gcd")
+	if assert.NotNil(t, report.FrobeniusNumber, "missing Frobenius number") {
+		assert.Equal(t, 326, *report.FrobeniusNumber, "unexpected Frobenius number")
+	}
+	assert.Equal(t, 22, report.MaxSurplus, "unexpected maximum surplus")
+}
+
+// TestAnalyzeHandler_InvalidMethod tests the handling of an invalid request method.
+func TestAnalyzeHandler_InvalidMethod(t *testing.T) {
+	// Create a test HTTP request with an incorrect method.
+	req, err := http.NewRequest("GET", "/analyze", nil)
+	assert.NoError(t, err)
+
+	// Create a fake HTTP response.
+	w := httptest.NewRecorder()
+
+	// Call AnalyzeHandler.
+	handlers.AnalyzeHandler(w, req)
+
+	// Verify that the response status code is 405 Method Not Allowed.
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+// TestAnalyzeHandler_InvalidRequest tests the handling of a request without pack sizes.
+func TestAnalyzeHandler_InvalidRequest(t *testing.T) {
+	// Create a test HTTP request with empty pack sizes.
+	requestBody := `{"pack_sizes": []}`
+	req, err := http.NewRequest("POST", "/analyze", bytes.NewBufferString(requestBody))
+	assert.NoError(t, err)
+
+	// Create a fake HTTP response.
+	w := httptest.NewRecorder()
+
+	// Call AnalyzeHandler.
+	handlers.AnalyzeHandler(w, req)
+
+	// Verify that the response status code is 400 Bad Request.
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// TestAnalyzeHandler_TooLarge tests the handling of pack sizes too large to analyse.
+func TestAnalyzeHandler_TooLarge(t *testing.T) {
+	// Create a test HTTP request with coprime pack sizes above the scan limit.
+	requestBody := `{"pack_sizes": [1000001, 1000002]}`
+	req, err := http.NewRequest("POST", "/analyze", bytes.NewBufferString(requestBody))
+	assert.NoError(t, err)
+
+	// Create a fake HTTP response.
+	w := httptest.NewRecorder()
+
+	// Call AnalyzeHandler.
+	handlers.AnalyzeHandler(w, req)
+
+	// Verify that the response status code is 422 Unprocessable Entity.
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
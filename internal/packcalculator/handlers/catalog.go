@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"rpg/internal/packcalculator/catalog"
+	"rpg/internal/packcalculator/logging"
+	"rpg/internal/packcalculator/models"
+	"rpg/internal/packcalculator/tenant"
+)
+
+// Catalog holds the product catalogs of the tenants. It is kept in memory unless replaced at startup.
+var Catalog = catalog.NewStore()
+
+// ErrInvalidSKU is returned when an order names a product it cannot be calculated for.
+var ErrInvalidSKU = errors.New("invalid SKU order")
+
+// ListProductsHandler handles GET requests to the '/catalog/products' endpoint.
+func ListProductsHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, Catalog.List(tenant.ID(r.Context())))
+}
+
+// GetProductHandler handles GET requests to the '/catalog/products/{sku}' endpoint.
+func GetProductHandler(w http.ResponseWriter, r *http.Request) {
+	product, err := Catalog.Get(tenant.ID(r.Context()), mux.Vars(r)["sku"])
+	if err != nil {
+		// If the tenant has no such product, return a Not Found response.
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, r, product)
+}
+
+// PutProductHandler handles PUT requests to the '/catalog/products/{sku}' endpoint, creating or replacing the product.
+func PutProductHandler(w http.ResponseWriter, r *http.Request) {
+	// Decode the JSON request body into a struct.
+	var product catalog.Product
+	if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
+		// If there is an error decoding JSON, return a Bad Request response.
+		http.Error(w, "Error decoding JSON request", http.StatusBadRequest)
+		return
+	}
+
+	// Take the SKU from the path, refusing a body naming another one.
+	sku := mux.Vars(r)["sku"]
+	if product.SKU != "" && product.SKU != sku {
+		http.Error(w, fmt.Sprintf("SKU %q does not match the path", product.SKU), http.StatusBadRequest)
+		return
+	}
+	product.SKU = sku
+
+	current := tenant.FromContext(r.Context())
+	created, err := Catalog.Put(current.ID, product, current.MaxProducts)
+	if errors.Is(err, catalog.ErrInvalidProduct) {
+		// If the product is invalid, return a Bad Request response.
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, catalog.ErrCatalogFull) {
+		// If the tenant's catalog holds its maximum of products, return a Conflict response.
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		// If the catalog cannot be saved, log it and return an Internal Server Error response.
+		logging.FromContext(r.Context()).Error("Error saving product", "sku", sku, "error", err)
+		http.Error(w, "Error saving product", http.StatusInternalServerError)
+		return
+	}
+
+	if created {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+	}
+	writeJSON(w, r, product)
+}
+
+// DeleteProductHandler handles DELETE requests to the '/catalog/products/{sku}' endpoint.
+func DeleteProductHandler(w http.ResponseWriter, r *http.Request) {
+	err := Catalog.Delete(tenant.ID(r.Context()), mux.Vars(r)["sku"])
+	if errors.Is(err, catalog.ErrProductNotFound) {
+		// If the tenant has no such product, return a Not Found response.
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		// If the catalog cannot be saved, log it and return an Internal Server Error response.
+		logging.FromContext(r.Context()).Error("Error deleting product", "error", err)
+		http.Error(w, "Error deleting product", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resolveSKU replaces the SKU of the request with the pack sizes of the product in the tenant's catalog.
+func resolveSKU(request *models.CalculateRequest, tenantID string) error {
+	if request.SKU == "" {
+		return nil
+	}
+	if len(request.PackSizes) > 0 {
+		return fmt.Errorf("%w: give either sku or pack_sizes", ErrInvalidSKU)
+	}
+	if request.Unit != models.UnitPiece || request.Precision != 0 {
+		return fmt.Errorf("%w: products are ordered in whole pieces", ErrInvalidSKU)
+	}
+
+	product, err := Catalog.Get(tenantID, request.SKU)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSKU, err)
+	}
+	request.PackSizes = product.PackSizes
+	return nil
+}
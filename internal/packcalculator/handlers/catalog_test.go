@@ -0,0 +1,129 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/auth"
+	"rpg/internal/packcalculator/cache"
+	"rpg/internal/packcalculator/catalog"
+	"rpg/internal/packcalculator/handlers"
+	"rpg/internal/packcalculator/models"
+	"rpg/internal/packcalculator/tenant"
+)
+
+// serveTenant sends a request from a client bound to the tenant, or an anonymous one for none, to the catalog and calculate routes.
+func serveTenant(t *testing.T, registry *tenant.Registry, tenantID, method, path, body string) *httptest.ResponseRecorder {
+	router := mux.NewRouter()
+	router.HandleFunc("/calculate", handlers.CalculateHandler).Methods("POST")
+	router.HandleFunc("/catalog/products", handlers.ListProductsHandler).Methods("GET")
+	router.HandleFunc("/catalog/products/{sku}", handlers.GetProductHandler).Methods("GET")
+	router.HandleFunc("/catalog/products/{sku}", handlers.PutProductHandler).Methods("PUT")
+	router.HandleFunc("/catalog/products/{sku}", handlers.DeleteProductHandler).Methods("DELETE")
+	router.Use(registry.Middleware)
+
+	req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+	assert.NoError(t, err)
+	if tenantID != "" {
+		req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{Subject: tenantID + "-warehouse", Tenant: tenantID}))
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// TestCatalogHandlers verifies that products are created, read and deleted per tenant and ordered by SKU.
+func TestCatalogHandlers(t *testing.T) {
+	handlers.Catalog = catalog.NewStore()
+	registry, err := tenant.NewRegistry([]tenant.Settings{{ID: "north", MaxProducts: 1}, {ID: "south"}})
+	assert.NoError(t, err)
+
+	w := serveTenant(t, registry, "north", "PUT", "/catalog/products/WIDGET-1", `{"name": "Widget", "pack_sizes": [23, 31, 53]}`)
+	assert.Equal(t, http.StatusCreated, w.Code, "Expected the product to be created")
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"), "Incorrect content type")
+	w = serveTenant(t, registry, "north", "PUT", "/catalog/products/WIDGET-1", `{"name": "Widget", "pack_sizes": [23, 31]}`)
+	assert.Equal(t, http.StatusOK, w.Code, "Expected the product to be replaced")
+
+	w = serveTenant(t, registry, "north", "GET", "/catalog/products", "")
+	var products []catalog.Product
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &products))
+	assert.Equal(t, []catalog.Product{{SKU: "WIDGET-1", Name: "Widget", PackSizes: models.NewPackSizes([]int{23, 31})}}, products, "Incorrect catalog")
+
+	w = serveTenant(t, registry, "south", "GET", "/catalog/products/WIDGET-1", "")
+	assert.Equal(t, http.StatusNotFound, w.Code, "Expected another tenant not to see the product")
+
+	w = serveTenant(t, registry, "north", "PUT", "/catalog/products/GADGET", `{"pack_sizes": [5]}`)
+	assert.Equal(t, http.StatusConflict, w.Code, "Expected the tenant's product limit to apply")
+	w = serveTenant(t, registry, "north", "PUT", "/catalog/products/GADGET", `{"sku": "OTHER", "pack_sizes": [5]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Expected a mismatching SKU to be refused")
+	w = serveTenant(t, registry, "south", "PUT", "/catalog/products/GADGET", `{"pack_sizes": []}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Expected a product without pack sizes to be refused")
+
+	w = serveTenant(t, registry, "south", "DELETE", "/catalog/products/WIDGET-1", "")
+	assert.Equal(t, http.StatusNotFound, w.Code, "Expected another tenant not to delete the product")
+	w = serveTenant(t, registry, "north", "DELETE", "/catalog/products/WIDGET-1", "")
+	assert.Equal(t, http.StatusNoContent, w.Code, "Expected the product to be deleted")
+}
+
+// TestCalculateHandler_SKU verifies that an order by SKU uses the pack sizes of the tenant's product.
+func TestCalculateHandler_SKU(t *testing.T) {
+	handlers.Catalog = catalog.NewStore()
+	registry, err := tenant.NewRegistry([]tenant.Settings{{ID: "north", MaxOrder: 1000}, {ID: "south"}})
+	assert.NoError(t, err)
+	_, err = handlers.Catalog.Put("north", catalog.Product{SKU: "WIDGET-1", PackSizes: models.NewPackSizes([]int{23, 31, 53})}, 0)
+	assert.NoError(t, err)
+
+	w := serveTenant(t, registry, "north", "POST", "/calculate", `{"order": 263, "sku": "WIDGET-1"}`)
+	assert.Equal(t, http.StatusOK, w.Code, "Expected the order to be calculated")
+	var response models.CalculateResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 23, response.Packs[0].PackSize, "Incorrect pack size")
+	assert.Equal(t, 2, response.Packs[0].Quantity, "Incorrect quantity")
+
+	tests := []struct {
+		name   string
+		tenant string
+		body   string
+	}{
+		{"Product of another tenant", "south", `{"order": 263, "sku": "WIDGET-1"}`},
+		{"SKU with pack sizes", "north", `{"order": 263, "sku": "WIDGET-1", "pack_sizes": [5]}`},
+		{"SKU with a unit", "north", `{"order": 2.5, "unit": "kg", "sku": "WIDGET-1"}`},
+		{"Order beyond the tenant's limit", "north", `{"order": 1001, "sku": "WIDGET-1"}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := serveTenant(t, registry, test.tenant, "POST", "/calculate", test.body)
+			assert.Equal(t, http.StatusBadRequest, w.Code, "Expected the order to be refused")
+		})
+	}
+}
+
+// TestCalculateHandler_Cache verifies that repeated calculations are answered from the cache of their tenant only.
+func TestCalculateHandler_Cache(t *testing.T) {
+	handlers.Results = cache.New(10)
+	defer func() { handlers.Results = cache.New(0) }()
+	registry, err := tenant.NewRegistry([]tenant.Settings{{ID: "north"}, {ID: "south", Clamp: "safe"}})
+	assert.NoError(t, err)
+	body := `{"order": 263, "pack_sizes": [23, 31, 53]}`
+
+	first := serveTenant(t, registry, "north", "POST", "/calculate", body)
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, "MISS", first.Header().Get(handlers.CacheHeader), "Expected a fresh calculation")
+
+	second := serveTenant(t, registry, "north", "POST", "/calculate", body)
+	assert.Equal(t, "HIT", second.Header().Get(handlers.CacheHeader), "Expected a cached response")
+	assert.Equal(t, first.Body.String(), second.Body.String(), "Expected the cached response to match")
+
+	other := serveTenant(t, registry, "south", "POST", "/calculate", body)
+	assert.Equal(t, "MISS", other.Header().Get(handlers.CacheHeader), "Expected tenants not to share cached responses")
+
+	invalid := serveTenant(t, registry, "north", "POST", "/calculate", `{"order": 1, "pack_sizes": [2], "policy": {"exact_only": true}}`)
+	assert.Empty(t, invalid.Header().Get(handlers.CacheHeader), "Expected refused requests not to be cached")
+	assert.Equal(t, 2, handlers.Results.Len(), "Expected only successful calculations to be cached")
+}
@@ -1,13 +1,42 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"io"
 	"net/http"
 
+	"github.com/go-playground/validator"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"rpg/internal/packcalculator/audit"
+	"rpg/internal/packcalculator/auth"
+	"rpg/internal/packcalculator/cache"
+	"rpg/internal/packcalculator/logging"
+	"rpg/internal/packcalculator/metrics"
+	"rpg/internal/packcalculator/models"
+	"rpg/internal/packcalculator/ratelimit"
 	"rpg/internal/packcalculator/services"
+	"rpg/internal/packcalculator/tenant"
 )
 
+// CacheHeader tells whether a response was served from the result cache, "HIT", or calculated, "MISS".
+const CacheHeader = "X-Cache"
+
+// Results caches the responses of '/calculate' by tenant and request. It caches nothing unless replaced at startup.
+var Results = cache.New(0)
+
+// Solver calculates the orders of every endpoint. It uses the solver defaults unless replaced at startup.
+var Solver services.Solver
+
+// tracer returns the tracer of the handlers from the current provider, which does nothing until one is installed.
+func tracer() trace.Tracer {
+	return otel.Tracer("rpg/internal/packcalculator/handlers")
+}
+
 // CalculateHandler handles the '/calculate' endpoint.
 func CalculateHandler(w http.ResponseWriter, r *http.Request) {
 	// Check if the request method is POST, return Method Not Allowed if not.
@@ -16,33 +45,41 @@ func CalculateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Decode the JSON request body into a struct.
-	var request struct {
-		Order     int   `json:"order"`
-		PackSizes []int `json:"pack_sizes"`
-	}
-	err := json.NewDecoder(r.Body).Decode(&request)
-	if err != nil {
-		// If there is an error decoding JSON, return a Bad Request response.
-		http.Error(w, "Error decoding JSON request", http.StatusBadRequest)
+	// Trace the request down to the graph phases of the calculator.
+	ctx, span := tracer().Start(r.Context(), "CalculateHandler")
+	defer span.End()
+
+	// Decode the order and apply the tenant's catalog, defaults and limits.
+	current := tenant.FromContext(ctx)
+	request, ok := decodeOrder(ctx, w, r.Body, current)
+	if !ok {
 		return
 	}
 
-	// Call the CalculatePacks function to calculate the optimal packing of sizes.
-	result, err := services.CalculatePacks(request.Order, request.PackSizes)
+	// Answer from the result cache when the tenant asked for the same calculation before.
+	key, err := cache.Key(current.ID, request)
 	if err != nil {
-		// If an error occurs during calculation, return an Internal Server Error response.
+		// If the request cannot be encoded as a key, log it and return an Internal Server Error response.
+		logging.FromContext(ctx).Error("Error encoding cache key", "error", err)
 		http.Error(w, "Error calculating packs", http.StatusInternalServerError)
 		return
 	}
-
-	// Convert the result to JSON format.
-	response, err := json.Marshal(result)
-	if err != nil {
-		// If encoding the response to JSON fails, return an Internal Server Error response.
-		http.Error(w, "Error encoding response", http.StatusInternalServerError)
-		log.Println("Error encoding response:", err)
-		return
+	response, hit := Results.Get(key)
+	metrics.ObserveCacheLookup(current.ID, hit, Results.Len())
+	span.SetAttributes(attribute.Int("order.quantity", request.Order), attribute.Int("pack_sizes.count", len(request.PackSizes)), attribute.Bool("cache.hit", hit))
+	audit.Annotate(ctx, request, hit)
+	if hit {
+		w.Header().Set(CacheHeader, "HIT")
+	} else {
+		// Charge the fresh calculation to the computations budget of the client.
+		if !ratelimit.Compute(w, r) {
+			return
+		}
+		if response, err = calculate(ctx, w, request); err != nil {
+			return
+		}
+		Results.Put(key, response)
+		w.Header().Set(CacheHeader, "MISS")
 	}
 
 	// Set the Content-Type header to indicate that the response is in JSON format.
@@ -52,7 +89,95 @@ func CalculateHandler(w http.ResponseWriter, r *http.Request) {
 	_, err = w.Write(response)
 	if err != nil {
 		// If writing the response fails, log the error and return an Internal Server Error response.
-		log.Println("Error writing response:", err)
+		logging.FromContext(r.Context()).Error("Error writing response", "error", err)
 		http.Error(w, "Error writing response", http.StatusInternalServerError)
 	}
 }
+
+// decodeOrder decodes the JSON order of the body, takes the pack sizes of a product from the tenant's
+// catalog and applies the tenant's defaults and limits. Only principals with the admin scope may choose the clamp
+// strategy, which decides how large the search grows. When the order is refused it writes the error response and
+// returns false.
+func decodeOrder(ctx context.Context, w http.ResponseWriter, body io.Reader, current *tenant.Tenant) (models.CalculateRequest, bool) {
+	// Decode the JSON request body into a struct.
+	var request models.CalculateRequest
+	err := json.NewDecoder(body).Decode(&request)
+	if errors.Is(err, models.ErrInvalidQuantity) {
+		// If a quantity or unit cannot be represented, return a Bad Request response explaining why.
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return request, false
+	}
+	if err != nil {
+		// If there is an error decoding JSON, return a Bad Request response.
+		http.Error(w, "Error decoding JSON request", http.StatusBadRequest)
+		return request, false
+	}
+
+	if principal, _ := auth.FromContext(ctx); request.Clamp != "" && !principal.HasScope(auth.ScopeAdmin) {
+		// If the caller may not choose the clamp strategy, return a Forbidden response.
+		http.Error(w, "choosing the clamp requires scope "+auth.ScopeAdmin, http.StatusForbidden)
+		return request, false
+	}
+
+	// Take the pack sizes of a product from the tenant's catalog and apply the tenant's defaults and limits.
+	if err := resolveSKU(&request, current.ID); err != nil {
+		// If the product cannot be ordered, return a Bad Request response explaining why.
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return request, false
+	}
+	if err := current.Apply(&request); err != nil {
+		// If the order exceeds the limits of the tenant, return a Bad Request response explaining why.
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return request, false
+	}
+	return request, true
+}
+
+// calculate calculates the packing of the request and returns it as JSON. When it fails it writes the error
+// response and returns the error.
+func calculate(ctx context.Context, w http.ResponseWriter, request models.CalculateRequest) ([]byte, error) {
+	// Calculate the optimal packing of sizes with the configured solver.
+	result, err := Solver.CalculateOrder(ctx, request)
+	if status := errorStatus(err); status == http.StatusInternalServerError {
+		// If an error occurs during calculation, log it and return an Internal Server Error response.
+		logging.FromContext(ctx).Error("Error calculating packs", "error", err)
+		http.Error(w, "Error calculating packs", status)
+		return nil, err
+	} else if err != nil {
+		// If the order cannot be packed as asked, return a response explaining why.
+		http.Error(w, err.Error(), status)
+		return nil, err
+	}
+
+	// Convert the result to JSON format.
+	response, err := json.Marshal(result)
+	if err != nil {
+		// If encoding the response to JSON fails, return an Internal Server Error response.
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		logging.FromContext(ctx).Error("Error encoding response", "error", err)
+		return nil, err
+	}
+	return response, nil
+}
+
+// errorStatus returns the HTTP status of a calculation error. Constraints or a fulfilment policy which cannot be
+// satisfied and searches or parcels beyond the budget are Unprocessable Entity; invalid pack sizes, contradicting pack limits, a
+// quantity which is too large or an unknown clamp are Bad Request; calculations canceled or timed out are Service
+// Unavailable and any other error is Internal Server Error.
+func errorStatus(err error) int {
+	var validationErrors validator.ValidationErrors
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.As(err, &validationErrors):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrPackExceedsParcel) || errors.Is(err, services.ErrNoFeasiblePacking) || errors.Is(err, services.ErrSearchTooLarge) || errors.Is(err, services.ErrTooManyParcels):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, services.ErrInvalidPackLimits) || errors.Is(err, services.ErrQuantityOverflow) || errors.Is(err, services.ErrUnknownClamp):
+		return http.StatusBadRequest
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
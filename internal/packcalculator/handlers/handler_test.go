@@ -9,6 +9,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"rpg/internal/packcalculator/auth"
 	"rpg/internal/packcalculator/handlers"
 	"rpg/internal/packcalculator/models"
 )
@@ -91,3 +92,300 @@ func TestCalculateHandler_InvalidJSON(t *testing.T) {
 	// Verify that the response status code is 400 Bad Request.
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
+
+// TestCalculateHandler_Constraints tests that packs are grouped into parcels when constraints are given.
+func TestCalculateHandler_Constraints(t *testing.T) {
+	// Create a test HTTP request with weighted pack sizes and a maximum parcel weight.
+	requestBody := `{"order": 263, "pack_sizes": [{"size": 23, "weight": 2}, {"size": 31, "weight": 3}, 53], "constraints": {"max_parcel_weight": 10}}`
+	req, err := http.NewRequest("POST", "/calculate", bytes.NewBufferString(requestBody))
+	assert.NoError(t, err)
+
+	// Create a fake HTTP response.
+	w := httptest.NewRecorder()
+
+	// Call CalculateHandler.
+	handlers.CalculateHandler(w, req)
+
+	// Verify that the response status code is 200 OK.
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Parse the JSON response and check that no parcel exceeds the maximum weight.
+	var response models.CalculateResponse
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, response.Parcels, "expected parcels in the response")
+	for _, parcel := range response.Parcels {
+		assert.LessOrEqual(t, parcel.Weight, 10.0, "parcel exceeds the maximum weight")
+	}
+}
+
+// TestCalculateHandler_PackExceedsParcel tests the handling of a pack heavier than the parcel limit.
+func TestCalculateHandler_PackExceedsParcel(t *testing.T) {
+	// Create a test HTTP request with a pack heavier than the maximum parcel weight.
+	requestBody := `{"order": 10, "pack_sizes": [{"size": 10, "weight": 50}], "constraints": {"max_parcel_weight": 30}}`
+	req, err := http.NewRequest("POST", "/calculate", bytes.NewBufferString(requestBody))
+	assert.NoError(t, err)
+
+	// Create a fake HTTP response.
+	w := httptest.NewRecorder()
+
+	// Call CalculateHandler.
+	handlers.CalculateHandler(w, req)
+
+	// Verify that the response status code is 422 Unprocessable Entity.
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+// TestCalculateHandler_TooManyParcels tests the handling of packs which need more parcels than allowed.
+func TestCalculateHandler_TooManyParcels(t *testing.T) {
+	// Create a test HTTP request needing a parcel for each of its packs.
+	requestBody := `{"order": 20000, "pack_sizes": [1], "constraints": {"max_packs_per_parcel": 1}}`
+	req, err := http.NewRequest("POST", "/calculate", bytes.NewBufferString(requestBody))
+	assert.NoError(t, err)
+
+	// Create a fake HTTP response.
+	w := httptest.NewRecorder()
+
+	// Call CalculateHandler.
+	handlers.CalculateHandler(w, req)
+
+	// Verify that the response status code is 422 Unprocessable Entity.
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "too many parcels")
+}
+
+// TestCalculateHandler_NoFeasiblePacking tests the handling of a fulfilment policy which cannot be satisfied.
+func TestCalculateHandler_NoFeasiblePacking(t *testing.T) {
+	// Create a test HTTP request which requires an exact packing that does not exist.
+	requestBody := `{"order": 251, "pack_sizes": [250, 500], "policy": {"exact_only": true}}`
+	req, err := http.NewRequest("POST", "/calculate", bytes.NewBufferString(requestBody))
+	assert.NoError(t, err)
+
+	// Create a fake HTTP response.
+	w := httptest.NewRecorder()
+
+	// Call CalculateHandler.
+	handlers.CalculateHandler(w, req)
+
+	// Verify that the response status code is 422 Unprocessable Entity.
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+// TestCalculateHandler_DecimalPolicy tests that the shortfall of the policy is given in the order's unit.
+func TestCalculateHandler_DecimalPolicy(t *testing.T) {
+	for _, test := range []struct {
+		policy   string
+		expected string
+	}{
+		{`{"allowed_shortfall": 0.1}`, `"shortfall":0.1`},
+		{`{"allowed_shortfall": 0.05}`, `"surplus":0.4`},
+	} {
+		// Create a test HTTP request of 2.6 l which 2.5 l packs miss by 0.1 l.
+		requestBody := `{"order": 2.6, "unit": "l", "pack_sizes": [1, 2.5], "policy": ` + test.policy + `}`
+		req, err := http.NewRequest("POST", "/calculate", bytes.NewBufferString(requestBody))
+		assert.NoError(t, err)
+		w := httptest.NewRecorder()
+
+		handlers.CalculateHandler(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code, "Unexpected status for %s", test.policy)
+		assert.Contains(t, w.Body.String(), test.expected, "Incorrect response for %s", test.policy)
+	}
+}
+
+// TestCalculateHandler_PackLimits tests that pack limits are read from the request.
+func TestCalculateHandler_PackLimits(t *testing.T) {
+	// Create a test HTTP request which caps the largest pack size.
+	requestBody := `{"order": 10, "pack_sizes": [2, {"size": 5, "max_count": 1}], "policy": {"exact_only": true}}`
+	req, err := http.NewRequest("POST", "/calculate", bytes.NewBufferString(requestBody))
+	assert.NoError(t, err)
+
+	// Create a fake HTTP response.
+	w := httptest.NewRecorder()
+
+	// Call CalculateHandler.
+	handlers.CalculateHandler(w, req)
+
+	// Verify that the response status code is 200 OK.
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Parse the JSON response and check the packs.
+	var response models.CalculateResponse
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Pack{{PackSize: 2, Quantity: 5}}, response.Packs, "unexpected packs")
+}
+
+// TestCalculateHandler_InvalidPackLimits tests the handling of contradicting pack limits.
+func TestCalculateHandler_InvalidPackLimits(t *testing.T) {
+	// Create a test HTTP request whose minimum count exceeds the maximum count.
+	requestBody := `{"order": 10, "pack_sizes": [{"size": 5, "min_count": 3, "max_count": 1}]}`
+	req, err := http.NewRequest("POST", "/calculate", bytes.NewBufferString(requestBody))
+	assert.NoError(t, err)
+
+	// Create a fake HTTP response.
+	w := httptest.NewRecorder()
+
+	// Call CalculateHandler.
+	handlers.CalculateHandler(w, req)
+
+	// Verify that the response status code is 400 Bad Request.
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// TestCalculateHandler_QuantityOverflow tests the handling of an order above the supported maximum.
+func TestCalculateHandler_QuantityOverflow(t *testing.T) {
+	// Create a test HTTP request with an order close to the 64-bit limit.
+	requestBody := `{"order": 9000000000000000000, "pack_sizes": [250, 500]}`
+	req, err := http.NewRequest("POST", "/calculate", bytes.NewBufferString(requestBody))
+	assert.NoError(t, err)
+
+	// Create a fake HTTP response.
+	w := httptest.NewRecorder()
+
+	// Call CalculateHandler.
+	handlers.CalculateHandler(w, req)
+
+	// Verify that the response status code is 400 Bad Request.
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// TestCalculateHandler_Clamp tests choosing the clamp strategy per request.
+func TestCalculateHandler_Clamp(t *testing.T) {
+	admin := auth.Principal{Subject: "operator", Scopes: []string{auth.ScopeAdmin}}
+
+	// Subtest: the safe clamp finds the packing with the fewest packs.
+	t.Run("Safe", func(t *testing.T) {
+		requestBody := `{"order": 5406, "pack_sizes": [23, 31, 53], "clamp": "safe"}`
+		req, err := http.NewRequest("POST", "/calculate", bytes.NewBufferString(requestBody))
+		assert.NoError(t, err)
+		req = req.WithContext(auth.WithPrincipal(req.Context(), admin))
+		w := httptest.NewRecorder()
+
+		handlers.CalculateHandler(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"packs": [{"pack_size": 53, "quantity": 102}]}`, w.Body.String())
+	})
+
+	// Subtest: an unknown clamp is a Bad Request.
+	t.Run("Unknown", func(t *testing.T) {
+		requestBody := `{"order": 199, "pack_sizes": [23, 31, 53], "clamp": "fast"}`
+		req, err := http.NewRequest("POST", "/calculate", bytes.NewBufferString(requestBody))
+		assert.NoError(t, err)
+		req = req.WithContext(auth.WithPrincipal(req.Context(), admin))
+		w := httptest.NewRecorder()
+
+		handlers.CalculateHandler(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "unknown clamp strategy")
+	})
+
+	// Subtest: callers without the admin scope may not choose the clamp.
+	t.Run("Forbidden", func(t *testing.T) {
+		for _, principal := range []*auth.Principal{nil, {Subject: "shop", Scopes: []string{auth.ScopeCalculate}}} {
+			requestBody := `{"order": 5406, "pack_sizes": [23, 31, 53], "clamp": "off"}`
+			req, err := http.NewRequest("POST", "/calculate", bytes.NewBufferString(requestBody))
+			assert.NoError(t, err)
+			if principal != nil {
+				req = req.WithContext(auth.WithPrincipal(req.Context(), *principal))
+			}
+			w := httptest.NewRecorder()
+
+			handlers.CalculateHandler(w, req)
+
+			assert.Equal(t, http.StatusForbidden, w.Code)
+		}
+	})
+}
+
+// TestCalculateHandler_Units tests a decimal order in litres with pack sizes in millilitres.
+func TestCalculateHandler_Units(t *testing.T) {
+	// Create a test HTTP request with decimal quantities in compatible units.
+	requestBody := `{"order": 2.75, "unit": "l", "pack_sizes": [{"size": 500, "unit": "ml"}, 2.5]}`
+	req, err := http.NewRequest("POST", "/calculate", bytes.NewBufferString(requestBody))
+	assert.NoError(t, err)
+
+	// Create a fake HTTP response.
+	w := httptest.NewRecorder()
+
+	// Call CalculateHandler.
+	handlers.CalculateHandler(w, req)
+
+	// Verify that the response status code is 200 OK.
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Parse the JSON response and check the decimal pack sizes.
+	var response models.CalculateResponse
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Pack{
+		{PackSize: 50, Quantity: 1, Size: "0.5", Unit: models.UnitLitre, Precision: 2},
+		{PackSize: 250, Quantity: 1, Size: "2.5", Unit: models.UnitLitre, Precision: 2},
+	}, response.Packs, "unexpected packs")
+}
+
+// TestCalculateHandler_UnitSurplus tests that the surplus of a decimal order is reported in the order's unit.
+func TestCalculateHandler_UnitSurplus(t *testing.T) {
+	// Create a test HTTP request whose values are scaled by 100.
+	requestBody := `{"order": 2.75, "unit": "l", "pack_sizes": [0.5]}`
+	req, err := http.NewRequest("POST", "/calculate", bytes.NewBufferString(requestBody))
+	assert.NoError(t, err)
+
+	// Create a fake HTTP response.
+	w := httptest.NewRecorder()
+
+	// Call CalculateHandler.
+	handlers.CalculateHandler(w, req)
+
+	// Verify that the response status code is 200 OK.
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Check the surplus in litres rather than in hundredths of a litre.
+	var body map[string]any
+	err = json.Unmarshal(w.Body.Bytes(), &body)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.25, body["surplus"], "unexpected surplus")
+	assert.Equal(t, 2.0, body["precision"], "unexpected precision")
+}
+
+// TestCalculateHandler_IncompatibleUnits tests the handling of pack sizes in an incompatible unit.
+func TestCalculateHandler_IncompatibleUnits(t *testing.T) {
+	// Create a test HTTP request mixing weight and volume.
+	requestBody := `{"order": 1, "unit": "kg", "pack_sizes": [{"size": 1, "unit": "l"}]}`
+	req, err := http.NewRequest("POST", "/calculate", bytes.NewBufferString(requestBody))
+	assert.NoError(t, err)
+
+	// Create a fake HTTP response.
+	w := httptest.NewRecorder()
+
+	// Call CalculateHandler.
+	handlers.CalculateHandler(w, req)
+
+	// Verify that the response status code is 400 Bad Request.
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// TestCalculateHandler_InvalidPackSizes tests the handling of pack sizes which fail validation.
+func TestCalculateHandler_InvalidPackSizes(t *testing.T) {
+	for name, requestBody := range map[string]string{
+		"Empty":           `{"order": 10, "pack_sizes": []}`,
+		"Negative weight": `{"order": 10, "pack_sizes": [{"size": 5, "weight": -1}]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			// Create a test HTTP request with invalid pack sizes.
+			req, err := http.NewRequest("POST", "/calculate", bytes.NewBufferString(requestBody))
+			assert.NoError(t, err)
+
+			// Create a fake HTTP response.
+			w := httptest.NewRecorder()
+
+			// Call CalculateHandler.
+			handlers.CalculateHandler(w, req)
+
+			// Verify that the response status code is 400 Bad Request.
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"rpg/internal/packcalculator/audit"
+	"rpg/internal/packcalculator/auth"
+	"rpg/internal/packcalculator/history"
+	"rpg/internal/packcalculator/tenant"
+)
+
+// Limits of a page of calculations.
+const (
+	DefaultHistoryLimit = 50
+	MaxHistoryLimit     = 500
+)
+
+// History holds the recent calculations of the tenants. It keeps nothing unless replaced at startup.
+var History = history.NewStore(0)
+
+// Authenticator authenticates the callers of the API. The API is open, and every caller sees the calculations of
+// every client, unless it is replaced at startup.
+var Authenticator auth.Authenticator
+
+// ErrInvalidQuery is returned when the parameters of a history query cannot be parsed.
+var ErrInvalidQuery = errors.New("invalid query")
+
+// ListCalculationsHandler handles GET requests to the '/calculations' endpoint, returning the tenant's calculations
+// filtered by the 'sku', 'client', 'from' and 'to' parameters, the most recent first, a page at a time. Callers
+// without the admin scope only see their own calculations.
+func ListCalculationsHandler(w http.ResponseWriter, r *http.Request) {
+	query, err := parseHistoryQuery(r)
+	if err != nil {
+		// If a parameter is invalid, return a Bad Request response.
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !seesAllClients(r) {
+		if query.Client != "" && query.Client != audit.Client(r) {
+			// If the caller asks for the calculations of another client, return a Forbidden response.
+			http.Error(w, "listing the calculations of other clients requires scope "+auth.ScopeAdmin, http.StatusForbidden)
+			return
+		}
+		query.Client = audit.Client(r)
+	}
+
+	page, err := History.List(query)
+	if err != nil {
+		// If the cursor is invalid, return a Bad Request response.
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, r, page)
+}
+
+// GetCalculationHandler handles GET requests to the '/calculations/{id}' endpoint. Callers without the admin scope
+// only find their own calculations.
+func GetCalculationHandler(w http.ResponseWriter, r *http.Request) {
+	record, err := History.Get(tenant.ID(r.Context()), mux.Vars(r)["id"])
+	if err == nil && !seesAllClients(r) && record.Client != audit.Client(r) {
+		err = fmt.Errorf("%w: %s", history.ErrNotFound, mux.Vars(r)["id"])
+	}
+	if err != nil {
+		// If the tenant has no such calculation, return a Not Found response.
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, r, record)
+}
+
+// seesAllClients reports whether the caller may read the calculations of every client of its tenant. Without
+// authentication callers cannot be told apart, so every caller may.
+func seesAllClients(r *http.Request) bool {
+	principal, _ := auth.FromContext(r.Context())
+	return !Authenticator.Enabled() || principal.HasScope(auth.ScopeAdmin)
+}
+
+// parseHistoryQuery returns the history query of the request's parameters for its tenant.
+func parseHistoryQuery(r *http.Request) (history.Query, error) {
+	values := r.URL.Query()
+	query := history.Query{
+		Tenant: tenant.ID(r.Context()),
+		SKU:    values.Get("sku"),
+		Client: values.Get("client"),
+		Limit:  DefaultHistoryLimit,
+		Cursor: values.Get("cursor"),
+	}
+
+	var err error
+	if query.From, err = parseTime(values.Get("from"), false); err != nil {
+		return query, fmt.Errorf("%w: from: %v", ErrInvalidQuery, err)
+	}
+	if query.To, err = parseTime(values.Get("to"), true); err != nil {
+		return query, fmt.Errorf("%w: to: %v", ErrInvalidQuery, err)
+	}
+	if limit := values.Get("limit"); limit != "" {
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil || query.Limit < 1 || query.Limit > MaxHistoryLimit {
+			return query, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidQuery, MaxHistoryLimit)
+		}
+	}
+	return query, nil
+}
+
+// parseTime parses an RFC 3339 time or a date. A date is the start of its day in UTC, or the end of it when it
+// ends a range, so the range includes the whole day.
+func parseTime(value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if date, err := time.Parse(time.DateOnly, value); err == nil {
+		if end {
+			return date.AddDate(0, 0, 1), nil
+		}
+		return date, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
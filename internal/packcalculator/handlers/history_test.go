@@ -0,0 +1,132 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/audit"
+	"rpg/internal/packcalculator/auth"
+	"rpg/internal/packcalculator/handlers"
+	"rpg/internal/packcalculator/history"
+	"rpg/internal/packcalculator/models"
+)
+
+// serveHistory sends a GET request from the principal to the history routes.
+func serveHistory(path string, principal auth.Principal) *httptest.ResponseRecorder {
+	router := mux.NewRouter()
+	router.HandleFunc("/calculations", handlers.ListCalculationsHandler).Methods("GET")
+	router.HandleFunc("/calculations/{id}", handlers.GetCalculationHandler).Methods("GET")
+
+	req := httptest.NewRequest("GET", path, nil)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// TestHistoryHandlers verifies that calculations are listed by the query parameters and read by their ID.
+func TestHistoryHandlers(t *testing.T) {
+	admin := auth.Principal{Subject: "operator", Scopes: []string{auth.ScopeAdmin}}
+	handlers.History = history.NewStore(10)
+	for i, sku := range []string{"WIDGET", "GADGET", "WIDGET"} {
+		handlers.History.Add(audit.Record{
+			ID:       sku + string(rune('0'+i)),
+			Time:     time.Date(2024, 3, 1+i, 12, 0, 0, 0, time.UTC),
+			Tenant:   "default",
+			Client:   []string{"api_key:shop", "api_key:warehouse", "api_key:shop"}[i],
+			Request:  &models.CalculateRequest{Order: 10, SKU: sku},
+			Response: &models.CalculateResponse{},
+		})
+	}
+
+	w := serveHistory("/calculations?sku=WIDGET&limit=1", admin)
+	assert.Equal(t, http.StatusOK, w.Code, "Incorrect status")
+	var page history.Page
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Equal(t, "WIDGET2", page.Calculations[0].ID, "Expected the most recent calculation of the SKU")
+	assert.NotEmpty(t, page.NextCursor, "Expected a further page")
+
+	w = serveHistory("/calculations?from=2024-03-02&to=2024-03-02", admin)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Len(t, page.Calculations, 1, "Expected the calculations of the whole day")
+	assert.Equal(t, "GADGET1", page.Calculations[0].ID, "Incorrect calculation")
+
+	w = serveHistory("/calculations/GADGET1", admin)
+	assert.Equal(t, http.StatusOK, w.Code, "Expected the calculation to be found")
+	w = serveHistory("/calculations/unknown", admin)
+	assert.Equal(t, http.StatusNotFound, w.Code, "Expected an unknown ID to be not found")
+
+	for _, query := range []string{"limit=0", "limit=501", "from=yesterday", "cursor=next"} {
+		w = serveHistory("/calculations?"+query, admin)
+		assert.Equal(t, http.StatusBadRequest, w.Code, "Expected %s to be refused", query)
+	}
+}
+
+// TestHistoryHandlers_Client verifies that callers without the admin scope only read their own calculations.
+func TestHistoryHandlers_Client(t *testing.T) {
+	handlers.History = history.NewStore(10)
+	for i, client := range []string{"shop", "warehouse"} {
+		handlers.History.Add(audit.Record{
+			ID:       client,
+			Time:     time.Date(2024, 3, 1+i, 12, 0, 0, 0, time.UTC),
+			Client:   auth.MethodAPIKey + ":" + client,
+			Tenant:   "default",
+			Request:  &models.CalculateRequest{Order: 10},
+			Response: &models.CalculateResponse{},
+		})
+	}
+	shop := auth.Principal{Subject: "shop", Method: auth.MethodAPIKey, Scopes: []string{auth.ScopeCalculate}}
+	handlers.Authenticator = auth.Authenticator{Keys: &auth.KeyStore{}}
+	defer func() { handlers.Authenticator = auth.Authenticator{} }()
+
+	w := serveHistory("/calculations", shop)
+	var page history.Page
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Len(t, page.Calculations, 1, "Expected only the caller's calculations")
+	assert.Equal(t, "shop", page.Calculations[0].ID, "Incorrect calculation")
+
+	assert.Equal(t, http.StatusOK, serveHistory("/calculations?client=api_key:shop", shop).Code, "Expected the caller's own client to be allowed")
+	assert.Equal(t, http.StatusForbidden, serveHistory("/calculations?client=api_key:warehouse", shop).Code, "Expected other clients to be refused")
+	assert.Equal(t, http.StatusOK, serveHistory("/calculations/shop", shop).Code, "Expected the caller's calculation to be found")
+	assert.Equal(t, http.StatusNotFound, serveHistory("/calculations/warehouse", shop).Code, "Expected other clients' calculations to be hidden")
+}
+
+// TestHistoryHandlers_Anonymous verifies that every caller reads the calculations of every client when
+// authentication is disabled.
+func TestHistoryHandlers_Anonymous(t *testing.T) {
+	handlers.History = history.NewStore(10)
+	handlers.History.Add(audit.Record{
+		ID:       "warehouse",
+		Time:     time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		Client:   "ip:192.0.2.7",
+		Tenant:   "default",
+		Request:  &models.CalculateRequest{Order: 10},
+		Response: &models.CalculateResponse{},
+	})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/calculations", handlers.ListCalculationsHandler).Methods("GET")
+	router.HandleFunc("/calculations/{id}", handlers.GetCalculationHandler).Methods("GET")
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	w := serve("/calculations?client=ip:192.0.2.7")
+	assert.Equal(t, http.StatusOK, w.Code, "Expected another client to be allowed")
+	var page history.Page
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Len(t, page.Calculations, 1, "Expected the other client's calculations")
+
+	w = serve("/calculations")
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Len(t, page.Calculations, 1, "Expected every client's calculations")
+	assert.Equal(t, http.StatusOK, serve("/calculations/warehouse").Code, "Expected the other client's calculation to be found")
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"rpg/internal/packcalculator/audit"
+	"rpg/internal/packcalculator/buildinfo"
+	"rpg/internal/packcalculator/cache"
+	"rpg/internal/packcalculator/jobs"
+	"rpg/internal/packcalculator/logging"
+	"rpg/internal/packcalculator/metrics"
+	"rpg/internal/packcalculator/models"
+	"rpg/internal/packcalculator/ratelimit"
+	"rpg/internal/packcalculator/tenant"
+	"rpg/internal/packcalculator/webhook"
+)
+
+// Jobs calculates orders in the background. It refuses every job unless replaced at startup.
+var Jobs = jobs.New(jobs.Config{}, RunJob)
+
+// Webhooks notifies the callback URLs of finished jobs. Callbacks are refused unless it is replaced at startup.
+var Webhooks = webhook.New(webhook.Config{})
+
+// JobFinishedEvent is the webhook event posted to the callback URL of a job once it finished.
+const JobFinishedEvent = "job.finished"
+
+// Audit records the calculations of jobs, as the audit middleware records those of '/calculate'. Jobs are not
+// recorded unless it is set at startup.
+var Audit *audit.Log
+
+// SubmitJobHandler handles POST requests to the '/jobs' endpoint, queuing the calculation of the order and returning
+// its job with 202 Accepted. The order may name a 'callback_url' to be notified once the job finished.
+func SubmitJobHandler(w http.ResponseWriter, r *http.Request) {
+	// Read the body, which holds the order and its callback URL.
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		// If the body cannot be read, such as one beyond the size limit, return a Bad Request response.
+		http.Error(w, "Error reading request", http.StatusBadRequest)
+		return
+	}
+	var options struct {
+		CallbackURL string `json:"callback_url"`
+	}
+	if err := json.Unmarshal(body, &options); err != nil {
+		// If there is an error decoding JSON, return a Bad Request response.
+		http.Error(w, "Error decoding JSON request", http.StatusBadRequest)
+		return
+	}
+	if options.CallbackURL != "" {
+		if err := Webhooks.ValidateURL(tenant.ID(r.Context()), options.CallbackURL); err != nil {
+			// If the callback cannot be notified, return a Bad Request response explaining why.
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Decode the order and apply the tenant's catalog, defaults and limits.
+	current := tenant.FromContext(r.Context())
+	request, ok := decodeOrder(r.Context(), w, bytes.NewReader(body), current)
+	if !ok {
+		return
+	}
+
+	// Charge the calculation to the computations budget of the client.
+	if !ratelimit.Compute(w, r) {
+		return
+	}
+
+	job, err := Jobs.Submit(jobs.Job{
+		Tenant:      current.ID,
+		Client:      audit.Client(r),
+		RequestID:   logging.RequestID(r.Context()),
+		Request:     request,
+		CallbackURL: options.CallbackURL,
+	})
+	if errors.Is(err, jobs.ErrQueueFull) {
+		// If every worker is busy and the queue is full, return a Service Unavailable response.
+		logging.FromContext(r.Context()).Warn("Job refused", "error", err)
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	writeJSON(w, r, job)
+}
+
+// GetJobHandler handles GET requests to the '/jobs/{id}' endpoint, returning the status of the job and its result
+// or error once it finished.
+func GetJobHandler(w http.ResponseWriter, r *http.Request) {
+	job, err := Jobs.Get(tenant.ID(r.Context()), mux.Vars(r)["id"])
+	if err != nil {
+		// If the tenant has no such job, return a Not Found response.
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, r, job)
+}
+
+// CancelJobHandler handles DELETE requests to the '/jobs/{id}' endpoint, canceling the job unless it finished.
+func CancelJobHandler(w http.ResponseWriter, r *http.Request) {
+	job, err := Jobs.Cancel(tenant.ID(r.Context()), mux.Vars(r)["id"])
+	if errors.Is(err, jobs.ErrJobNotFound) {
+		// If the tenant has no such job, return a Not Found response.
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, jobs.ErrJobFinished) {
+		// If the job finished already, return a Conflict response.
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	writeJSON(w, r, job)
+}
+
+// NotifyJob posts the finished job to its callback URL, if it has one, as a JobFinishedEvent.
+func NotifyJob(ctx context.Context, job jobs.Job) {
+	if job.CallbackURL == "" {
+		return
+	}
+	body, err := json.Marshal(struct {
+		Event string   `json:"event"`
+		Job   jobs.Job `json:"job"`
+	}{JobFinishedEvent, job})
+	if err != nil {
+		logging.FromContext(ctx).Error("Error encoding webhook", "job", job.ID, "tenant", job.Tenant, "error", err)
+		return
+	}
+	Webhooks.Send(webhook.Delivery{ID: job.ID, Tenant: job.Tenant, Event: JobFinishedEvent, URL: job.CallbackURL, Body: body})
+}
+
+// RunJob calculates the order of a job, answering from the result cache when the tenant asked for the same
+// calculation before, and records it in the audit log unless the job was canceled.
+func RunJob(ctx context.Context, job jobs.Job) (models.CalculateResponse, error) {
+	start := time.Now()
+	logger := logging.FromContext(ctx).With("job", job.ID, "tenant", job.Tenant)
+	ctx = logging.WithLogger(ctx, logger)
+
+	// Answer from the result cache, or calculate the order and cache its result.
+	var result models.CalculateResponse
+	key, err := cache.Key(job.Tenant, job.Request)
+	if err != nil {
+		return result, err
+	}
+	response, hit := Results.Get(key)
+	metrics.ObserveCacheLookup(job.Tenant, hit, Results.Len())
+	if hit {
+		err = json.Unmarshal(response, &result)
+	} else if result, err = Solver.CalculateOrder(ctx, job.Request); err == nil {
+		if response, err := json.Marshal(result); err == nil {
+			Results.Put(key, response)
+		}
+	}
+	if ctx.Err() != nil {
+		return result, ctx.Err()
+	}
+	if errorStatus(err) == http.StatusInternalServerError {
+		logger.Error("Error calculating packs", "error", err)
+	}
+
+	// Record the outcome like a request to '/calculate'.
+	if Audit != nil {
+		record := audit.Record{
+			ID:         job.ID,
+			Time:       start.UTC(),
+			RequestID:  job.RequestID,
+			Client:     job.Client,
+			Tenant:     job.Tenant,
+			Version:    buildinfo.Version,
+			Cached:     hit,
+			Status:     errorStatus(err),
+			DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+			Request:    &job.Request,
+		}
+		if err == nil {
+			record.Response = &result
+		} else {
+			record.Error = err.Error()
+		}
+		Audit.Add(ctx, record)
+	}
+	return result, err
+}
@@ -0,0 +1,138 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/audit"
+	"rpg/internal/packcalculator/auth"
+	"rpg/internal/packcalculator/handlers"
+	"rpg/internal/packcalculator/history"
+	"rpg/internal/packcalculator/jobs"
+	"rpg/internal/packcalculator/models"
+	"rpg/internal/packcalculator/tenant"
+	"rpg/internal/packcalculator/webhook"
+)
+
+// serveJobs sends a request from a client bound to the tenant, or an anonymous one for none, to the job routes.
+func serveJobs(t *testing.T, registry *tenant.Registry, tenantID, method, path, body string) *httptest.ResponseRecorder {
+	router := mux.NewRouter()
+	router.HandleFunc("/jobs", handlers.SubmitJobHandler).Methods("POST")
+	router.HandleFunc("/jobs/{id}", handlers.GetJobHandler).Methods("GET")
+	router.HandleFunc("/jobs/{id}", handlers.CancelJobHandler).Methods("DELETE")
+	router.Use(registry.Middleware)
+
+	req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+	assert.NoError(t, err)
+	if tenantID != "" {
+		req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{Subject: tenantID + "-warehouse", Tenant: tenantID}))
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// TestJobHandlers verifies that a job is queued, calculated in the background, recorded and read by its tenant only.
+func TestJobHandlers(t *testing.T) {
+	registry, err := tenant.NewRegistry([]tenant.Settings{{ID: "north"}, {ID: "south"}})
+	assert.NoError(t, err)
+	store := history.NewStore(10)
+	handlers.Audit = &audit.Log{History: store}
+	handlers.Jobs = jobs.New(jobs.Config{Workers: 1, QueueSize: 1}, handlers.RunJob)
+	defer func() {
+		handlers.Jobs.Close(context.Background())
+		handlers.Audit = nil
+	}()
+
+	w := serveJobs(t, registry, "north", "POST", "/jobs", `{"order": 263, "pack_sizes": [23, 31, 53]}`)
+	assert.Equal(t, http.StatusAccepted, w.Code, "Expected the job to be accepted")
+	var job jobs.Job
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.Equal(t, "/jobs/"+job.ID, w.Header().Get("Location"), "Incorrect location")
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"), "Incorrect content type")
+
+	for deadline := time.Now().Add(5 * time.Second); !job.Finished() && time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		w = serveJobs(t, registry, "north", "GET", "/jobs/"+job.ID, "")
+		assert.Equal(t, http.StatusOK, w.Code, "Expected the job to be found")
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	}
+	assert.Equal(t, jobs.StatusSucceeded, job.Status, "Expected the job to succeed")
+	assert.Equal(t, []models.Pack{{PackSize: 23, Quantity: 2}, {PackSize: 31, Quantity: 7}}, job.Result.Packs, "Incorrect result")
+
+	record, err := store.Get("north", job.ID)
+	assert.NoError(t, err, "Expected the job in the calculation history")
+	assert.Equal(t, http.StatusOK, record.Status, "Incorrect status")
+
+	w = serveJobs(t, registry, "south", "GET", "/jobs/"+job.ID, "")
+	assert.Equal(t, http.StatusNotFound, w.Code, "Expected another tenant not to see the job")
+	w = serveJobs(t, registry, "north", "DELETE", "/jobs/"+job.ID, "")
+	assert.Equal(t, http.StatusConflict, w.Code, "Expected a finished job not to be canceled")
+	w = serveJobs(t, registry, "north", "POST", "/jobs", `{"order": "many"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Expected an invalid order to be refused")
+}
+
+// TestSubmitJobHandler_QueueFull verifies that jobs are refused with 503 Service Unavailable when no worker is free.
+func TestSubmitJobHandler_QueueFull(t *testing.T) {
+	registry, err := tenant.NewRegistry(nil)
+	assert.NoError(t, err)
+	handlers.Jobs = jobs.New(jobs.Config{}, handlers.RunJob)
+	defer handlers.Jobs.Close(context.Background())
+
+	w := serveJobs(t, registry, "", "POST", "/jobs", `{"order": 263, "pack_sizes": [23, 31, 53]}`)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "Expected the job to be refused")
+	assert.Equal(t, "1", w.Header().Get("Retry-After"), "Expected a retry delay")
+}
+
+// TestSubmitJobHandler_Callback verifies that the callback URL of a job receives the signed finished job, and that
+// callbacks are refused while webhooks are not configured.
+func TestSubmitJobHandler_Callback(t *testing.T) {
+	registry, err := tenant.NewRegistry(nil)
+	assert.NoError(t, err)
+	deliveries := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		deliveries <- r
+		bodies <- body
+	}))
+	defer server.Close()
+
+	settings := webhook.Config{AllowedHosts: []string{"127.0.0.1"}, HTTPClient: server.Client()}
+	handlers.Webhooks = webhook.New(settings)
+	w := serveJobs(t, registry, "", "POST", "/jobs", `{"order": 263, "pack_sizes": [23, 31, 53], "callback_url": "`+server.URL+`"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Expected the callback to be refused without a secret")
+
+	settings.Secrets = map[string]string{tenant.DefaultID: "secret"}
+	handlers.Webhooks = webhook.New(settings)
+	handlers.Jobs = jobs.New(jobs.Config{Workers: 1, QueueSize: 1, Notify: handlers.NotifyJob}, handlers.RunJob)
+	defer func() {
+		handlers.Jobs.Close(context.Background())
+		handlers.Webhooks.Close()
+		handlers.Webhooks = webhook.New(webhook.Config{})
+	}()
+
+	w = serveJobs(t, registry, "", "POST", "/jobs", `{"order": 263, "pack_sizes": [23, 31, 53], "callback_url": "`+server.URL+`"}`)
+	assert.Equal(t, http.StatusAccepted, w.Code, "Expected the job to be accepted")
+	w = serveJobs(t, registry, "", "POST", "/jobs", `{"order": 263, "pack_sizes": [23, 31, 53], "callback_url": "ftp://example.com"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Expected an invalid callback URL to be refused")
+
+	r, body := <-deliveries, <-bodies
+	assert.Equal(t, handlers.JobFinishedEvent, r.Header.Get(webhook.HeaderEvent), "Incorrect event")
+	assert.True(t, webhook.Verify("secret", r.Header.Get(webhook.HeaderTimestamp), r.Header.Get(webhook.HeaderSignature), body, time.Now(), time.Minute), "Expected a valid signature")
+	var event struct {
+		Event string   `json:"event"`
+		Job   jobs.Job `json:"job"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &event))
+	assert.Equal(t, jobs.StatusSucceeded, event.Job.Status, "Expected the finished job")
+	assert.Equal(t, []models.Pack{{PackSize: 23, Quantity: 2}, {PackSize: 31, Quantity: 7}}, event.Job.Result.Packs, "Incorrect result")
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-playground/validator"
+
+	"rpg/internal/packcalculator/logging"
+	"rpg/internal/packcalculator/ratelimit"
+	"rpg/internal/packcalculator/services"
+	"rpg/internal/packcalculator/tenant"
+)
+
+// LintRequest is the body of the '/lint' endpoint.
+type LintRequest struct {
+	PackSizes []int `json:"pack_sizes"`
+	Threshold int   `json:"threshold"` // Threshold is the largest order checked for sizes which are never needed.
+}
+
+// LintResponse lists the warnings about the pack sizes.
+type LintResponse struct {
+	Diagnostics []services.Diagnostic `json:"diagnostics"`
+}
+
+// LintHandler handles the '/lint' endpoint.
+func LintHandler(w http.ResponseWriter, r *http.Request) {
+	// Check if the request method is POST, return Method Not Allowed if not.
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method. Use POST.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Decode the JSON request body into a struct.
+	var request LintRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		// If there is an error decoding JSON, return a Bad Request response.
+		http.Error(w, "Error decoding JSON request", http.StatusBadRequest)
+		return
+	}
+	current := tenant.FromContext(r.Context())
+	if err := errors.Join(current.CheckPackSizes(len(request.PackSizes)), current.CheckOrder(request.Threshold, 0)); err != nil {
+		// If the pack sizes or the threshold exceed the limits of the tenant, return a Bad Request response explaining why.
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Charge the check to the computations budget of the client.
+	if !ratelimit.Compute(w, r) {
+		return
+	}
+
+	// Call the LintPackSizes function to check the pack sizes, stopping when the client goes away.
+	diagnostics, err := services.LintPackSizes(r.Context(), request.PackSizes, request.Threshold)
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) || errors.Is(err, services.ErrInvalidLintThreshold) {
+		// If the request is invalid, return a Bad Request response.
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		// If the lint was canceled, such as by the client going away, return a Service Unavailable response.
+		logging.FromContext(r.Context()).Warn("Lint canceled", "error", err)
+		http.Error(w, "Lint canceled", http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		// If an error occurs during linting, return an Internal Server Error response.
+		http.Error(w, "Error linting pack sizes", http.StatusInternalServerError)
+		return
+	}
+
+	// Always return a list so clients can iterate over it.
+	if diagnostics == nil {
+		diagnostics = []services.Diagnostic{}
+	}
+	writeJSON(w, r, LintResponse{Diagnostics: diagnostics})
+}
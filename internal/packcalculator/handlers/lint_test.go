@@ -0,0 +1,97 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/handlers"
+	"rpg/internal/packcalculator/services"
+)
+
+// TestLintHandler_ValidRequest tests the handling of a valid lint request.
+func TestLintHandler_ValidRequest(t *testing.T) {
+	// Create a test HTTP request with a duplicate pack size.
+	requestBody := `{"pack_sizes": [23, 31, 53, 31], "threshold": 100}`
+	req, err := http.NewRequest("POST", "/lint", bytes.NewBufferString(requestBody))
+	assert.NoError(t, err)
+
+	// Create a fake HTTP response.
+	w := httptest.NewRecorder()
+
+	// Call LintHandler.
+	handlers.LintHandler(w, req)
+
+	// Verify that the response status code is 200 OK.
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Parse the JSON response and check its structure.
+	var response handlers.LintResponse
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	if assert.Len(t, response.Diagnostics, 1, "unexpected number of diagnostics") {
+		assert.Equal(t, services.DiagnosticDuplicate, response.Diagnostics[0].Code, "unexpected diagnostic")
+		assert.Equal(t, 31, response.Diagnostics[0].PackSize, "unexpected pack size")
+	}
+}
+
+// TestLintHandler_NoDiagnostics tests that a clean pack-size set returns an empty list.
+func TestLintHandler_NoDiagnostics(t *testing.T) {
+	// Create a test HTTP request with coprime pack sizes.
+	requestBody := `{"pack_sizes": [23, 31, 53]}`
+	req, err := http.NewRequest("POST", "/lint", bytes.NewBufferString(requestBody))
+	assert.NoError(t, err)
+
+	// Create a fake HTTP response.
+	w := httptest.NewRecorder()
+
+	// Call LintHandler.
+	handlers.LintHandler(w, req)
+
+	// Verify that the response status code is 200 OK and the list is empty.
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"diagnostics": []}`, w.Body.String())
+}
+
+// TestLintHandler_InvalidRequest tests the handling of an invalid threshold.
+func TestLintHandler_InvalidRequest(t *testing.T) {
+	// Create a test HTTP request with a negative threshold.
+	requestBody := `{"pack_sizes": [250, 500], "threshold": -1}`
+	req, err := http.NewRequest("POST", "/lint", bytes.NewBufferString(requestBody))
+	assert.NoError(t, err)
+
+	// Create a fake HTTP response.
+	w := httptest.NewRecorder()
+
+	// Call LintHandler.
+	handlers.LintHandler(w, req)
+
+	// Verify that the response status code is 400 Bad Request.
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// TestLintHandler_TooManyPackSizes tests the handling of more pack sizes than are linted at once.
+func TestLintHandler_TooManyPackSizes(t *testing.T) {
+	// Create a test HTTP request with 101 pack sizes.
+	sizes := make([]int, 101)
+	for i := range sizes {
+		sizes[i] = i + 1
+	}
+	body, err := json.Marshal(handlers.LintRequest{PackSizes: sizes, Threshold: services.MaxLintThreshold})
+	assert.NoError(t, err)
+	req, err := http.NewRequest("POST", "/lint", bytes.NewReader(body))
+	assert.NoError(t, err)
+
+	// Create a fake HTTP response.
+	w := httptest.NewRecorder()
+
+	// Call LintHandler.
+	handlers.LintHandler(w, req)
+
+	// Verify that the response status code is 400 Bad Request.
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"rpg/internal/packcalculator/logging"
+)
+
+// writeJSON writes the value as a JSON response, logging failures with the logger of the request.
+func writeJSON(w http.ResponseWriter, r *http.Request, value any) {
+	// Convert the value to JSON format.
+	response, err := json.Marshal(value)
+	if err != nil {
+		// If encoding the response to JSON fails, return an Internal Server Error response.
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		logging.FromContext(r.Context()).Error("Error encoding response", "error", err)
+		return
+	}
+
+	// Set the Content-Type header to indicate that the response is in JSON format.
+	w.Header().Set("Content-Type", "application/json")
+
+	// Write the JSON response to the client.
+	if _, err := w.Write(response); err != nil {
+		// If writing the response fails, log the error.
+		logging.FromContext(r.Context()).Error("Error writing response", "error", err)
+	}
+}
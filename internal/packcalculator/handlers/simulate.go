@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-playground/validator"
+
+	"rpg/internal/packcalculator/analysis"
+	"rpg/internal/packcalculator/logging"
+	"rpg/internal/packcalculator/ratelimit"
+	"rpg/internal/packcalculator/services"
+	"rpg/internal/packcalculator/tenant"
+)
+
+// MaxSimulationCalculations bounds the calculations of a '/simulate' request, its distinct orders times its
+// scenarios. Zero does not bound them.
+var MaxSimulationCalculations int
+
+// ErrSimulationTooLarge is returned when a simulation needs more calculations than MaxSimulationCalculations.
+var ErrSimulationTooLarge = errors.New("simulation too large")
+
+// SimulateHandler handles the '/simulate' endpoint.
+func SimulateHandler(w http.ResponseWriter, r *http.Request) {
+	// Check if the request method is POST, return Method Not Allowed if not.
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method. Use POST.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Decode the JSON request body into a struct.
+	var request analysis.SimulationRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		// If there is an error decoding JSON, return a Bad Request response.
+		http.Error(w, "Error decoding JSON request", http.StatusBadRequest)
+		return
+	}
+
+	// Apply the tenant's solver settings and limits to every scenario and order.
+	current := tenant.FromContext(r.Context())
+	if err := checkSimulation(request, current); err != nil {
+		// If the simulation exceeds the limits of the server or the tenant, return a Bad Request response explaining why.
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	solver := Solver
+	if current.Clamp != "" {
+		solver.Clamp = current.Clamp
+	}
+
+	// Charge every scenario to the computations budget of the client.
+	for range request.Scenarios {
+		if !ratelimit.Compute(w, r) {
+			return
+		}
+	}
+
+	// Call the Simulate function to compare the scenarios, stopping when the client goes away.
+	report, err := analysis.Simulate(r.Context(), solver, request)
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) || errors.Is(err, services.ErrInvalidPackLimits) || errors.Is(err, services.ErrQuantityOverflow) {
+		// If the request is invalid, return a Bad Request response.
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, services.ErrSearchTooLarge) {
+		// If an order needs a search beyond the budget, return an Unprocessable Entity response.
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		// If the simulation was canceled, such as by the client going away, return a Service Unavailable response.
+		logging.FromContext(r.Context()).Warn("Simulation canceled", "error", err)
+		http.Error(w, "Simulation canceled", http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		// If an error occurs during simulation, log it and return an Internal Server Error response.
+		logging.FromContext(r.Context()).Error("Error simulating scenarios", "error", err)
+		http.Error(w, "Error simulating scenarios", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, report)
+}
+
+// checkSimulation checks the number of calculations against MaxSimulationCalculations, and the pack sizes of every
+// scenario and every order against the limits of the tenant.
+func checkSimulation(request analysis.SimulationRequest, current *tenant.Tenant) error {
+	distinct := make(map[int]bool, len(request.Orders))
+	for _, order := range request.Orders {
+		distinct[order] = true
+	}
+	if calculations := len(distinct) * len(request.Scenarios); MaxSimulationCalculations > 0 && calculations > MaxSimulationCalculations {
+		return fmt.Errorf("%w: %d distinct orders times %d scenarios, at most %d calculations", ErrSimulationTooLarge, len(distinct), len(request.Scenarios), MaxSimulationCalculations)
+	}
+	for _, scenario := range request.Scenarios {
+		if err := current.CheckPackSizes(len(scenario.PackSizes)); err != nil {
+			return fmt.Errorf("scenario %s: %w", scenario.Name, err)
+		}
+	}
+	for _, order := range request.Orders {
+		if err := current.CheckOrder(order, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/analysis"
+	"rpg/internal/packcalculator/handlers"
+	"rpg/internal/packcalculator/ratelimit"
+	"rpg/internal/packcalculator/tenant"
+)
+
+// TestSimulateHandler_ValidRequest tests the handling of a valid simulation request.
+func TestSimulateHandler_ValidRequest(t *testing.T) {
+	// Create a test HTTP request comparing two pack-size sets.
+	requestBody := `{"orders": [251, 500, 12001], "scenarios": [{"name": "current", "pack_sizes": [250, 500, 1000, 2000, 5000]}, {"name": "custom", "pack_sizes": [23, 31, 53]}]}`
+	req, err := http.NewRequest("POST", "/simulate", bytes.NewBufferString(requestBody))
+	assert.NoError(t, err)
+
+	// Create a fake HTTP response.
+	w := httptest.NewRecorder()
+
+	// Call SimulateHandler.
+	handlers.SimulateHandler(w, req)
+
+	// Verify that the response status code is 200 OK.
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Parse the JSON response and check its structure.
+	var report analysis.SimulationReport
+	err = json.Unmarshal(w.Body.Bytes(), &report)
+	assert.NoError(t, err)
+	assert.Equal(t, "current", report.Baseline, "unexpected baseline")
+	assert.Len(t, report.Scenarios, 2, "unexpected number of scenarios")
+	assert.Equal(t, 3, report.Scenarios[1].Fulfilled, "unexpected number of fulfilled orders")
+}
+
+// TestSimulateHandler_InvalidMethod tests the handling of an invalid request method.
+func TestSimulateHandler_InvalidMethod(t *testing.T) {
+	// Create a test HTTP request with an incorrect method.
+	req, err := http.NewRequest("GET", "/simulate", nil)
+	assert.NoError(t, err)
+
+	// Create a fake HTTP response.
+	w := httptest.NewRecorder()
+
+	// Call SimulateHandler.
+	handlers.SimulateHandler(w, req)
+
+	// Verify that the response status code is 405 Method Not Allowed.
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+// TestSimulateHandler_InvalidRequest tests the handling of a request with a single scenario.
+func TestSimulateHandler_InvalidRequest(t *testing.T) {
+	// Create a test HTTP request with a single scenario.
+	requestBody := `{"orders": [251], "scenarios": [{"name": "current", "pack_sizes": [250]}]}`
+	req, err := http.NewRequest("POST", "/simulate", bytes.NewBufferString(requestBody))
+	assert.NoError(t, err)
+
+	// Create a fake HTTP response.
+	w := httptest.NewRecorder()
+
+	// Call SimulateHandler.
+	handlers.SimulateHandler(w, req)
+
+	// Verify that the response status code is 400 Bad Request.
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// TestAnalysisHandlers_TenantLimits tests that the simulation, analysis and lint endpoints enforce the tenant's
+// limits.
+func TestAnalysisHandlers_TenantLimits(t *testing.T) {
+	registry, err := tenant.NewRegistry([]tenant.Settings{{ID: "north", MaxOrder: 1000, MaxPackSizes: 2}})
+	assert.NoError(t, err)
+	north, _ := registry.Get("north")
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		body    string
+	}{
+		{"Simulated order", handlers.SimulateHandler, `{"orders": [1001], "scenarios": [{"name": "a", "pack_sizes": [250]}, {"name": "b", "pack_sizes": [300]}]}`},
+		{"Simulated pack sizes", handlers.SimulateHandler, `{"orders": [251], "scenarios": [{"name": "a", "pack_sizes": [250]}, {"name": "b", "pack_sizes": [23, 31, 53]}]}`},
+		{"Analysed pack sizes", handlers.AnalyzeHandler, `{"pack_sizes": [23, 31, 53]}`},
+		{"Linted pack sizes", handlers.LintHandler, `{"pack_sizes": [23, 31, 53]}`},
+		{"Lint threshold", handlers.LintHandler, `{"pack_sizes": [23, 31], "threshold": 5000}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/", bytes.NewBufferString(test.body))
+			assert.NoError(t, err)
+			req = req.WithContext(tenant.WithTenant(req.Context(), north))
+			w := httptest.NewRecorder()
+
+			test.handler(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "tenant limit exceeded")
+		})
+	}
+}
+
+// TestAnalysisHandlers_Cost tests that the analysis and lint endpoints charge every check to the computations
+// budget and stop when the client goes away.
+func TestAnalysisHandlers_Cost(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		body    string
+	}{
+		{"Analysis", handlers.AnalyzeHandler, `{"pack_sizes": [23, 31, 53]}`},
+		{"Lint", handlers.LintHandler, `{"pack_sizes": [23, 31, 53], "threshold": 1000}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			serve := func(ctx context.Context, handler http.Handler) *httptest.ResponseRecorder {
+				req, err := http.NewRequestWithContext(ctx, "POST", "/", bytes.NewBufferString(test.body))
+				assert.NoError(t, err)
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, req)
+				return w
+			}
+
+			limiter := ratelimit.New(ratelimit.Config{Computations: ratelimit.Budget{Rate: 0.001, Burst: 1}})
+			handler := limiter.Middleware(test.handler)
+			assert.Equal(t, http.StatusOK, serve(context.Background(), handler).Code, "Expected the first check to be served")
+			assert.Equal(t, http.StatusTooManyRequests, serve(context.Background(), handler).Code, "Expected the second check to exhaust the budget")
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			assert.Equal(t, http.StatusServiceUnavailable, serve(ctx, test.handler).Code, "Expected a canceled check to stop")
+		})
+	}
+}
+
+// TestSimulateHandler_Cost tests that simulations are bounded by the number of calculations, charged per scenario
+// to the computations budget and stopped when the client goes away.
+func TestSimulateHandler_Cost(t *testing.T) {
+	requestBody := `{"orders": [251, 251, 500], "scenarios": [{"name": "current", "pack_sizes": [250, 500]}, {"name": "proposed", "pack_sizes": [300]}]}`
+	serve := func(ctx context.Context, handler http.Handler) *httptest.ResponseRecorder {
+		req, err := http.NewRequestWithContext(ctx, "POST", "/simulate", bytes.NewBufferString(requestBody))
+		assert.NoError(t, err)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Calculations", func(t *testing.T) {
+		handlers.MaxSimulationCalculations = 3
+		defer func() { handlers.MaxSimulationCalculations = 0 }()
+
+		w := serve(context.Background(), http.HandlerFunc(handlers.SimulateHandler))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, "Expected 2 distinct orders times 2 scenarios to be refused")
+		assert.Contains(t, w.Body.String(), handlers.ErrSimulationTooLarge.Error())
+	})
+
+	t.Run("Computations", func(t *testing.T) {
+		limiter := ratelimit.New(ratelimit.Config{Computations: ratelimit.Budget{Rate: 0.001, Burst: 3}})
+		handler := limiter.Middleware(http.HandlerFunc(handlers.SimulateHandler))
+
+		assert.Equal(t, http.StatusOK, serve(context.Background(), handler).Code, "Expected the first simulation to be served")
+		assert.Equal(t, http.StatusTooManyRequests, serve(context.Background(), handler).Code, "Expected the second scenario of the second simulation to exhaust the budget")
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		w := serve(ctx, http.HandlerFunc(handlers.SimulateHandler))
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code, "Expected a canceled simulation to stop")
+	})
+}
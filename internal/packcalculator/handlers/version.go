@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"net/http"
+
+	"rpg/internal/packcalculator/buildinfo"
+	"rpg/internal/packcalculator/services"
+)
+
+// VersionResponse describes the running build and the solvers it provides.
+type VersionResponse struct {
+	buildinfo.Info
+	Solvers []string `json:"solvers"`
+}
+
+// VersionHandler handles the '/version' endpoint.
+func VersionHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, VersionResponse{Info: buildinfo.Get(), Solvers: services.Solvers()})
+}
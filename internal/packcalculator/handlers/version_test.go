@@ -0,0 +1,38 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/buildinfo"
+	"rpg/internal/packcalculator/handlers"
+	"rpg/internal/packcalculator/services"
+)
+
+// TestVersionHandler tests that the build version and the solvers are reported.
+func TestVersionHandler(t *testing.T) {
+	// Create a test HTTP request.
+	req, err := http.NewRequest("GET", "/version", nil)
+	assert.NoError(t, err)
+
+	// Create a fake HTTP response.
+	w := httptest.NewRecorder()
+
+	// Call VersionHandler.
+	handlers.VersionHandler(w, req)
+
+	// Verify that the response status code is 200 OK.
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Parse the JSON response and check its structure.
+	var response handlers.VersionResponse
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, buildinfo.Version, response.Version, "unexpected version")
+	assert.NotEmpty(t, response.GoVersion, "missing Go version")
+	assert.Equal(t, services.Solvers(), response.Solvers, "unexpected solvers")
+}
@@ -0,0 +1,93 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CheckTimeout bounds how long a single readiness check may take.
+const CheckTimeout = 2 * time.Second
+
+// Check reports whether a dependency is ready to serve requests.
+type Check func(ctx context.Context) error
+
+// Status is the body of the health endpoints.
+type Status struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"` // Checks holds the error of every failing check.
+}
+
+// Checker decides whether the server is ready to receive traffic.
+type Checker struct {
+	mu           sync.RWMutex
+	checks       map[string]Check
+	shuttingDown atomic.Bool
+}
+
+// NewChecker creates a Checker without any checks.
+func NewChecker() *Checker {
+	return &Checker{checks: make(map[string]Check)}
+}
+
+// Register adds a readiness check for a dependency, replacing any check of the same name.
+func (c *Checker) Register(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+}
+
+// SetShuttingDown makes the server report itself as not ready, so load balancers stop sending new requests.
+func (c *Checker) SetShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+// Ready runs every check and returns the errors of those which fail.
+func (c *Checker) Ready(ctx context.Context) map[string]string {
+	failures := make(map[string]string)
+	if c.shuttingDown.Load() {
+		failures["shutdown"] = "server is shutting down"
+	}
+
+	// Copy the checks so they run without holding the lock.
+	c.mu.RLock()
+	checks := make(map[string]Check, len(c.checks))
+	for name, check := range c.checks {
+		checks[name] = check
+	}
+	c.mu.RUnlock()
+
+	for name, check := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, CheckTimeout)
+		if err := check(checkCtx); err != nil {
+			failures[name] = err.Error()
+		}
+		cancel()
+	}
+	return failures
+}
+
+// LivenessHandler handles the '/healthz' endpoint; it succeeds as long as the process serves requests.
+func LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	writeStatus(w, http.StatusOK, Status{Status: "ok"})
+}
+
+// ReadinessHandler handles the '/readyz' endpoint; it fails while a check fails or the server shuts down.
+func (c *Checker) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	if failures := c.Ready(r.Context()); len(failures) > 0 {
+		writeStatus(w, http.StatusServiceUnavailable, Status{Status: "unavailable", Checks: failures})
+		return
+	}
+	writeStatus(w, http.StatusOK, Status{Status: "ok"})
+}
+
+// writeStatus writes the status as a JSON response which is never cached.
+func writeStatus(w http.ResponseWriter, code int, status Status) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(status)
+}
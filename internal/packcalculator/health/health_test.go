@@ -0,0 +1,67 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/health"
+)
+
+// readiness calls the readiness handler and decodes its status.
+func readiness(t *testing.T, checker *health.Checker) (int, health.Status) {
+	w := httptest.NewRecorder()
+	checker.ReadinessHandler(w, httptest.NewRequest("GET", "/readyz", nil))
+
+	var status health.Status
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &status), "Invalid response")
+	return w.Code, status
+}
+
+// TestLivenessHandler verifies that the liveness probe always succeeds.
+func TestLivenessHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	health.LivenessHandler(w, httptest.NewRequest("GET", "/healthz", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status": "ok"}`, w.Body.String())
+}
+
+// TestReadinessHandler verifies that readiness follows the registered checks.
+func TestReadinessHandler(t *testing.T) {
+	checker := health.NewChecker()
+	code, status := readiness(t, checker)
+	assert.Equal(t, http.StatusOK, code, "Expected ready without checks")
+	assert.Equal(t, "ok", status.Status)
+
+	// A failing dependency makes the server unavailable.
+	var warmed bool
+	checker.Register("cache", func(ctx context.Context) error {
+		if !warmed {
+			return errors.New("cache is warming up")
+		}
+		return nil
+	})
+	code, status = readiness(t, checker)
+	assert.Equal(t, http.StatusServiceUnavailable, code, "Expected unavailable while warming up")
+	assert.Equal(t, map[string]string{"cache": "cache is warming up"}, status.Checks, "Incorrect failing checks")
+
+	warmed = true
+	code, _ = readiness(t, checker)
+	assert.Equal(t, http.StatusOK, code, "Expected ready once warmed up")
+}
+
+// TestReadinessHandler_ShuttingDown verifies that readiness fails during shutdown.
+func TestReadinessHandler_ShuttingDown(t *testing.T) {
+	checker := health.NewChecker()
+	checker.SetShuttingDown()
+
+	code, status := readiness(t, checker)
+	assert.Equal(t, http.StatusServiceUnavailable, code, "Expected unavailable during shutdown")
+	assert.Contains(t, status.Checks, "shutdown", "Missing shutdown check")
+}
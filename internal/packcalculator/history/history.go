@@ -0,0 +1,162 @@
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"rpg/internal/packcalculator/audit"
+)
+
+// Errors returned by the history.
+var (
+	ErrNotFound      = errors.New("calculation not found")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// Query selects calculations of a tenant, the most recent first. Empty fields match every calculation.
+type Query struct {
+	Tenant string
+	SKU    string
+	Client string
+	From   time.Time // From is the earliest time of a calculation, inclusive.
+	To     time.Time // To is the latest time of a calculation, exclusive.
+	Limit  int       // Limit is the maximum number of calculations of the page; zero returns all.
+	Cursor string    // Cursor continues after the previous page.
+}
+
+// Page is a page of calculations, with the cursor of the next page if there are more.
+type Page struct {
+	Calculations []audit.Record `json:"calculations"`
+	NextCursor   string         `json:"next_cursor,omitempty"`
+}
+
+// Store keeps the most recent successful calculations in memory, dropping the oldest beyond its capacity. Each
+// calculation belongs to the tenant it was made for and is only found for that tenant.
+type Store struct {
+	capacity int
+
+	mu      sync.RWMutex
+	records []audit.Record    // records are ordered from the oldest to the most recent.
+	first   uint64            // first is the sequence number of the oldest record.
+	ids     map[string]uint64 // ids are the sequence numbers of the records by their ID.
+}
+
+// NewStore returns a store keeping up to the capacity of calculations; zero or less keeps none.
+func NewStore(capacity int) *Store {
+	return &Store{capacity: capacity, first: 1, ids: make(map[string]uint64)}
+}
+
+// Add keeps the record, dropping the oldest one if the store is full.
+func (s *Store) Add(record audit.Record) {
+	if s.capacity <= 0 || record.ID == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.ids[record.ID]; ok {
+		return
+	}
+	s.ids[record.ID] = s.first + uint64(len(s.records))
+	s.records = append(s.records, record)
+	if drop := len(s.records) - s.capacity; drop > 0 {
+		for _, dropped := range s.records[:drop] {
+			delete(s.ids, dropped.ID)
+		}
+		s.records = s.records[drop:]
+		s.first += uint64(drop)
+	}
+}
+
+// Load adds the successful calculations of the audit records read from the reader, such as a previous run's
+// audit log. Records without an ID, written before calculations had one, are skipped.
+func (s *Store) Load(reader io.Reader) error {
+	decoder := json.NewDecoder(reader)
+	for line := 1; ; line++ {
+		var record audit.Record
+		err := decoder.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("record %d: %v", line, err)
+		}
+		if record.Request != nil && record.Response != nil {
+			s.Add(record)
+		}
+	}
+}
+
+// Len returns the number of calculations kept.
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.records)
+}
+
+// Get returns the calculation of the tenant with the ID.
+func (s *Store) Get(tenant, id string) (audit.Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seq, ok := s.ids[id]
+	if !ok || s.records[seq-s.first].Tenant != tenant {
+		return audit.Record{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return s.records[seq-s.first], nil
+}
+
+// List returns the page of the tenant's calculations matching the query, the most recent first.
+func (s *Store) List(query Query) (Page, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Start after the cursor, which is the sequence number of the last calculation of the previous page.
+	end := len(s.records)
+	if query.Cursor != "" {
+		seq, err := strconv.ParseUint(query.Cursor, 10, 64)
+		if err != nil || seq == 0 {
+			return Page{}, fmt.Errorf("%w: %q", ErrInvalidCursor, query.Cursor)
+		}
+		end = 0
+		if seq > s.first {
+			end = min(int(seq-s.first), len(s.records))
+		}
+	}
+
+	page := Page{Calculations: []audit.Record{}}
+	for i := end - 1; i >= 0; i-- {
+		record := s.records[i]
+		if !query.matches(record) {
+			continue
+		}
+		if query.Limit > 0 && len(page.Calculations) == query.Limit {
+			page.NextCursor = strconv.FormatUint(s.first+uint64(i)+1, 10)
+			break
+		}
+		page.Calculations = append(page.Calculations, record)
+	}
+	return page, nil
+}
+
+// matches reports whether the record is selected by the query.
+func (q Query) matches(record audit.Record) bool {
+	switch {
+	case record.Tenant != q.Tenant:
+		return false
+	case q.SKU != "" && (record.Request == nil || record.Request.SKU != q.SKU):
+		return false
+	case q.Client != "" && record.Client != q.Client:
+		return false
+	case !q.From.IsZero() && record.Time.Before(q.From):
+		return false
+	case !q.To.IsZero() && !record.Time.Before(q.To):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package history_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/audit"
+	"rpg/internal/packcalculator/history"
+	"rpg/internal/packcalculator/models"
+)
+
+// record returns a calculation of the tenant at the minute past noon on 1 March 2024.
+func record(id, tenant, sku, client string, minute int) audit.Record {
+	return audit.Record{
+		ID:       id,
+		Time:     time.Date(2024, 3, 1, 12, minute, 0, 0, time.UTC),
+		Client:   client,
+		Tenant:   tenant,
+		Status:   200,
+		Request:  &models.CalculateRequest{Order: 263, SKU: sku},
+		Response: &models.CalculateResponse{},
+	}
+}
+
+// ids returns the IDs of the calculations of the page.
+func ids(page history.Page) []string {
+	result := []string{}
+	for _, record := range page.Calculations {
+		result = append(result, record.ID)
+	}
+	return result
+}
+
+// TestStore_List verifies that calculations are filtered, ordered from the most recent and paged.
+func TestStore_List(t *testing.T) {
+	store := history.NewStore(10)
+	store.Add(record("a", "north", "WIDGET", "shop", 0))
+	store.Add(record("b", "north", "GADGET", "shop", 1))
+	store.Add(record("c", "south", "WIDGET", "shop", 2))
+	store.Add(record("d", "north", "WIDGET", "support", 3))
+	store.Add(record("e", "north", "WIDGET", "shop", 4))
+
+	page, err := store.List(history.Query{Tenant: "north"})
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, []string{"e", "d", "b", "a"}, ids(page), "Expected the tenant's calculations, the most recent first")
+	assert.Empty(t, page.NextCursor, "Expected no further page")
+
+	page, _ = store.List(history.Query{Tenant: "north", SKU: "WIDGET", Client: "shop"})
+	assert.Equal(t, []string{"e", "a"}, ids(page), "Incorrect SKU and client filter")
+
+	from := time.Date(2024, 3, 1, 12, 1, 0, 0, time.UTC)
+	page, _ = store.List(history.Query{Tenant: "north", From: from, To: from.Add(3 * time.Minute)})
+	assert.Equal(t, []string{"d", "b"}, ids(page), "Incorrect date range")
+
+	page, _ = store.List(history.Query{Tenant: "north", Limit: 3})
+	assert.Equal(t, []string{"e", "d", "b"}, ids(page), "Incorrect first page")
+	store.Add(record("f", "north", "WIDGET", "shop", 5))
+	page, _ = store.List(history.Query{Tenant: "north", Limit: 3, Cursor: page.NextCursor})
+	assert.Equal(t, []string{"a"}, ids(page), "Expected the next page to continue despite a new calculation")
+	assert.Empty(t, page.NextCursor, "Expected no further page")
+
+	_, err = store.List(history.Query{Tenant: "north", Cursor: "next"})
+	assert.ErrorIs(t, err, history.ErrInvalidCursor, "Expected an error for a malformed cursor")
+}
+
+// TestStore_Get verifies that a calculation is only found for its tenant and the oldest are dropped when full.
+func TestStore_Get(t *testing.T) {
+	store := history.NewStore(2)
+	store.Add(record("a", "north", "", "shop", 0))
+	store.Add(record("b", "north", "", "shop", 1))
+
+	found, err := store.Get("north", "a")
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, "a", found.ID, "Incorrect calculation")
+	_, err = store.Get("south", "a")
+	assert.ErrorIs(t, err, history.ErrNotFound, "Expected another tenant not to see the calculation")
+
+	store.Add(record("c", "north", "", "shop", 2))
+	_, err = store.Get("north", "a")
+	assert.ErrorIs(t, err, history.ErrNotFound, "Expected the oldest calculation to be dropped")
+	assert.Equal(t, 2, store.Len(), "Incorrect number of calculations")
+}
+
+// TestStore_Load verifies that the successful calculations of an audit log are restored.
+func TestStore_Load(t *testing.T) {
+	log := strings.Join([]string{
+		`{"id":"a","tenant":"default","status":200,"request":{"order":263,"pack_sizes":[23,31,53]},"response":{"packs":[]}}`,
+		`{"id":"b","tenant":"default","status":422,"request":{"order":1,"pack_sizes":[2]},"error":"no packing"}`,
+		`{"tenant":"default","status":200,"request":{"order":263,"pack_sizes":[23,31,53]},"response":{"packs":[]}}`,
+	}, "\n")
+
+	store := history.NewStore(10)
+	assert.NoError(t, store.Load(strings.NewReader(log)), "Unexpected error")
+	assert.Equal(t, 1, store.Len(), "Expected only the successful calculation with an ID")
+
+	assert.Error(t, store.Load(strings.NewReader("{")), "Expected an error for a malformed record")
+}
@@ -0,0 +1,262 @@
+package idempotency
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"rpg/internal/packcalculator/audit"
+	"rpg/internal/packcalculator/logging"
+	"rpg/internal/packcalculator/metrics"
+	"rpg/internal/packcalculator/tenant"
+)
+
+// Headers of idempotent requests.
+const (
+	KeyHeader      = "Idempotency-Key"     // KeyHeader carries the key a client chose for a request and its retries.
+	ReplayedHeader = "Idempotent-Replayed" // ReplayedHeader is "true" on a response replayed for a repeated key.
+)
+
+// MaxKeyLength bounds the length of a key.
+const MaxKeyLength = 255
+
+// transientHeaders describe the moment of a response rather than its outcome, so they are not replayed.
+var transientHeaders = []string{"Date", "Retry-After", "Ratelimit-Limit", "Ratelimit-Remaining", "Ratelimit-Reset"}
+
+// response is a stored response, or a request still in progress when done is false.
+type response struct {
+	key         string
+	owner       string        // owner is the tenant and client which sent the request.
+	owned       *list.Element // owned is the element of the response in the list of its owner.
+	fingerprint [sha256.Size]byte
+	expires     time.Time
+	done        bool
+	status      int
+	header      http.Header
+	body        []byte
+}
+
+// Store keeps the responses of requests by their key, so repeated requests get the first response instead of
+// being served again. Keys are kept apart by tenant and client.
+type Store struct {
+	Clock            func() time.Time // Clock returns the current time; nil uses time.Now.
+	MaxBodyBytes     int              // MaxBodyBytes is the size of the largest body kept; larger responses are not kept. Zero does not limit it.
+	MaxKeysPerClient int              // MaxKeysPerClient is the number of responses kept for each tenant and client, the oldest forgotten first. Zero does not limit it.
+
+	ttl     time.Duration
+	maxKeys int
+
+	mu        sync.Mutex
+	responses map[string]*list.Element
+	order     *list.List            // order lists the responses from the oldest to the most recent.
+	owners    map[string]*list.List // owners list the elements of each owner's responses from the oldest to the most recent.
+}
+
+// NewStore returns a store keeping up to maxKeys responses for the time to live. Zero or less maxKeys keeps none,
+// which makes the header ineffective.
+func NewStore(ttl time.Duration, maxKeys int) *Store {
+	return &Store{ttl: ttl, maxKeys: maxKeys, responses: make(map[string]*list.Element), order: list.New(), owners: make(map[string]*list.List)}
+}
+
+// Middleware makes the POST, PUT and DELETE requests carrying an Idempotency-Key header idempotent. The first
+// request with a key is served and its response stored; a repeat with the same method, path and body gets the
+// stored response again with the Idempotent-Replayed header. A repeat with another body is refused with 422
+// Unprocessable Entity and one arriving while the first is still served with 409 Conflict. Server errors, refusals
+// by the rate limits, bodies beyond the size limit and requests whose handler panicked are not stored, so a retry
+// is served again.
+func (s *Store) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(KeyHeader)
+		if key == "" || s.maxKeys <= 0 || (r.Method != http.MethodPost && r.Method != http.MethodPut && r.Method != http.MethodDelete) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > MaxKeyLength {
+			http.Error(w, "Idempotency-Key is longer than 255 characters", http.StatusBadRequest)
+			return
+		}
+
+		// Fingerprint the request by its method, path and body, then restore the body for the handler.
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			// If the body cannot be read, such as one beyond the size limit, return a Bad Request response.
+			http.Error(w, "Error reading request", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := sha256.Sum256(append([]byte(r.Method+" "+r.URL.Path+"\n"), body...))
+
+		// Keep the keys of each tenant and client apart.
+		owner := tenant.ID(r.Context()) + "\x00" + audit.Client(r)
+		scoped := owner + "\x00" + key
+		stored, found := s.begin(scoped, owner, fingerprint)
+		switch {
+		case found && stored.fingerprint != fingerprint:
+			// If the key was used for another request, return an Unprocessable Entity response.
+			metrics.IdempotentRequestsTotal.WithLabelValues("mismatch").Inc()
+			http.Error(w, "Idempotency-Key was used for a different request", http.StatusUnprocessableEntity)
+			return
+		case found && !stored.done:
+			// If the first request is still served, return a Conflict response asking to retry.
+			metrics.IdempotentRequestsTotal.WithLabelValues("in_progress").Inc()
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "A request with this Idempotency-Key is in progress", http.StatusConflict)
+			return
+		case found:
+			// Replay the stored response.
+			metrics.IdempotentRequestsTotal.WithLabelValues("replayed").Inc()
+			logging.FromContext(r.Context()).Debug("Replaying idempotent response", "key", key)
+			for name, values := range stored.header {
+				w.Header()[name] = values
+			}
+			w.Header().Set(ReplayedHeader, "true")
+			w.WriteHeader(stored.status)
+			w.Write(stored.body)
+			return
+		}
+
+		// Serve the first request with the key and store its response, forgetting the key unless it was stored, such
+		// as when the handler panics.
+		completed := false
+		defer func() {
+			if !completed {
+				s.abandon(scoped)
+			}
+		}()
+		before := w.Header().Clone()
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK, limit: s.MaxBodyBytes}
+		next.ServeHTTP(recorder, r)
+		if recorder.status >= 500 || recorder.status == http.StatusTooManyRequests {
+			return
+		}
+		if recorder.truncated {
+			metrics.IdempotentRequestsTotal.WithLabelValues("too_large").Inc()
+			logging.FromContext(r.Context()).Debug("Idempotent response too large to keep", "key", key, "max_body_bytes", s.MaxBodyBytes)
+			return
+		}
+		metrics.IdempotentRequestsTotal.WithLabelValues("stored").Inc()
+		s.complete(scoped, recorder.status, added(before, w.Header()), recorder.body.Bytes())
+		completed = true
+	})
+}
+
+// begin returns the response stored for the key, or records that a request of the owner with the key is in
+// progress, forgetting the oldest responses beyond the limits of the store and of the owner.
+func (s *Store) begin(key, owner string, fingerprint [sha256.Size]byte) (response, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.expire(now)
+
+	if element, ok := s.responses[key]; ok {
+		return *element.Value.(*response), true
+	}
+	owned, ok := s.owners[owner]
+	if !ok {
+		owned = list.New()
+		s.owners[owner] = owned
+	}
+	element := s.order.PushBack(&response{key: key, owner: owner, fingerprint: fingerprint, expires: now.Add(s.ttl)})
+	element.Value.(*response).owned = owned.PushBack(element)
+	s.responses[key] = element
+	for s.MaxKeysPerClient > 0 && owned.Len() > s.MaxKeysPerClient {
+		s.remove(owned.Front().Value.(*list.Element))
+	}
+	for s.order.Len() > s.maxKeys {
+		s.remove(s.order.Front())
+	}
+	return response{}, false
+}
+
+// complete stores the response of the key's request.
+func (s *Store) complete(key string, status int, header http.Header, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if element, ok := s.responses[key]; ok {
+		stored := element.Value.(*response)
+		stored.done, stored.status, stored.header, stored.body = true, status, header, body
+	}
+}
+
+// abandon forgets the key's request, so it is served again.
+func (s *Store) abandon(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if element, ok := s.responses[key]; ok {
+		s.remove(element)
+	}
+}
+
+// expire forgets the responses whose time to live passed; the caller holds the lock.
+func (s *Store) expire(now time.Time) {
+	for element := s.order.Front(); element != nil && !now.Before(element.Value.(*response).expires); element = s.order.Front() {
+		s.remove(element)
+	}
+}
+
+// remove forgets the response of the element; the caller holds the lock.
+func (s *Store) remove(element *list.Element) {
+	stored := element.Value.(*response)
+	s.order.Remove(element)
+	delete(s.responses, stored.key)
+	if owned := s.owners[stored.owner]; owned != nil {
+		owned.Remove(stored.owned)
+		if owned.Len() == 0 {
+			delete(s.owners, stored.owner)
+		}
+	}
+}
+
+// now returns the current time of the store's clock.
+func (s *Store) now() time.Time {
+	if s.Clock == nil {
+		return time.Now()
+	}
+	return s.Clock()
+}
+
+// added returns the headers which the handler set, leaving out those set before it and the transient ones.
+func added(before, after http.Header) http.Header {
+	header := http.Header{}
+	for name, values := range after {
+		if _, ok := before[name]; !ok {
+			header[name] = append([]string(nil), values...)
+		}
+	}
+	for _, name := range transientHeaders {
+		header.Del(name)
+	}
+	return header
+}
+
+// responseRecorder keeps the status code and body written by a handler while passing them on, up to the limit.
+type responseRecorder struct {
+	http.ResponseWriter
+	status    int
+	body      bytes.Buffer
+	limit     int  // limit is the size of the largest body kept; zero does not limit it.
+	truncated bool // truncated reports that the body outgrew the limit and was not kept.
+}
+
+// WriteHeader records the status code before writing it.
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the body, unless it outgrew the limit, before writing it.
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	switch {
+	case r.truncated:
+	case r.limit > 0 && r.body.Len()+len(p) > r.limit:
+		r.truncated = true
+		r.body = bytes.Buffer{}
+	default:
+		r.body.Write(p)
+	}
+	return r.ResponseWriter.Write(p)
+}
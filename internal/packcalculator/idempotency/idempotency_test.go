@@ -0,0 +1,190 @@
+package idempotency_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/idempotency"
+)
+
+// counter returns a handler answering with the number of requests it served, with the status.
+func counter(status int) (http.Handler, *atomic.Int32) {
+	var served atomic.Int32
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		n := served.Add(1)
+		w.Header().Set("Location", fmt.Sprintf("/jobs/%d", n))
+		w.Header().Set("RateLimit-Remaining", "9")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "%d:%s", n, body)
+	}), &served
+}
+
+// send serves a request with the key and body.
+func send(handler http.Handler, method, path, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	if key != "" {
+		req.Header.Set(idempotency.KeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+// TestMiddleware_Replay verifies that a repeated key and body replays the first response without serving it again.
+func TestMiddleware_Replay(t *testing.T) {
+	next, served := counter(http.StatusAccepted)
+	handler := idempotency.NewStore(time.Hour, 10).Middleware(next)
+
+	first := send(handler, "POST", "/jobs", "order-42", `{"order": 263}`)
+	assert.Equal(t, http.StatusAccepted, first.Code, "Incorrect status")
+	assert.Empty(t, first.Header().Get(idempotency.ReplayedHeader), "Expected the first response not to be replayed")
+
+	repeat := send(handler, "POST", "/jobs", "order-42", `{"order": 263}`)
+	assert.Equal(t, http.StatusAccepted, repeat.Code, "Expected the stored status")
+	assert.Equal(t, first.Body.String(), repeat.Body.String(), "Expected the stored body")
+	assert.Equal(t, "/jobs/1", repeat.Header().Get("Location"), "Expected the stored headers")
+	assert.Empty(t, repeat.Header().Get("RateLimit-Remaining"), "Expected transient headers not to be replayed")
+	assert.Equal(t, "true", repeat.Header().Get(idempotency.ReplayedHeader), "Expected the replayed header")
+	assert.Equal(t, int32(1), served.Load(), "Expected the request to be served once")
+
+	send(handler, "POST", "/jobs", "order-43", `{"order": 263}`)
+	send(handler, "POST", "/jobs", "", `{"order": 263}`)
+	assert.Equal(t, int32(3), served.Load(), "Expected other keys and requests without one to be served")
+}
+
+// TestMiddleware_Mismatch verifies that a key reused for another body or path is refused.
+func TestMiddleware_Mismatch(t *testing.T) {
+	next, served := counter(http.StatusOK)
+	handler := idempotency.NewStore(time.Hour, 10).Middleware(next)
+
+	send(handler, "POST", "/calculate", "order-42", `{"order": 263}`)
+	w := send(handler, "POST", "/calculate", "order-42", `{"order": 264}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, "Expected another body to be refused")
+	w = send(handler, "POST", "/jobs", "order-42", `{"order": 263}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, "Expected another path to be refused")
+	assert.Equal(t, int32(1), served.Load(), "Expected only the first request to be served")
+
+	w = send(handler, "POST", "/calculate", string(bytes.Repeat([]byte("k"), 256)), `{}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Expected a long key to be refused")
+}
+
+// TestMiddleware_InProgress verifies that a repeat arriving while the first request is served is refused.
+func TestMiddleware_InProgress(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	store := idempotency.NewStore(time.Hour, 10)
+	handler := store.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		send(handler, "POST", "/jobs", "order-42", `{}`)
+		close(done)
+	}()
+	<-entered
+
+	w := send(handler, "POST", "/jobs", "order-42", `{}`)
+	assert.Equal(t, http.StatusConflict, w.Code, "Expected a concurrent repeat to be refused")
+	assert.Equal(t, "1", w.Header().Get("Retry-After"), "Expected a retry delay")
+	close(release)
+	<-done
+}
+
+// TestMiddleware_NotStored verifies that server errors are served again, reads pass through and keys expire.
+func TestMiddleware_NotStored(t *testing.T) {
+	next, served := counter(http.StatusServiceUnavailable)
+	store := idempotency.NewStore(time.Hour, 10)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	store.Clock = func() time.Time { return now }
+	handler := store.Middleware(next)
+
+	send(handler, "POST", "/jobs", "order-42", `{}`)
+	send(handler, "POST", "/jobs", "order-42", `{}`)
+	assert.Equal(t, int32(2), served.Load(), "Expected a server error to be served again")
+
+	send(handler, "GET", "/jobs/1", "order-43", "")
+	send(handler, "GET", "/jobs/1", "order-43", "")
+	assert.Equal(t, int32(4), served.Load(), "Expected reads to pass through")
+
+	next, served = counter(http.StatusOK)
+	handler = store.Middleware(next)
+	send(handler, "POST", "/calculate", "order-44", `{}`)
+	now = now.Add(2 * time.Hour)
+	w := send(handler, "POST", "/calculate", "order-44", `{}`)
+	assert.Empty(t, w.Header().Get(idempotency.ReplayedHeader), "Expected the expired key to be served again")
+	assert.Equal(t, int32(2), served.Load(), "Incorrect number of served requests")
+}
+
+// TestMiddleware_Scope verifies that the same key from different clients names different requests.
+func TestMiddleware_Scope(t *testing.T) {
+	next, served := counter(http.StatusOK)
+	handler := idempotency.NewStore(time.Hour, 10).Middleware(next)
+
+	for _, addr := range []string{"192.0.2.1:5000", "192.0.2.2:5000", "192.0.2.1:6000"} {
+		req := httptest.NewRequest("POST", "/calculate", bytes.NewBufferString(`{}`))
+		req.RemoteAddr = addr
+		req.Header.Set(idempotency.KeyHeader, "order-42")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	assert.Equal(t, int32(2), served.Load(), "Expected each client's key to be served once")
+}
+
+// TestMiddleware_Panic verifies that the key of a request whose handler panicked is forgotten, so a retry is
+// served again instead of being refused as in progress.
+func TestMiddleware_Panic(t *testing.T) {
+	panicking := true
+	handler := idempotency.NewStore(time.Hour, 10).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if panicking {
+			panic("handler failed")
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	assert.Panics(t, func() { send(handler, "POST", "/jobs", "order-42", `{}`) }, "Expected the panic to propagate")
+	panicking = false
+	w := send(handler, "POST", "/jobs", "order-42", `{}`)
+	assert.Equal(t, http.StatusAccepted, w.Code, "Expected the retry to be served")
+}
+
+// TestMiddleware_Limits verifies that bodies beyond the size limit are not kept and that each client keeps at most
+// its own number of keys, without evicting those of other clients.
+func TestMiddleware_Limits(t *testing.T) {
+	next, served := counter(http.StatusOK)
+	store := idempotency.NewStore(time.Hour, 10)
+	store.MaxBodyBytes = 8
+	store.MaxKeysPerClient = 2
+	handler := store.Middleware(next)
+
+	first := send(handler, "POST", "/calculate", "large", `{"order": 263}`)
+	assert.Equal(t, "1:{\"order\": 263}", first.Body.String(), "Expected the whole response to be served")
+	send(handler, "POST", "/calculate", "large", `{"order": 263}`)
+	assert.Equal(t, int32(2), served.Load(), "Expected a large response to be served again")
+
+	serve := func(addr, key string) {
+		req := httptest.NewRequest("POST", "/calculate", bytes.NewBufferString(`{}`))
+		req.RemoteAddr = addr
+		req.Header.Set(idempotency.KeyHeader, key)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	serve("192.0.2.2:5000", "order-1")
+	for _, key := range []string{"order-1", "order-2", "order-3"} {
+		serve("192.0.2.1:5000", key)
+	}
+	assert.Equal(t, int32(6), served.Load(), "Incorrect number of served requests")
+
+	serve("192.0.2.1:5000", "order-3")
+	serve("192.0.2.2:5000", "order-1")
+	assert.Equal(t, int32(6), served.Load(), "Expected the recent keys of both clients to be replayed")
+	serve("192.0.2.1:5000", "order-1")
+	assert.Equal(t, int32(7), served.Load(), "Expected the client's oldest key to be forgotten")
+}
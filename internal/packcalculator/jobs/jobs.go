@@ -0,0 +1,317 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"rpg/internal/packcalculator/logging"
+	"rpg/internal/packcalculator/metrics"
+	"rpg/internal/packcalculator/models"
+)
+
+// maxSweepInterval bounds how long finished jobs are kept beyond their retention.
+const maxSweepInterval = time.Minute
+
+// Statuses of a job.
+const (
+	StatusQueued    = "queued"    // StatusQueued waits for a worker.
+	StatusRunning   = "running"   // StatusRunning is being calculated.
+	StatusSucceeded = "succeeded" // StatusSucceeded holds its result.
+	StatusFailed    = "failed"    // StatusFailed holds its error.
+	StatusCanceled  = "canceled"  // StatusCanceled was canceled by its client or by the shutdown of the server.
+)
+
+// Errors returned by the queue.
+var (
+	ErrQueueFull   = errors.New("job queue full")
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobFinished = errors.New("job already finished")
+)
+
+// Job is a calculation run in the background.
+type Job struct {
+	ID          string                    `json:"id"`
+	Tenant      string                    `json:"tenant"`
+	Client      string                    `json:"client,omitempty"`
+	RequestID   string                    `json:"request_id,omitempty"` // RequestID is the ID of the request which submitted the job.
+	Status      string                    `json:"status"`
+	Request     models.CalculateRequest   `json:"request"`
+	CallbackURL string                    `json:"callback_url,omitempty"` // CallbackURL is the URL notified when the job finished, if any.
+	Result      *models.CalculateResponse `json:"result,omitempty"`
+	Error       string                    `json:"error,omitempty"`
+	CreatedAt   time.Time                 `json:"created_at"`
+	StartedAt   *time.Time                `json:"started_at,omitempty"`
+	FinishedAt  *time.Time                `json:"finished_at,omitempty"`
+}
+
+// Finished reports whether the job will not change anymore.
+func (j Job) Finished() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusFailed || j.Status == StatusCanceled
+}
+
+// Func calculates the result of a job. It should stop when the context is canceled.
+type Func func(ctx context.Context, job Job) (models.CalculateResponse, error)
+
+// Config bounds the work of a queue.
+type Config struct {
+	Workers   int                                // Workers is the number of jobs calculated at once; zero refuses every job.
+	QueueSize int                                // QueueSize is the number of jobs waiting for a worker, beyond which jobs are refused.
+	Retention time.Duration                      // Retention is how long finished jobs are kept; zero keeps them until the queue is closed.
+	Notify    func(ctx context.Context, job Job) // Notify is called with every job once it finished, if set.
+	Logger    *slog.Logger                       // Logger is carried by the contexts of the jobs and notifications; nil uses the default logger.
+}
+
+// entry is a job with the function canceling its calculation while it runs.
+type entry struct {
+	job    Job
+	cancel context.CancelFunc
+}
+
+// Queue calculates jobs on a bounded pool of workers. Each job belongs to the tenant it was submitted for and is
+// only found for that tenant.
+type Queue struct {
+	Clock func() time.Time // Clock returns the current time; nil uses time.Now.
+
+	config Config
+	run    Func
+	queue  chan *entry
+	ctx    context.Context
+	stop   context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	jobs   map[string]*entry
+	closed bool
+}
+
+// New starts the workers of a queue calculating jobs with the function and, with a retention, the sweeper
+// forgetting the jobs which finished before it.
+func New(config Config, run Func) *Queue {
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+	ctx, stop := context.WithCancel(logging.WithLogger(context.Background(), config.Logger))
+	q := &Queue{config: config, run: run, queue: make(chan *entry, max(config.QueueSize, 0)), ctx: ctx, stop: stop, jobs: make(map[string]*entry)}
+	for i := 0; i < config.Workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	if config.Retention > 0 {
+		q.wg.Add(1)
+		go q.sweepEvery(min(config.Retention, maxSweepInterval))
+	}
+	return q
+}
+
+// Submit queues a calculation and returns its job, or refuses it when no worker is free and the queue is full.
+func (q *Queue) Submit(job Job) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.sweep()
+
+	if q.closed || q.config.Workers <= 0 {
+		return Job{}, ErrQueueFull
+	}
+	job.ID, job.Status, job.CreatedAt, job.Result, job.Error = newID(), StatusQueued, q.now(), nil, ""
+	job.StartedAt, job.FinishedAt = nil, nil
+	e := &entry{job: job}
+	select {
+	case q.queue <- e:
+	default:
+		return Job{}, fmt.Errorf("%w: %d jobs waiting", ErrQueueFull, len(q.queue))
+	}
+	q.jobs[job.ID] = e
+	metrics.JobsQueued.Inc()
+	return job, nil
+}
+
+// Get returns the job of the tenant with the ID.
+func (q *Queue) Get(tenant, id string) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	e, err := q.lookup(tenant, id)
+	if err != nil {
+		return Job{}, err
+	}
+	return e.job, nil
+}
+
+// Cancel cancels the job of the tenant with the ID. A queued job never runs and a running job is stopped, its
+// result discarded.
+func (q *Queue) Cancel(tenant, id string) (Job, error) {
+	q.mu.Lock()
+	e, err := q.lookup(tenant, id)
+	if err != nil {
+		q.mu.Unlock()
+		return Job{}, err
+	}
+	if e.job.Finished() {
+		q.mu.Unlock()
+		return e.job, fmt.Errorf("%w: %s", ErrJobFinished, e.job.Status)
+	}
+	if e.job.Status == StatusQueued {
+		metrics.JobsQueued.Dec()
+	}
+	if e.cancel != nil {
+		e.cancel()
+	}
+	q.finish(e, StatusCanceled)
+	job := e.job
+	q.mu.Unlock()
+
+	q.notify(job)
+	return job, nil
+}
+
+// Close refuses new jobs, cancels the queued and running ones and waits for the workers to stop, or until the
+// context is done, returning its error.
+func (q *Queue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.queue)
+	}
+	q.mu.Unlock()
+	q.stop()
+
+	stopped := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// work calculates the queued jobs until the queue is closed.
+func (q *Queue) work() {
+	defer q.wg.Done()
+	for e := range q.queue {
+		q.mu.Lock()
+		if e.job.Status != StatusQueued {
+			// The job was canceled while it waited.
+			q.mu.Unlock()
+			continue
+		}
+		metrics.JobsQueued.Dec()
+		if q.ctx.Err() != nil {
+			// The queue is closing; cancel the jobs still waiting.
+			q.finish(e, StatusCanceled)
+			job := e.job
+			q.mu.Unlock()
+			q.notify(job)
+			continue
+		}
+		ctx, cancel := context.WithCancel(q.ctx)
+		started := q.now()
+		e.job.Status, e.job.StartedAt, e.cancel = StatusRunning, &started, cancel
+		job := e.job
+		q.mu.Unlock()
+
+		result, err := q.run(ctx, job)
+		interrupted := ctx.Err() != nil
+		cancel()
+
+		q.mu.Lock()
+		e.cancel = nil
+		if e.job.Status != StatusRunning {
+			// The job was canceled while it ran, which notified; discard its outcome.
+			q.mu.Unlock()
+			continue
+		}
+		switch {
+		case interrupted:
+			q.finish(e, StatusCanceled)
+		case err != nil:
+			e.job.Error = err.Error()
+			q.finish(e, StatusFailed)
+		default:
+			e.job.Result = &result
+			q.finish(e, StatusSucceeded)
+		}
+		job = e.job
+		q.mu.Unlock()
+		q.notify(job)
+	}
+}
+
+// notify passes the finished job to the notification function, if any; the caller does not hold the lock.
+func (q *Queue) notify(job Job) {
+	if q.config.Notify != nil {
+		q.config.Notify(context.WithoutCancel(q.ctx), job)
+	}
+}
+
+// finish records the final status of the job; the caller holds the lock.
+func (q *Queue) finish(e *entry, status string) {
+	finished := q.now()
+	e.job.Status, e.job.FinishedAt = status, &finished
+	metrics.JobsTotal.WithLabelValues(status).Inc()
+}
+
+// lookup returns the entry of the tenant's job; the caller holds the lock.
+func (q *Queue) lookup(tenant, id string) (*entry, error) {
+	e, ok := q.jobs[id]
+	if !ok || e.job.Tenant != tenant {
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+	}
+	return e, nil
+}
+
+// sweepEvery sweeps the finished jobs at the interval until the queue is closed.
+func (q *Queue) sweepEvery(interval time.Duration) {
+	defer q.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			q.mu.Lock()
+			q.sweep()
+			q.mu.Unlock()
+		case <-q.ctx.Done():
+			return
+		}
+	}
+}
+
+// sweep forgets the jobs which finished longer ago than the retention; the caller holds the lock.
+func (q *Queue) sweep() {
+	if q.config.Retention <= 0 {
+		return
+	}
+	cutoff := q.now().Add(-q.config.Retention)
+	for id, e := range q.jobs {
+		if e.job.FinishedAt != nil && e.job.FinishedAt.Before(cutoff) {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+// now returns the current time of the queue's clock.
+func (q *Queue) now() time.Time {
+	if q.Clock == nil {
+		return time.Now().UTC()
+	}
+	return q.Clock()
+}
+
+// newID returns a random 128-bit ID in hexadecimal.
+func newID() string {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(id[:])
+}
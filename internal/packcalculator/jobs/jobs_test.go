@@ -0,0 +1,196 @@
+package jobs_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/jobs"
+	"rpg/internal/packcalculator/models"
+)
+
+// blocking returns a job function which waits for a release, or for its cancellation, before answering the order.
+func blocking(release <-chan struct{}, started chan<- string) jobs.Func {
+	return func(ctx context.Context, job jobs.Job) (models.CalculateResponse, error) {
+		started <- job.ID
+		select {
+		case <-release:
+		case <-ctx.Done():
+			return models.CalculateResponse{}, ctx.Err()
+		}
+		if job.Request.Order < 0 {
+			return models.CalculateResponse{}, errors.New("negative order")
+		}
+		return models.CalculateResponse{Packs: []models.Pack{{PackSize: job.Request.Order, Quantity: 1}}}, nil
+	}
+}
+
+// wait polls the job until it finished.
+func wait(t *testing.T, queue *jobs.Queue, tenant, id string) jobs.Job {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		job, err := queue.Get(tenant, id)
+		assert.NoError(t, err, "Unexpected error")
+		if job.Finished() {
+			return job
+		}
+	}
+	t.Fatalf("Job %s did not finish", id)
+	return jobs.Job{}
+}
+
+// TestQueue_Submit verifies that jobs are calculated in the background and only found for their tenant.
+func TestQueue_Submit(t *testing.T) {
+	release, started := make(chan struct{}), make(chan string, 2)
+	queue := jobs.New(jobs.Config{Workers: 1, QueueSize: 1}, blocking(release, started))
+	defer queue.Close(context.Background())
+
+	job, err := queue.Submit(jobs.Job{Tenant: "north", Request: models.CalculateRequest{Order: 10}})
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, jobs.StatusQueued, job.Status, "Expected a queued job")
+	assert.Equal(t, job.ID, <-started, "Expected the job to start")
+
+	running, _ := queue.Get("north", job.ID)
+	assert.Equal(t, jobs.StatusRunning, running.Status, "Expected a running job")
+	_, err = queue.Get("south", job.ID)
+	assert.ErrorIs(t, err, jobs.ErrJobNotFound, "Expected another tenant not to see the job")
+
+	failing, err := queue.Submit(jobs.Job{Tenant: "north", Request: models.CalculateRequest{Order: -1}})
+	assert.NoError(t, err, "Expected the job to wait in the queue")
+	_, err = queue.Submit(jobs.Job{Tenant: "north"})
+	assert.ErrorIs(t, err, jobs.ErrQueueFull, "Expected the full queue to refuse the job")
+
+	close(release)
+	finished := wait(t, queue, "north", job.ID)
+	assert.Equal(t, jobs.StatusSucceeded, finished.Status, "Expected the job to succeed")
+	assert.Equal(t, []models.Pack{{PackSize: 10, Quantity: 1}}, finished.Result.Packs, "Incorrect result")
+	assert.NotNil(t, finished.FinishedAt, "Expected the finish time")
+
+	<-started
+	finished = wait(t, queue, "north", failing.ID)
+	assert.Equal(t, jobs.StatusFailed, finished.Status, "Expected the job to fail")
+	assert.Equal(t, "negative order", finished.Error, "Incorrect error")
+}
+
+// TestQueue_Cancel verifies that queued and running jobs are canceled and finished jobs are not.
+func TestQueue_Cancel(t *testing.T) {
+	release, started := make(chan struct{}), make(chan string, 2)
+	queue := jobs.New(jobs.Config{Workers: 1, QueueSize: 2}, blocking(release, started))
+	defer queue.Close(context.Background())
+
+	running, _ := queue.Submit(jobs.Job{Tenant: "north", Request: models.CalculateRequest{Order: 10}})
+	<-started
+	queued, _ := queue.Submit(jobs.Job{Tenant: "north", Request: models.CalculateRequest{Order: 20}})
+
+	job, err := queue.Cancel("north", queued.ID)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, jobs.StatusCanceled, job.Status, "Expected the queued job to be canceled")
+
+	job, err = queue.Cancel("north", running.ID)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, jobs.StatusCanceled, job.Status, "Expected the running job to be canceled")
+	assert.Equal(t, jobs.StatusCanceled, wait(t, queue, "north", running.ID).Status, "Expected the canceled job to stay canceled")
+
+	_, err = queue.Cancel("north", running.ID)
+	assert.ErrorIs(t, err, jobs.ErrJobFinished, "Expected a finished job not to be canceled")
+	_, err = queue.Cancel("south", queued.ID)
+	assert.ErrorIs(t, err, jobs.ErrJobNotFound, "Expected another tenant not to cancel the job")
+
+	// The canceled job never runs, so the next one starts.
+	next, err := queue.Submit(jobs.Job{Tenant: "north", Request: models.CalculateRequest{Order: 30}})
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, next.ID, <-started, "Expected the next job to start")
+	close(release)
+	assert.Equal(t, jobs.StatusSucceeded, wait(t, queue, "north", next.ID).Status, "Expected the next job to succeed")
+}
+
+// TestQueue_Retention verifies that finished jobs are forgotten after the retention.
+func TestQueue_Retention(t *testing.T) {
+	release, started := make(chan struct{}), make(chan string, 2)
+	close(release)
+	queue := jobs.New(jobs.Config{Workers: 1, QueueSize: 1, Retention: time.Hour}, blocking(release, started))
+	defer queue.Close(context.Background())
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	queue.Clock = func() time.Time { return now }
+
+	job, _ := queue.Submit(jobs.Job{Tenant: "north", Request: models.CalculateRequest{Order: 10}})
+	<-started
+	wait(t, queue, "north", job.ID)
+
+	now = now.Add(2 * time.Hour)
+	queue.Submit(jobs.Job{Tenant: "north", Request: models.CalculateRequest{Order: 20}})
+	_, err := queue.Get("north", job.ID)
+	assert.ErrorIs(t, err, jobs.ErrJobNotFound, "Expected the finished job to be forgotten")
+}
+
+// TestQueue_Close verifies that closing the queue cancels the running job and refuses new ones.
+func TestQueue_Close(t *testing.T) {
+	release, started := make(chan struct{}), make(chan string, 2)
+	queue := jobs.New(jobs.Config{Workers: 1, QueueSize: 1}, blocking(release, started))
+
+	running, _ := queue.Submit(jobs.Job{Tenant: "north"})
+	<-started
+	assert.NoError(t, queue.Close(context.Background()), "Unexpected error")
+
+	job, _ := queue.Get("north", running.ID)
+	assert.Equal(t, jobs.StatusCanceled, job.Status, "Expected the running job to be canceled")
+	_, err := queue.Submit(jobs.Job{Tenant: "north"})
+	assert.ErrorIs(t, err, jobs.ErrQueueFull, "Expected a closed queue to refuse jobs")
+}
+
+// TestQueue_Notify verifies that every finished job is passed to the notification function, canceled ones included.
+func TestQueue_Notify(t *testing.T) {
+	release, started := make(chan struct{}), make(chan string, 2)
+	notified := make(chan jobs.Job, 2)
+	queue := jobs.New(jobs.Config{Workers: 1, QueueSize: 1, Notify: func(_ context.Context, job jobs.Job) { notified <- job }}, blocking(release, started))
+	defer queue.Close(context.Background())
+
+	running, _ := queue.Submit(jobs.Job{Tenant: "north", Request: models.CalculateRequest{Order: 10}})
+	<-started
+	queued, _ := queue.Submit(jobs.Job{Tenant: "north", Request: models.CalculateRequest{Order: 20}})
+	queue.Cancel("north", queued.ID)
+	close(release)
+
+	job := <-notified
+	assert.Equal(t, queued.ID, job.ID, "Expected the canceled job first")
+	assert.Equal(t, jobs.StatusCanceled, job.Status, "Incorrect status")
+	job = <-notified
+	assert.Equal(t, running.ID, job.ID, "Expected the running job once it finished")
+	assert.Equal(t, jobs.StatusSucceeded, job.Status, "Incorrect status")
+}
+
+// TestQueue_SweepTicker verifies that finished jobs are forgotten after the retention without further submissions.
+func TestQueue_SweepTicker(t *testing.T) {
+	release, started := make(chan struct{}), make(chan string, 1)
+	queue := jobs.New(jobs.Config{Workers: 1, QueueSize: 1, Retention: 10 * time.Millisecond}, blocking(release, started))
+	defer queue.Close(context.Background())
+
+	job, _ := queue.Submit(jobs.Job{Tenant: "north", Request: models.CalculateRequest{Order: 10}})
+	<-started
+	close(release)
+
+	assert.Eventually(t, func() bool {
+		_, err := queue.Get("north", job.ID)
+		return errors.Is(err, jobs.ErrJobNotFound)
+	}, time.Second, 5*time.Millisecond, "Expected the finished job to be forgotten")
+}
+
+// TestQueue_CloseDeadline verifies that closing the queue gives up waiting for a job which ignores its cancellation
+// once the deadline passes.
+func TestQueue_CloseDeadline(t *testing.T) {
+	release, started := make(chan struct{}), make(chan string, 1)
+	defer close(release)
+	queue := jobs.New(jobs.Config{Workers: 1, QueueSize: 1}, func(ctx context.Context, job jobs.Job) (models.CalculateResponse, error) {
+		started <- job.ID
+		<-release
+		return models.CalculateResponse{}, nil
+	})
+
+	queue.Submit(jobs.Job{Tenant: "north"})
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, queue.Close(ctx), context.DeadlineExceeded, "Expected the deadline to pass")
+}
@@ -0,0 +1,105 @@
+package logging_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/handlers"
+	"rpg/internal/packcalculator/logging"
+)
+
+// readLines decodes the JSON log lines.
+func readLines(t *testing.T, output *bytes.Buffer) []map[string]any {
+	var lines []map[string]any
+	scanner := bufio.NewScanner(output)
+	for scanner.Scan() {
+		var line map[string]any
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &line), "Invalid log line")
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// newRouter returns a router serving '/calculate' with the logging middleware.
+func newRouter(logger *slog.Logger) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/calculate", handlers.CalculateHandler).Methods("POST")
+	router.Use(logging.Middleware(logger))
+	return router
+}
+
+// TestMiddleware verifies that the request ID is propagated to the response, the solver logs and the access log.
+func TestMiddleware(t *testing.T) {
+	var output bytes.Buffer
+	router := newRouter(logging.New(&output, slog.LevelDebug))
+
+	// An order far above the sum of the sizes is clamped, which the solver logs at debug level.
+	req := httptest.NewRequest("POST", "/calculate", bytes.NewBufferString(`{"order": 500001, "pack_sizes": [250, 500, 1000, 2000, 5000]}`))
+	req.Header.Set(logging.RequestIDHeader, "order-42")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "order-42", w.Header().Get(logging.RequestIDHeader), "Request ID not returned")
+
+	lines := readLines(t, &output)
+	if assert.Len(t, lines, 2, "Unexpected number of log lines") {
+		assert.Equal(t, "Clamped order quantity", lines[0]["msg"], "Missing solver log")
+		assert.Equal(t, "order-42", lines[0]["request_id"], "Solver log not correlated")
+
+		access := lines[1]
+		assert.Equal(t, "request", access["msg"], "Missing access log")
+		assert.Equal(t, "order-42", access["request_id"], "Access log not correlated")
+		assert.Equal(t, "/calculate", access["route"], "Incorrect route")
+		assert.Equal(t, float64(http.StatusOK), access["status"], "Incorrect status")
+		assert.Contains(t, access, "latency_ms", "Missing latency")
+	}
+}
+
+// TestMiddleware_GeneratedID verifies that requests without an ID get a new one.
+func TestMiddleware_GeneratedID(t *testing.T) {
+	var output bytes.Buffer
+	router := newRouter(logging.New(&output, slog.LevelInfo))
+
+	req := httptest.NewRequest("POST", "/calculate", bytes.NewBufferString(`{`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	id := w.Header().Get(logging.RequestIDHeader)
+	assert.Len(t, id, 32, "Unexpected generated request ID")
+	lines := readLines(t, &output)
+	if assert.Len(t, lines, 1, "Unexpected number of log lines") {
+		assert.Equal(t, id, lines[0]["request_id"], "Access log not correlated")
+		assert.Equal(t, float64(http.StatusBadRequest), lines[0]["status"], "Incorrect status")
+	}
+}
+
+// TestFromContext verifies that a context without a logger falls back to the default logger.
+func TestFromContext(t *testing.T) {
+	assert.Equal(t, slog.Default(), logging.FromContext(context.Background()), "Expected the default logger")
+
+	logger := logging.New(&bytes.Buffer{}, slog.LevelInfo)
+	assert.Equal(t, logger, logging.FromContext(logging.WithLogger(context.Background(), logger)), "Expected the context logger")
+	assert.Empty(t, logging.RequestID(context.Background()), "Unexpected request ID")
+}
+
+// TestParseLevel verifies the supported level names.
+func TestParseLevel(t *testing.T) {
+	for name, expected := range map[string]slog.Level{"": slog.LevelInfo, "debug": slog.LevelDebug, "WARN": slog.LevelWarn, "error": slog.LevelError} {
+		level, err := logging.ParseLevel(name)
+		assert.NoError(t, err, "Unexpected error for %q", name)
+		assert.Equal(t, expected, level, "Incorrect level for %q", name)
+	}
+
+	_, err := logging.ParseLevel("verbose")
+	assert.Error(t, err, "Expected an error for an unknown level")
+}
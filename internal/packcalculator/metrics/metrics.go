@@ -0,0 +1,223 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace prefixes the name of every metric.
+const Namespace = "rpg"
+
+// Registry holds the metrics exposed on the '/metrics' endpoint.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// RequestsTotal counts the HTTP requests by route, method and status.
+	RequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+
+	// RequestDuration observes the HTTP request latency by route, method and status.
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by route, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	// TenantRequestsTotal counts the API requests by tenant, route and status.
+	TenantRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "tenant_requests_total",
+		Help:      "Number of API requests by tenant, route and status.",
+	}, []string{"tenant", "route", "status"})
+
+	// CacheLookupsTotal counts the lookups of the result cache by tenant and result, "hit" or "miss".
+	CacheLookupsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Number of result cache lookups by tenant and result.",
+	}, []string{"tenant", "result"})
+
+	// CacheEntries counts the responses held by the result cache.
+	CacheEntries = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "cache_entries",
+		Help:      "Number of responses held by the result cache.",
+	})
+
+	// RateLimitedTotal counts the requests refused by the rate limiter by the budget which was empty.
+	RateLimitedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "rate_limited_total",
+		Help:      "Number of requests refused by the rate limiter by budget.",
+	}, []string{"budget"})
+
+	// JobsQueued counts the jobs waiting for a worker.
+	JobsQueued = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "jobs_queued",
+		Help:      "Number of calculation jobs waiting for a worker.",
+	})
+
+	// JobsTotal counts the finished jobs by status.
+	JobsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "jobs_total",
+		Help:      "Number of finished calculation jobs by status.",
+	}, []string{"status"})
+
+	// WebhookDeliveriesTotal counts the webhook deliveries by result, delivered or dead-lettered.
+	WebhookDeliveriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Number of webhook deliveries by result.",
+	}, []string{"result"})
+
+	// IdempotentRequestsTotal counts the requests carrying an idempotency key by result.
+	IdempotentRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "idempotent_requests_total",
+		Help:      "Number of requests with an idempotency key by result.",
+	}, []string{"result"})
+
+	// CalculationDuration observes how long the calculator takes for a single order.
+	CalculationDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "calculation_duration_seconds",
+		Help:      "Duration of pack calculations.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 10),
+	})
+
+	// CalculationsInFlight counts the calculations currently running.
+	CalculationsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "calculations_in_flight",
+		Help:      "Number of pack calculations currently running.",
+	})
+
+	// GraphNodes observes the number of nodes of each quantity graph.
+	GraphNodes = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "graph_nodes",
+		Help:      "Number of nodes in the quantity graph of a calculation.",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 12),
+	})
+
+	// GraphEdges observes the number of edges of each quantity graph.
+	GraphEdges = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "graph_edges",
+		Help:      "Number of edges in the quantity graph of a calculation.",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 12),
+	})
+)
+
+func init() {
+	// Register the application metrics together with the Go runtime and process metrics.
+	Registry.MustRegister(
+		RequestsTotal,
+		RequestDuration,
+		TenantRequestsTotal,
+		CacheLookupsTotal,
+		CacheEntries,
+		RateLimitedTotal,
+		JobsQueued,
+		JobsTotal,
+		WebhookDeliveriesTotal,
+		IdempotentRequestsTotal,
+		CalculationDuration,
+		CalculationsInFlight,
+		GraphNodes,
+		GraphEdges,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler returns the handler of the '/metrics' endpoint.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// Middleware records the count and latency of every request routed by mux.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		route, status := routeLabel(r), strconv.Itoa(recorder.status)
+		RequestsTotal.WithLabelValues(route, r.Method, status).Inc()
+		RequestDuration.WithLabelValues(route, r.Method, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// TenantMiddleware returns a middleware counting the requests by the tenant the function returns for their context.
+// It runs after the tenant was selected; tenant IDs are validated, so they cannot create unbounded series.
+func TenantMiddleware(tenantOf func(context.Context) string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+			TenantRequestsTotal.WithLabelValues(tenantOf(r.Context()), routeLabel(r), strconv.Itoa(recorder.status)).Inc()
+		})
+	}
+}
+
+// routeLabel returns the route template of the request so that paths and their parameters do not create new series.
+func routeLabel(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return "unmatched"
+}
+
+// ObserveCalculation marks a calculation as running and returns a function recording its duration once it ends.
+func ObserveCalculation() func() {
+	start := time.Now()
+	CalculationsInFlight.Inc()
+	return func() {
+		CalculationsInFlight.Dec()
+		CalculationDuration.Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveCacheLookup records a lookup of the tenant in the result cache and the number of cached responses.
+func ObserveCacheLookup(tenant string, hit bool, entries int) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	CacheLookupsTotal.WithLabelValues(tenant, result).Inc()
+	CacheEntries.Set(float64(entries))
+}
+
+// ObserveGraph records the size of a quantity graph.
+func ObserveGraph(nodes, edges int) {
+	GraphNodes.Observe(float64(nodes))
+	GraphEdges.Observe(float64(edges))
+}
+
+// statusRecorder remembers the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code before writing it.
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,63 @@
+package metrics_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/metrics"
+	"rpg/internal/packcalculator/services"
+)
+
+// TestMiddleware verifies that requests are counted by route template and status.
+func TestMiddleware(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/orders/{id}", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}).Methods("GET")
+	router.Use(metrics.Middleware)
+
+	counter := metrics.RequestsTotal.WithLabelValues("/orders/{id}", "GET", "404")
+	before := testutil.ToFloat64(counter)
+
+	for _, path := range []string{"/orders/1", "/orders/2"} {
+		req := httptest.NewRequest("GET", path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	assert.Equal(t, before+2, testutil.ToFloat64(counter), "Incorrect request count")
+}
+
+// TestHandler verifies that the calculator metrics are exposed after a calculation.
+func TestHandler(t *testing.T) {
+	_, err := services.CalculatePacks(12001, []int{250, 500, 1000, 2000, 5000})
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.CalculationsInFlight), "Calculations still in flight")
+
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	for _, name := range []string{"rpg_calculation_duration_seconds_count", "rpg_graph_nodes_count", "rpg_graph_edges_count", "rpg_calculations_in_flight", "go_goroutines"} {
+		assert.Contains(t, w.Body.String(), name, "Missing metric")
+	}
+}
+
+// TestTenantMiddleware verifies that requests are counted by the tenant of their context.
+func TestTenantMiddleware(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/catalog/products", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+	router.Use(metrics.TenantMiddleware(func(context.Context) string { return "north" }))
+
+	counter := metrics.TenantRequestsTotal.WithLabelValues("north", "/catalog/products", "200")
+	before := testutil.ToFloat64(counter)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/catalog/products", nil))
+
+	assert.Equal(t, before+1, testutil.ToFloat64(counter), "Incorrect tenant request count")
+}
@@ -1,15 +1,395 @@
 package models
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
 // RequiredPacks is a map of pack sizes and the number required.
 type RequiredPacks map[int]int
 
 // Pack represents information about a pack size and quantity.
 type Pack struct {
-	PackSize int `json:"pack_size"`
-	Quantity int `json:"quantity"`
+	PackSize  int    `json:"pack_size"`
+	Quantity  int    `json:"quantity"`
+	Size      string `json:"size,omitempty"`      // Size is the decimal pack size in Unit.
+	Unit      Unit   `json:"unit,omitempty"`      // Unit is the unit of measure of the pack size.
+	Precision int    `json:"precision,omitempty"` // Precision is the number of decimal places PackSize is scaled by.
+}
+
+// DescribePacks sets the unit, precision and decimal size of the packs.
+func DescribePacks(packs []Pack, unit Unit, precision int) {
+	for i := range packs {
+		packs[i].Size = FormatDecimal(packs[i].PackSize, precision)
+		packs[i].Unit = unit
+		packs[i].Precision = precision
+	}
+}
+
+// PackSize represents an available pack size with optional physical attributes.
+type PackSize struct {
+	Size   int     `json:"size" validate:"gt=0"`
+	Weight float64 `json:"weight,omitempty" validate:"gte=0"` // Weight is the weight of a single pack.
+	Length float64 `json:"length,omitempty" validate:"gte=0"` // Length, Width and Height are the outer dimensions of a single pack.
+	Width  float64 `json:"width,omitempty" validate:"gte=0"`
+	Height float64 `json:"height,omitempty" validate:"gte=0"`
+
+	Unit Unit `json:"unit,omitempty"` // Unit is the unit of measure of the size, defaulting to the order's unit.
+
+	MinCount int  `json:"min_count,omitempty" validate:"gte=0"`           // MinCount is the number of packs always included in an order.
+	MaxCount *int `json:"max_count,omitempty" validate:"omitempty,gte=0"` // MaxCount caps the number of packs per order.
+}
+
+// PackLimit represents the minimum and maximum number of packs of a size per order.
+type PackLimit struct {
+	Min int  // Min is the number of packs always included.
+	Max *int // Max is the maximum number of packs, or nil if unlimited.
+}
+
+// UnmarshalJSON accepts either a bare number or an object with physical attributes.
+func (p *PackSize) UnmarshalJSON(data []byte) error {
+	// Keep backward compatibility with plain numeric pack sizes.
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] != '{' {
+		*p = PackSize{}
+		return json.Unmarshal(trimmed, &p.Size)
+	}
+
+	// Decode through an alias type to avoid recursing into this method.
+	var decoded packSizeFields
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*p = PackSize(decoded)
+	return nil
+}
+
+// packSizeFields has the fields of PackSize without its JSON methods.
+type packSizeFields PackSize
+
+// Volume returns the volume of a single pack, or zero if dimensions are not set.
+func (p PackSize) Volume() float64 {
+	return p.Length * p.Width * p.Height
 }
 
-// CalculateResponse represents the JSON response structure.
+// NewPackSizes converts plain pack sizes into PackSize values without physical attributes.
+func NewPackSizes(sizes []int) []PackSize {
+	packSizes := make([]PackSize, len(sizes))
+	for i, size := range sizes {
+		packSizes[i] = PackSize{Size: size}
+	}
+	return packSizes
+}
+
+// Sizes returns the plain sizes of the given pack sizes.
+func Sizes(packSizes []PackSize) []int {
+	sizes := make([]int, len(packSizes))
+	for i, packSize := range packSizes {
+		sizes[i] = packSize.Size
+	}
+	return sizes
+}
+
+// Limits returns the pack limits of the given pack sizes, keeping the first occurrence of duplicates.
+func Limits(packSizes []PackSize) map[int]PackLimit {
+	limits := make(map[int]PackLimit)
+	seen := make(map[int]bool)
+	for _, packSize := range packSizes {
+		if seen[packSize.Size] {
+			continue
+		}
+		seen[packSize.Size] = true
+		if packSize.MinCount > 0 || packSize.MaxCount != nil {
+			limits[packSize.Size] = PackLimit{Min: packSize.MinCount, Max: packSize.MaxCount}
+		}
+	}
+	return limits
+}
+
+// ShipmentConstraints represents carrier limits applied when grouping packs into parcels.
+type ShipmentConstraints struct {
+	MaxParcelWeight   float64 `json:"max_parcel_weight,omitempty" validate:"gte=0"`    // MaxParcelWeight is the maximum total weight of a parcel.
+	MaxParcelVolume   float64 `json:"max_parcel_volume,omitempty" validate:"gte=0"`    // MaxParcelVolume is the maximum total volume of a parcel.
+	MaxPacksPerParcel int     `json:"max_packs_per_parcel,omitempty" validate:"gte=0"` // MaxPacksPerParcel is the maximum number of packs in a parcel.
+}
+
+// Parcel represents a group of packs shipped together.
+type Parcel struct {
+	Packs  []Pack  `json:"packs"`
+	Weight float64 `json:"weight,omitempty"`
+	Volume float64 `json:"volume,omitempty"`
+}
+
+// FulfilmentPolicy represents how far a packing may deviate from the ordered quantity. Within a CalculateRequest the
+// shortfall and surplus are decimals in the order's unit in JSON, scaled by 10^Precision once decoded.
+type FulfilmentPolicy struct {
+	AllowedShortfall  int      `json:"allowed_shortfall,omitempty" validate:"gte=0"`             // AllowedShortfall is the quantity that may be left unshipped.
+	MaxSurplus        *int     `json:"max_surplus,omitempty" validate:"omitempty,gte=0"`         // MaxSurplus caps the shipped quantity above the order.
+	MaxSurplusPercent *float64 `json:"max_surplus_percent,omitempty" validate:"omitempty,gte=0"` // MaxSurplusPercent caps the surplus relative to the order.
+	ExactOnly         bool     `json:"exact_only,omitempty"`                                     // ExactOnly requires the shipped quantity to match the order.
+}
+
+// CalculateRequest represents the JSON request structure. In JSON the order and pack sizes are decimals
+// in their units; once decoded they are integers in the order's unit, scaled by 10^Precision.
+type CalculateRequest struct {
+	Order       int                  `json:"order"`
+	PackSizes   []PackSize           `json:"pack_sizes"`
+	Unit        Unit                 `json:"unit,omitempty"`
+	Precision   int                  `json:"precision,omitempty" validate:"gte=0,lte=9"`
+	Constraints *ShipmentConstraints `json:"constraints,omitempty"`
+	Policy      *FulfilmentPolicy    `json:"policy,omitempty"`
+	Clamp       string               `json:"clamp,omitempty"` // Clamp names the clamp strategy of the calculation, or is empty for the default.
+	SKU         string               `json:"sku,omitempty"`   // SKU names a product of the catalog whose pack sizes are used.
+}
+
+// calculateRequestFields has the fields of CalculateRequest without its JSON methods.
+type calculateRequestFields CalculateRequest
+
+// decimalPolicy is a FulfilmentPolicy whose shortfall and surplus are decimals in the order's unit.
+type decimalPolicy struct {
+	AllowedShortfall  json.Number  `json:"allowed_shortfall,omitempty"`
+	MaxSurplus        *json.Number `json:"max_surplus,omitempty"`
+	MaxSurplusPercent *float64     `json:"max_surplus_percent,omitempty"`
+	ExactOnly         bool         `json:"exact_only,omitempty"`
+}
+
+// UnmarshalJSON decodes decimal quantities, converts pack sizes to the order's unit and scales them to integers.
+func (r *CalculateRequest) UnmarshalJSON(data []byte) error {
+	// Decode the order and pack sizes separately from the remaining fields.
+	var raw struct {
+		calculateRequestFields
+		Order     json.Number       `json:"order"`
+		PackSizes []json.RawMessage `json:"pack_sizes"`
+		Policy    *decimalPolicy    `json:"policy"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	request := CalculateRequest(raw.calculateRequestFields)
+	request.Policy = nil
+	if request.Precision < 0 || request.Precision > MaxPrecision {
+		return fmt.Errorf("%w: precision %d is outside 0 to %d", ErrInvalidQuantity, request.Precision, MaxPrecision)
+	}
+
+	// Parse the order in its own unit.
+	order := new(big.Rat)
+	if raw.Order != "" {
+		var err error
+		if order, err = ParseDecimal(raw.Order.String()); err != nil {
+			return err
+		}
+	}
+	if _, ok := unitScales[request.Unit]; !ok {
+		return fmt.Errorf("%w: unknown unit %q", ErrInvalidQuantity, request.Unit)
+	}
+
+	// Parse the pack sizes and convert them to the order's unit.
+	request.PackSizes = make([]PackSize, len(raw.PackSizes))
+	sizes := make([]*big.Rat, len(raw.PackSizes))
+	for i, message := range raw.PackSizes {
+		size, packSize, err := decodeDecimalPackSize(message)
+		if err != nil {
+			return err
+		}
+		if packSize.Unit == UnitPiece {
+			packSize.Unit = request.Unit
+		}
+		if sizes[i], err = packSize.Unit.Convert(size, request.Unit); err != nil {
+			return err
+		}
+		packSize.Unit = UnitPiece
+		request.PackSizes[i] = packSize
+	}
+
+	// Parse the shortfall and surplus of the policy in the order's unit.
+	var shortfall, surplus *big.Rat
+	values := append([]*big.Rat{order}, sizes...)
+	if raw.Policy != nil {
+		var err error
+		if shortfall, err = parseOptionalDecimal(raw.Policy.AllowedShortfall); err != nil {
+			return err
+		}
+		if raw.Policy.MaxSurplus != nil {
+			if surplus, err = ParseDecimal(raw.Policy.MaxSurplus.String()); err != nil {
+				return err
+			}
+			values = append(values, surplus)
+		}
+		values = append(values, shortfall)
+	}
+
+	// Use the requested precision, or the fewest decimal places which represent every value exactly.
+	if raw.Precision == 0 {
+		for _, value := range values {
+			places, err := DecimalPlaces(value)
+			if err != nil {
+				return err
+			}
+			request.Precision = max(request.Precision, places)
+		}
+	}
+
+	// Scale the values to integers.
+	var err error
+	if request.Order, err = ScaleDecimal(order, request.Precision); err != nil {
+		return err
+	}
+	for i, size := range sizes {
+		if request.PackSizes[i].Size, err = ScaleDecimal(size, request.Precision); err != nil {
+			return err
+		}
+	}
+	if raw.Policy != nil {
+		policy := FulfilmentPolicy{MaxSurplusPercent: raw.Policy.MaxSurplusPercent, ExactOnly: raw.Policy.ExactOnly}
+		if policy.AllowedShortfall, err = ScaleDecimal(shortfall, request.Precision); err != nil {
+			return err
+		}
+		if surplus != nil {
+			policy.MaxSurplus = new(int)
+			if *policy.MaxSurplus, err = ScaleDecimal(surplus, request.Precision); err != nil {
+				return err
+			}
+		}
+		request.Policy = &policy
+	}
+
+	*r = request
+	return nil
+}
+
+// MarshalJSON encodes the order and pack sizes as decimals in the order's unit.
+func (r CalculateRequest) MarshalJSON() ([]byte, error) {
+	type decimalPackSize struct {
+		packSizeFields
+		Size json.Number `json:"size"`
+	}
+	packSizes := make([]decimalPackSize, len(r.PackSizes))
+	for i, packSize := range r.PackSizes {
+		packSizes[i] = decimalPackSize{packSizeFields: packSizeFields(packSize), Size: json.Number(FormatDecimal(packSize.Size, r.Precision))}
+	}
+
+	var policy *decimalPolicy
+	if r.Policy != nil {
+		policy = &decimalPolicy{
+			AllowedShortfall:  optionalDecimal(r.Policy.AllowedShortfall, r.Precision),
+			MaxSurplusPercent: r.Policy.MaxSurplusPercent,
+			ExactOnly:         r.Policy.ExactOnly,
+		}
+		if r.Policy.MaxSurplus != nil {
+			surplus := json.Number(FormatDecimal(*r.Policy.MaxSurplus, r.Precision))
+			policy.MaxSurplus = &surplus
+		}
+	}
+
+	return json.Marshal(struct {
+		calculateRequestFields
+		Order     json.Number       `json:"order"`
+		PackSizes []decimalPackSize `json:"pack_sizes"`
+		Policy    *decimalPolicy    `json:"policy,omitempty"`
+	}{
+		calculateRequestFields: calculateRequestFields(r),
+		Order:                  json.Number(FormatDecimal(r.Order, r.Precision)),
+		PackSizes:              packSizes,
+		Policy:                 policy,
+	})
+}
+
+// decodeDecimalPackSize decodes a pack size given as a bare number or an object, returning its decimal size.
+func decodeDecimalPackSize(data json.RawMessage) (*big.Rat, PackSize, error) {
+	// Bare numbers carry no attributes.
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] != '{' {
+		var size json.Number
+		if err := json.Unmarshal(trimmed, &size); err != nil {
+			return nil, PackSize{}, err
+		}
+		value, err := ParseDecimal(size.String())
+		return value, PackSize{}, err
+	}
+
+	// Objects carry the decimal size next to the other attributes.
+	var object struct {
+		packSizeFields
+		Size json.Number `json:"size"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, PackSize{}, err
+	}
+	value, err := ParseDecimal(object.Size.String())
+	return value, PackSize(object.packSizeFields), err
+}
+
+// CalculateResponse represents the JSON response structure. In JSON the shortfall and surplus are decimals in the
+// order's unit; once decoded they are integers scaled by 10^Precision like the pack sizes.
 type CalculateResponse struct {
-	Packs []Pack `json:"packs"`
+	Packs     []Pack   `json:"packs"`
+	Parcels   []Parcel `json:"parcels,omitempty"`
+	Shortfall int      `json:"shortfall,omitempty"`
+	Surplus   int      `json:"surplus,omitempty"`
+	Unit      Unit     `json:"unit,omitempty"`
+	Precision int      `json:"precision,omitempty"`
+}
+
+// calculateResponseFields has the fields of CalculateResponse without its JSON methods.
+type calculateResponseFields CalculateResponse
+
+// MarshalJSON encodes the shortfall and surplus as decimals in the order's unit.
+func (r CalculateResponse) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		calculateResponseFields
+		Shortfall json.Number `json:"shortfall,omitempty"`
+		Surplus   json.Number `json:"surplus,omitempty"`
+	}{
+		calculateResponseFields: calculateResponseFields(r),
+		Shortfall:               optionalDecimal(r.Shortfall, r.Precision),
+		Surplus:                 optionalDecimal(r.Surplus, r.Precision),
+	})
+}
+
+// UnmarshalJSON decodes the decimal shortfall and surplus and scales them to integers.
+func (r *CalculateResponse) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		calculateResponseFields
+		Shortfall json.Number `json:"shortfall"`
+		Surplus   json.Number `json:"surplus"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	response := CalculateResponse(raw.calculateResponseFields)
+	var err error
+	if response.Shortfall, err = scaleOptionalDecimal(raw.Shortfall, response.Precision); err != nil {
+		return err
+	}
+	if response.Surplus, err = scaleOptionalDecimal(raw.Surplus, response.Precision); err != nil {
+		return err
+	}
+
+	*r = response
+	return nil
+}
+
+// optionalDecimal formats an integer scaled by 10^precision as a decimal, or as nothing when it is zero.
+func optionalDecimal(value int, precision int) json.Number {
+	if value == 0 {
+		return ""
+	}
+	return json.Number(FormatDecimal(value, precision))
+}
+
+// scaleOptionalDecimal parses a decimal and scales it by 10^precision, returning zero when there is none.
+func scaleOptionalDecimal(text json.Number, precision int) (int, error) {
+	value, err := parseOptionalDecimal(text)
+	if err != nil {
+		return 0, err
+	}
+	return ScaleDecimal(value, precision)
+}
+
+// parseOptionalDecimal parses a decimal, returning zero when there is none.
+func parseOptionalDecimal(text json.Number) (*big.Rat, error) {
+	if text == "" {
+		return new(big.Rat), nil
+	}
+	return ParseDecimal(text.String())
 }
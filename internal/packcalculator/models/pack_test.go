@@ -0,0 +1,139 @@
+package models_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/models"
+)
+
+// TestPackSize_UnmarshalJSON verifies that pack sizes decode from both numbers and objects.
+func TestPackSize_UnmarshalJSON(t *testing.T) {
+	var packSizes []models.PackSize
+	err := json.Unmarshal([]byte(`[250, {"size": 500, "weight": 1.5, "length": 2, "width": 3, "height": 4}]`), &packSizes)
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, []models.PackSize{
+		{Size: 250},
+		{Size: 500, Weight: 1.5, Length: 2, Width: 3, Height: 4},
+	}, packSizes, "Incorrect pack sizes")
+	assert.Equal(t, 24.0, packSizes[1].Volume(), "Incorrect volume")
+}
+
+// TestPackSize_UnmarshalJSONInvalid verifies that invalid pack sizes are rejected.
+func TestPackSize_UnmarshalJSONInvalid(t *testing.T) {
+	var packSizes []models.PackSize
+	err := json.Unmarshal([]byte(`["large"]`), &packSizes)
+
+	assert.Error(t, err, "Expected error for a non-numeric pack size")
+}
+
+// TestCalculateRequest_UnmarshalJSON verifies that decimal quantities are converted and scaled.
+func TestCalculateRequest_UnmarshalJSON(t *testing.T) {
+	// Subtest: integer requests are unchanged.
+	t.Run("Integers", func(t *testing.T) {
+		var request models.CalculateRequest
+		err := json.Unmarshal([]byte(`{"order": 263, "pack_sizes": [23, {"size": 31, "weight": 2}, 53]}`), &request)
+
+		assert.NoError(t, err, "Unexpected error")
+		assert.Equal(t, models.CalculateRequest{
+			Order:     263,
+			PackSizes: []models.PackSize{{Size: 23}, {Size: 31, Weight: 2}, {Size: 53}},
+		}, request, "Incorrect request")
+	})
+
+	// Subtest: decimals in compatible units are converted to the order's unit.
+	t.Run("Decimals with units", func(t *testing.T) {
+		var request models.CalculateRequest
+		err := json.Unmarshal([]byte(`{"order": 3.2, "unit": "l", "pack_sizes": [0.5, 2.5, {"size": 250, "unit": "ml"}]}`), &request)
+
+		assert.NoError(t, err, "Unexpected error")
+		assert.Equal(t, models.CalculateRequest{
+			Order:     320,
+			Unit:      models.UnitLitre,
+			Precision: 2,
+			PackSizes: []models.PackSize{{Size: 50}, {Size: 250}, {Size: 25}},
+		}, request, "Incorrect request")
+	})
+
+	// Subtest: an explicit precision is kept.
+	t.Run("Explicit precision", func(t *testing.T) {
+		var request models.CalculateRequest
+		err := json.Unmarshal([]byte(`{"order": 1, "unit": "kg", "precision": 3, "pack_sizes": [0.5]}`), &request)
+
+		assert.NoError(t, err, "Unexpected error")
+		assert.Equal(t, 1000, request.Order, "Incorrect order")
+		assert.Equal(t, 500, request.PackSizes[0].Size, "Incorrect pack size")
+	})
+
+	// Subtest: the shortfall and surplus of the policy are decimals in the order's unit.
+	t.Run("Decimal policy", func(t *testing.T) {
+		var request models.CalculateRequest
+		err := json.Unmarshal([]byte(`{"order": 2.6, "unit": "l", "pack_sizes": [1, 2.5], "policy": {"allowed_shortfall": 0.1, "max_surplus": 0.05, "max_surplus_percent": 10}}`), &request)
+
+		assert.NoError(t, err, "Unexpected error")
+		assert.Equal(t, 2, request.Precision, "Expected the surplus to widen the precision")
+		assert.Equal(t, 260, request.Order, "Incorrect order")
+		assert.Equal(t, 10, request.Policy.AllowedShortfall, "Incorrect shortfall")
+		assert.Equal(t, 5, *request.Policy.MaxSurplus, "Incorrect surplus")
+		assert.Equal(t, 10.0, *request.Policy.MaxSurplusPercent, "Percentages are not scaled")
+	})
+
+	// Subtest: invalid quantities are rejected.
+	for name, body := range map[string]string{
+		"Incompatible units":     `{"order": 1, "unit": "kg", "pack_sizes": [{"size": 1, "unit": "l"}]}`,
+		"Unknown unit":           `{"order": 1, "unit": "oz", "pack_sizes": [1]}`,
+		"Insufficient precision": `{"order": 1.25, "precision": 1, "pack_sizes": [1]}`,
+		"Policy precision":       `{"order": 1, "precision": 1, "pack_sizes": [1], "policy": {"allowed_shortfall": 0.25}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			var request models.CalculateRequest
+			err := json.Unmarshal([]byte(body), &request)
+
+			assert.ErrorIs(t, err, models.ErrInvalidQuantity, "Expected error for an invalid quantity")
+		})
+	}
+}
+
+// TestCalculateRequest_MarshalJSON verifies that encoding and decoding a request round-trips.
+func TestCalculateRequest_MarshalJSON(t *testing.T) {
+	surplus := 50
+	request := models.CalculateRequest{
+		Order:     320,
+		Unit:      models.UnitLitre,
+		Precision: 2,
+		PackSizes: []models.PackSize{{Size: 50, Weight: 0.6}, {Size: 250}},
+		Policy:    &models.FulfilmentPolicy{AllowedShortfall: 5, MaxSurplus: &surplus},
+	}
+
+	data, err := json.Marshal(request)
+	assert.NoError(t, err, "Unexpected error")
+	assert.JSONEq(t, `{"order": 3.2, "unit": "l", "precision": 2, "pack_sizes": [{"size": 0.5, "weight": 0.6}, {"size": 2.5}], "policy": {"allowed_shortfall": 0.05, "max_surplus": 0.5}}`, string(data), "Incorrect JSON")
+
+	var decoded models.CalculateRequest
+	err = json.Unmarshal(data, &decoded)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, request, decoded, "Request did not round-trip")
+}
+
+// TestCalculateResponse_MarshalJSON verifies that the shortfall and surplus are encoded in the order's unit and
+// round-trip.
+func TestCalculateResponse_MarshalJSON(t *testing.T) {
+	response := models.CalculateResponse{
+		Packs:     []models.Pack{{PackSize: 50, Quantity: 6, Size: "0.5", Unit: models.UnitLitre, Precision: 2}},
+		Surplus:   25,
+		Unit:      models.UnitLitre,
+		Precision: 2,
+	}
+
+	data, err := json.Marshal(response)
+	assert.NoError(t, err, "Unexpected error")
+	assert.JSONEq(t, `{"packs": [{"pack_size": 50, "quantity": 6, "size": "0.5", "unit": "l", "precision": 2}], "surplus": 0.25, "unit": "l", "precision": 2}`, string(data), "Incorrect JSON")
+
+	var decoded models.CalculateResponse
+	err = json.Unmarshal(data, &decoded)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, response, decoded, "Response did not round-trip")
+}
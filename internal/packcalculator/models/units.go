@@ -0,0 +1,140 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// MaxPrecision is the largest number of decimal places supported for quantities.
+const MaxPrecision = 9
+
+// Quantities are integers of at most 19 digits once scaled by up to 10^MaxPrecision, so longer or further shifted
+// decimals cannot be represented and are refused before they are built.
+const (
+	maxDecimalDigits   = 32                // maxDecimalDigits is the largest number of digits of a decimal.
+	maxDecimalExponent = 19 + MaxPrecision // maxDecimalExponent is the largest magnitude of a decimal's exponent.
+)
+
+// ErrInvalidQuantity is returned when a decimal quantity or its unit cannot be represented.
+var ErrInvalidQuantity = errors.New("invalid quantity")
+
+// Unit is a unit of measure for order quantities and pack sizes. The empty unit counts pieces.
+type Unit string
+
+// Supported units of measure.
+const (
+	UnitPiece      Unit = ""
+	UnitGram       Unit = "g"
+	UnitKilogram   Unit = "kg"
+	UnitMillilitre Unit = "ml"
+	UnitLitre      Unit = "l"
+)
+
+// unitScale describes a unit as a power of ten of its base unit.
+type unitScale struct {
+	base     Unit
+	exponent int
+}
+
+// unitScales holds the base unit and exponent of every supported unit.
+var unitScales = map[Unit]unitScale{
+	UnitPiece:      {base: UnitPiece, exponent: 0},
+	UnitGram:       {base: UnitGram, exponent: 0},
+	UnitKilogram:   {base: UnitGram, exponent: 3},
+	UnitMillilitre: {base: UnitMillilitre, exponent: 0},
+	UnitLitre:      {base: UnitMillilitre, exponent: 3},
+}
+
+// Convert converts a decimal value from one unit to another compatible unit.
+func (u Unit) Convert(value *big.Rat, to Unit) (*big.Rat, error) {
+	from, ok := unitScales[u]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown unit %q", ErrInvalidQuantity, u)
+	}
+	target, ok := unitScales[to]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown unit %q", ErrInvalidQuantity, to)
+	}
+	if from.base != target.base {
+		return nil, fmt.Errorf("%w: cannot convert %q to %q", ErrInvalidQuantity, u, to)
+	}
+
+	// Units of the same base differ by a power of ten.
+	converted := new(big.Rat).Set(value)
+	if shift := from.exponent - target.exponent; shift > 0 {
+		converted.Mul(converted, new(big.Rat).SetInt(pow10(shift)))
+	} else if shift < 0 {
+		converted.Quo(converted, new(big.Rat).SetInt(pow10(-shift)))
+	}
+	return converted, nil
+}
+
+// ParseDecimal parses a decimal number such as "2.5" or "1e3" exactly. The value is not echoed in the error, as
+// it may be arbitrarily long.
+func ParseDecimal(text string) (*big.Rat, error) {
+	mantissa, exponent, found := strings.Cut(strings.ToLower(text), "e")
+	digits := 0
+	for _, c := range mantissa {
+		switch {
+		case c >= '0' && c <= '9':
+			digits++
+		case c != '.' && c != '-' && c != '+':
+			return nil, fmt.Errorf("%w: not a decimal number", ErrInvalidQuantity)
+		}
+	}
+	if digits > maxDecimalDigits {
+		return nil, fmt.Errorf("%w: a decimal has more than %d digits", ErrInvalidQuantity, maxDecimalDigits)
+	}
+	if found {
+		shift, err := strconv.Atoi(exponent)
+		if err != nil || shift > maxDecimalExponent || shift < -maxDecimalExponent {
+			return nil, fmt.Errorf("%w: a decimal exponent is not between -%d and %d", ErrInvalidQuantity, maxDecimalExponent, maxDecimalExponent)
+		}
+	}
+	value, ok := new(big.Rat).SetString(text)
+	if !ok {
+		return nil, fmt.Errorf("%w: not a decimal number", ErrInvalidQuantity)
+	}
+	return value, nil
+}
+
+// DecimalPlaces returns the number of decimal places needed to represent the value exactly.
+func DecimalPlaces(value *big.Rat) (int, error) {
+	scaled := new(big.Rat).Set(value)
+	for places := 0; places <= MaxPrecision; places++ {
+		if scaled.IsInt() {
+			return places, nil
+		}
+		scaled.Mul(scaled, big.NewRat(10, 1))
+	}
+	return 0, fmt.Errorf("%w: a decimal needs more than %d decimal places", ErrInvalidQuantity, MaxPrecision)
+}
+
+// ScaleDecimal multiplies the value by 10^precision and returns it as an integer.
+func ScaleDecimal(value *big.Rat, precision int) (int, error) {
+	scaled := new(big.Rat).Mul(value, new(big.Rat).SetInt(pow10(precision)))
+	if !scaled.IsInt() {
+		return 0, fmt.Errorf("%w: a decimal has more than %d decimal places", ErrInvalidQuantity, precision)
+	}
+	if !scaled.Num().IsInt64() || int64(int(scaled.Num().Int64())) != scaled.Num().Int64() {
+		return 0, fmt.Errorf("%w: a decimal is too large", ErrInvalidQuantity)
+	}
+	return int(scaled.Num().Int64()), nil
+}
+
+// FormatDecimal formats an integer scaled by 10^precision as a decimal without trailing zeros.
+func FormatDecimal(value int, precision int) string {
+	text := new(big.Rat).SetFrac(big.NewInt(int64(value)), pow10(precision)).FloatString(precision)
+	if strings.Contains(text, ".") {
+		text = strings.TrimRight(strings.TrimRight(text, "0"), ".")
+	}
+	return text
+}
+
+// pow10 returns 10^exponent.
+func pow10(exponent int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exponent)), nil)
+}
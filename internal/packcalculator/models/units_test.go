@@ -0,0 +1,102 @@
+package models_test
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/models"
+)
+
+// TestUnit_Convert checks conversions between compatible and incompatible units.
+func TestUnit_Convert(t *testing.T) {
+	testCases := []struct {
+		name     string
+		from, to models.Unit
+		value    string
+		expected string
+		wantErr  bool
+	}{
+		{name: "Kilograms to grams", from: models.UnitKilogram, to: models.UnitGram, value: "2.5", expected: "2500"},
+		{name: "Millilitres to litres", from: models.UnitMillilitre, to: models.UnitLitre, value: "500", expected: "1/2"},
+		{name: "Same unit", from: models.UnitLitre, to: models.UnitLitre, value: "1.5", expected: "3/2"},
+		{name: "Incompatible units", from: models.UnitGram, to: models.UnitLitre, value: "1", wantErr: true},
+		{name: "Unknown unit", from: "oz", to: models.UnitGram, value: "1", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			value, err := models.ParseDecimal(tc.value)
+			assert.NoError(t, err, "Unexpected parse error")
+
+			converted, err := tc.from.Convert(value, tc.to)
+
+			if tc.wantErr {
+				assert.ErrorIs(t, err, models.ErrInvalidQuantity, "Expected conversion error")
+				return
+			}
+			assert.NoError(t, err, "Unexpected error")
+			assert.Equal(t, tc.expected, converted.RatString(), "Incorrect conversion")
+		})
+	}
+}
+
+// TestDecimalPlaces checks the number of decimal places needed for exact values.
+func TestDecimalPlaces(t *testing.T) {
+	for text, expected := range map[string]int{"3": 0, "2.5": 1, "0.125": 3, "1e3": 0, "1.50": 1} {
+		value, err := models.ParseDecimal(text)
+		assert.NoError(t, err, "Unexpected parse error")
+
+		places, err := models.DecimalPlaces(value)
+
+		assert.NoError(t, err, "Unexpected error")
+		assert.Equal(t, expected, places, "Incorrect decimal places for %s", text)
+	}
+
+	// A repeating fraction cannot be represented with any number of decimal places.
+	_, err := models.DecimalPlaces(big.NewRat(1, 3))
+	assert.ErrorIs(t, err, models.ErrInvalidQuantity, "Expected error for a repeating fraction")
+}
+
+// TestScaleDecimal checks scaling decimals to integers.
+func TestScaleDecimal(t *testing.T) {
+	value, err := models.ParseDecimal("2.5")
+	assert.NoError(t, err, "Unexpected parse error")
+
+	scaled, err := models.ScaleDecimal(value, 2)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 250, scaled, "Incorrect scaled value")
+
+	_, err = models.ScaleDecimal(value, 0)
+	assert.ErrorIs(t, err, models.ErrInvalidQuantity, "Expected error for insufficient precision")
+
+	huge, err := models.ParseDecimal("1e20")
+	assert.NoError(t, err, "Unexpected parse error")
+	_, err = models.ScaleDecimal(huge, 0)
+	assert.ErrorIs(t, err, models.ErrInvalidQuantity, "Expected error for a value out of range")
+}
+
+// TestParseDecimal_Bounds checks that decimals too long or too far shifted to be quantities are refused without
+// being built or echoed.
+func TestParseDecimal_Bounds(t *testing.T) {
+	for _, text := range []string{"1e1000000", "1e-1000000", "1e30", strings.Repeat("9", 1000000), "0x1p1000000", "1/3"} {
+		_, err := models.ParseDecimal(text)
+
+		assert.ErrorIs(t, err, models.ErrInvalidQuantity, "Expected error for %.20s", text)
+		assert.Less(t, len(err.Error()), 100, "Expected the value not to be echoed for %.20s", text)
+	}
+
+	value, err := models.ParseDecimal("1.500000000000000000000000000000")
+	assert.NoError(t, err, "Unexpected error for trailing zeros")
+	assert.Equal(t, "3/2", value.RatString(), "Incorrect value")
+}
+
+// TestFormatDecimal checks formatting scaled integers as decimals.
+func TestFormatDecimal(t *testing.T) {
+	assert.Equal(t, "2.5", models.FormatDecimal(250, 2))
+	assert.Equal(t, "3", models.FormatDecimal(3000, 3))
+	assert.Equal(t, "250", models.FormatDecimal(250, 0))
+	assert.Equal(t, "0.05", models.FormatDecimal(5, 2))
+}
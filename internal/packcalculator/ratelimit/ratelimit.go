@@ -0,0 +1,258 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"rpg/internal/packcalculator/auth"
+	"rpg/internal/packcalculator/logging"
+	"rpg/internal/packcalculator/metrics"
+)
+
+// Headers describing the budget a response drew from, following the IETF RateLimit header fields draft.
+const (
+	HeaderLimit     = "RateLimit-Limit"     // HeaderLimit is the burst of the budget.
+	HeaderRemaining = "RateLimit-Remaining" // HeaderRemaining is the number of requests left in the budget.
+	HeaderReset     = "RateLimit-Reset"     // HeaderReset is the number of seconds until the budget is full again.
+)
+
+// sweepInterval is how often clients whose budgets are full again are forgotten.
+const sweepInterval = time.Minute
+
+// Budget is a token bucket refilled at Rate tokens per second up to Burst tokens. A zero rate does not limit.
+type Budget struct {
+	Rate  float64
+	Burst int // Burst is the capacity of the bucket; zero uses the rate rounded up, at least 1.
+}
+
+// Config configures the budgets of each client.
+type Config struct {
+	Requests       Budget         // Requests is charged for every request, including cache hits.
+	Computations   Budget         // Computations is charged in addition for every fresh calculation.
+	TrustedProxies []netip.Prefix // TrustedProxies are the proxies whose X-Forwarded-For entries are believed.
+}
+
+// Limiter limits the requests and fresh calculations of each client, identified by its authenticated principal or
+// else by its IP address. It is independent of authentication and works with the API open.
+type Limiter struct {
+	Clock func() time.Time // Clock returns the current time; nil uses time.Now.
+
+	config Config
+
+	mu      sync.Mutex
+	clients map[string]*client
+	swept   time.Time
+}
+
+// client holds the buckets of a client.
+type client struct {
+	requests     *bucket
+	computations *bucket
+}
+
+// New returns a limiter with the budgets.
+func New(config Config) *Limiter {
+	return &Limiter{config: config, clients: make(map[string]*client)}
+}
+
+// Enabled reports whether any budget limits requests.
+func (l *Limiter) Enabled() bool {
+	return l.config.Requests.Rate > 0 || l.config.Computations.Rate > 0
+}
+
+// contextKey keys the values this package stores in a context.
+type contextKey int
+
+const clientKey contextKey = iota
+
+// charge is the state Compute needs to charge the client of a request.
+type charge struct {
+	limiter *Limiter
+	client  *client
+	key     string
+}
+
+// Middleware charges every request to the requests budget of its client, refusing it with 429 Too Many Requests
+// and a Retry-After header when the budget is empty, and lets the handler charge fresh calculations with Compute.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !l.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := l.clientKey(r)
+		current := l.client(key)
+		if !l.take(w, r, key, current.requests) {
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientKey, charge{limiter: l, client: current, key: key})))
+	})
+}
+
+// Compute charges a fresh calculation to the computations budget of the request's client. When the budget is empty
+// it writes the 429 Too Many Requests response and returns false. Requests outside the middleware are not charged.
+func Compute(w http.ResponseWriter, r *http.Request) bool {
+	current, ok := r.Context().Value(clientKey).(charge)
+	if !ok {
+		return true
+	}
+	return current.limiter.take(w, r, current.key, current.client.computations)
+}
+
+// take takes a token from the bucket, if it limits, and describes the bucket in the response headers.
+func (l *Limiter) take(w http.ResponseWriter, r *http.Request, key string, b *bucket) bool {
+	if b == nil {
+		return true
+	}
+	remaining, reset, retryAfter, ok := b.take(l.now())
+	w.Header().Set(HeaderLimit, strconv.Itoa(b.burst))
+	w.Header().Set(HeaderRemaining, strconv.Itoa(remaining))
+	w.Header().Set(HeaderReset, auth.RetryAfter(reset))
+	if !ok {
+		// If the budget is empty, return a Too Many Requests response saying when to retry.
+		logging.FromContext(r.Context()).Warn("Request rate limited", "client", key, "budget", b.name)
+		metrics.RateLimitedTotal.WithLabelValues(b.name).Inc()
+		w.Header().Set("Retry-After", auth.RetryAfter(retryAfter))
+		http.Error(w, "rate limit exceeded for "+b.name, http.StatusTooManyRequests)
+	}
+	return ok
+}
+
+// client returns the buckets of the key, forgetting the clients whose budgets are full again from time to time.
+func (l *Limiter) client(key string) *client {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// A full bucket is the same as a new one, so forgetting it changes nothing but the memory used.
+	now := l.now()
+	if now.Sub(l.swept) >= sweepInterval {
+		for existing, state := range l.clients {
+			if state.requests.full(now) && state.computations.full(now) {
+				delete(l.clients, existing)
+			}
+		}
+		l.swept = now
+	}
+
+	current, ok := l.clients[key]
+	if !ok {
+		current = &client{
+			requests:     newBucket("requests", l.config.Requests),
+			computations: newBucket("computations", l.config.Computations),
+		}
+		l.clients[key] = current
+	}
+	return current
+}
+
+// clientKey identifies the client of the request by its authenticated principal or else by its IP address.
+// Unverified credentials are ignored, so that a client cannot escape its budget by sending made-up keys.
+func (l *Limiter) clientKey(r *http.Request) string {
+	return ClientKey(r, l.config.TrustedProxies)
+}
+
+// ClientKey identifies the client of the request by the method and subject of its authenticated principal, so an API
+// key and a token of the same name are different clients, or else by its IP address behind the trusted proxies.
+func ClientKey(r *http.Request, trustedProxies []netip.Prefix) string {
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		return principal.Method + ":" + principal.Subject
+	}
+	return "ip:" + ClientIP(r, trustedProxies)
+}
+
+// ClientIP returns the IP address of the request's client. Requests from trusted proxies are attributed to the
+// rightmost X-Forwarded-For entry which is not a trusted proxy itself, as only the entries the proxies appended can
+// be believed; the entries further left are sent by the client.
+func ClientIP(r *http.Request, trustedProxies []netip.Prefix) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !trusted(host, trustedProxies) {
+		return host
+	}
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		address := strings.TrimSpace(forwarded[i])
+		if address == "" {
+			continue
+		}
+		if !trusted(address, trustedProxies) {
+			return address
+		}
+		host = address
+	}
+	return host
+}
+
+// trusted reports whether the address is one of the trusted proxies.
+func trusted(address string, trustedProxies []netip.Prefix) bool {
+	ip, err := netip.ParseAddr(address)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// now returns the current time of the limiter's clock.
+func (l *Limiter) now() time.Time {
+	if l.Clock == nil {
+		return time.Now()
+	}
+	return l.Clock()
+}
+
+// bucket is a token bucket of a budget.
+type bucket struct {
+	name    string
+	rate    float64
+	burst   int
+	limiter *rate.Limiter
+}
+
+// newBucket returns a full bucket of the budget, or nil when the budget does not limit.
+func newBucket(name string, budget Budget) *bucket {
+	if budget.Rate <= 0 {
+		return nil
+	}
+	burst := budget.Burst
+	if burst == 0 {
+		burst = int(math.Max(1, math.Ceil(budget.Rate)))
+	}
+	return &bucket{name: name, rate: budget.Rate, burst: burst, limiter: rate.NewLimiter(rate.Limit(budget.Rate), burst)}
+}
+
+// take takes a token at the time. It returns the tokens remaining, the time until the bucket is full and, when no
+// token is available, how long to wait for one.
+func (b *bucket) take(now time.Time) (int, time.Duration, time.Duration, bool) {
+	reservation := b.limiter.ReserveN(now, 1)
+	delay := reservation.DelayFrom(now)
+	if delay > 0 {
+		// Give the token back, as the request is refused rather than delayed.
+		reservation.CancelAt(now)
+	}
+	tokens := math.Max(0, b.limiter.TokensAt(now))
+	reset := time.Duration((float64(b.burst) - tokens) / b.rate * float64(time.Second))
+	return int(tokens), reset, delay, delay <= 0
+}
+
+// full reports whether the bucket, if it limits, holds its burst at the time.
+func (b *bucket) full(now time.Time) bool {
+	return b == nil || b.limiter.TokensAt(now) >= float64(b.burst)
+}
@@ -0,0 +1,130 @@
+package ratelimit_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/auth"
+	"rpg/internal/packcalculator/ratelimit"
+)
+
+// newLimiter returns a limiter with the budgets, driven by a clock the test advances.
+func newLimiter(config ratelimit.Config) (*ratelimit.Limiter, *time.Time) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	limiter := ratelimit.New(config)
+	limiter.Clock = func() time.Time { return now }
+	return limiter, &now
+}
+
+// serve sends a request from the address through the limiter to a handler which computes when asked to, and
+// returns the response.
+func serve(limiter *ratelimit.Limiter, remoteAddr string, compute bool, principal *auth.Principal) *httptest.ResponseRecorder {
+	handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if compute && !ratelimit.Compute(w, r) {
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+
+	req := httptest.NewRequest("POST", "/calculate", nil)
+	req.RemoteAddr = remoteAddr
+	if principal != nil {
+		req = req.WithContext(auth.WithPrincipal(req.Context(), *principal))
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+// TestMiddleware_Requests verifies that every request is charged and described in the RateLimit headers.
+func TestMiddleware_Requests(t *testing.T) {
+	limiter, now := newLimiter(ratelimit.Config{Requests: ratelimit.Budget{Rate: 1, Burst: 2}})
+
+	w := serve(limiter, "192.0.2.1:5000", false, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get(ratelimit.HeaderLimit), "Incorrect limit")
+	assert.Equal(t, "1", w.Header().Get(ratelimit.HeaderRemaining), "Incorrect remaining requests")
+	assert.Equal(t, "1", w.Header().Get(ratelimit.HeaderReset), "Incorrect reset")
+
+	assert.Equal(t, http.StatusOK, serve(limiter, "192.0.2.1:5001", false, nil).Code, "Expected the burst to allow a second request")
+	w = serve(limiter, "192.0.2.1:5002", false, nil)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code, "Expected the budget of the IP address to be empty")
+	assert.Equal(t, "0", w.Header().Get(ratelimit.HeaderRemaining), "Incorrect remaining requests")
+	assert.Equal(t, "1", w.Header().Get("Retry-After"), "Incorrect retry delay")
+
+	assert.Equal(t, http.StatusOK, serve(limiter, "192.0.2.2:5000", false, nil).Code, "Expected other clients to have their own budget")
+
+	*now = now.Add(time.Second)
+	assert.Equal(t, http.StatusOK, serve(limiter, "192.0.2.1:5003", false, nil).Code, "Expected a token after a second")
+}
+
+// TestMiddleware_Computations verifies that fresh calculations draw from their own, smaller budget while cheap
+// requests continue.
+func TestMiddleware_Computations(t *testing.T) {
+	limiter, _ := newLimiter(ratelimit.Config{Requests: ratelimit.Budget{Rate: 10, Burst: 10}, Computations: ratelimit.Budget{Rate: 0.1, Burst: 1}})
+
+	w := serve(limiter, "192.0.2.1:5000", true, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get(ratelimit.HeaderLimit), "Expected the headers of the computations budget")
+	assert.Equal(t, "0", w.Header().Get(ratelimit.HeaderRemaining), "Incorrect remaining computations")
+	assert.Equal(t, "10", w.Header().Get(ratelimit.HeaderReset), "Incorrect reset")
+
+	w = serve(limiter, "192.0.2.1:5000", true, nil)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code, "Expected the computations budget to be empty")
+	assert.Equal(t, "10", w.Header().Get("Retry-After"), "Incorrect retry delay")
+
+	w = serve(limiter, "192.0.2.1:5000", false, nil)
+	assert.Equal(t, http.StatusOK, w.Code, "Expected cheap requests to continue")
+	assert.Equal(t, "10", w.Header().Get(ratelimit.HeaderLimit), "Expected the headers of the requests budget")
+	assert.Equal(t, "7", w.Header().Get(ratelimit.HeaderRemaining), "Expected every request to be charged")
+}
+
+// TestMiddleware_Principal verifies that authenticated clients are limited by their identity, not their address.
+func TestMiddleware_Principal(t *testing.T) {
+	limiter, _ := newLimiter(ratelimit.Config{Requests: ratelimit.Budget{Rate: 1, Burst: 1}})
+	north := &auth.Principal{Subject: "north-warehouse", Method: auth.MethodAPIKey}
+
+	assert.Equal(t, http.StatusOK, serve(limiter, "192.0.2.1:5000", false, north).Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve(limiter, "192.0.2.2:5000", false, north).Code, "Expected the client's budget to follow it across addresses")
+	assert.Equal(t, http.StatusOK, serve(limiter, "192.0.2.1:5000", false, nil).Code, "Expected the address to have its own budget")
+}
+
+// TestMiddleware_Disabled verifies that budgets without a rate neither limit nor add headers.
+func TestMiddleware_Disabled(t *testing.T) {
+	limiter, _ := newLimiter(ratelimit.Config{})
+
+	for i := 0; i < 5; i++ {
+		w := serve(limiter, "192.0.2.1:5000", true, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get(ratelimit.HeaderLimit), "Expected no RateLimit headers")
+	}
+}
+
+// TestMiddleware_TrustedProxies verifies that clients behind trusted proxies are identified by the rightmost
+// X-Forwarded-For entry which is not a trusted proxy, and that others cannot choose their address.
+func TestMiddleware_TrustedProxies(t *testing.T) {
+	limiter, _ := newLimiter(ratelimit.Config{
+		Requests:       ratelimit.Budget{Rate: 1, Burst: 1},
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+	})
+	send := func(remoteAddr, forwarded string) int {
+		handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		req := httptest.NewRequest("POST", "/calculate", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", forwarded)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, send("10.0.0.1:5000", "192.0.2.1, 10.0.0.2"), "Unexpected refusal")
+	assert.Equal(t, http.StatusTooManyRequests, send("10.0.0.1:5000", "198.51.100.7, 192.0.2.1"), "Expected a spoofed leftmost entry to be ignored")
+	assert.Equal(t, http.StatusOK, send("10.0.0.1:5000", "192.0.2.1, 192.0.2.2"), "Expected the rightmost untrusted entry to be the client")
+	assert.Equal(t, http.StatusOK, send("192.0.2.3:5000", "192.0.2.1"), "Expected untrusted peers to be identified by their own address")
+	assert.Equal(t, http.StatusTooManyRequests, send("192.0.2.3:5000", "198.51.100.8"), "Expected untrusted peers not to choose their address")
+}
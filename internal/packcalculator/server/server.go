@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"rpg/internal/packcalculator/health"
+)
+
+// Config holds the address, timeouts and shutdown behaviour of the HTTP server.
+type Config struct {
+	Addr              string
+	ReadTimeout       time.Duration // ReadTimeout bounds reading a whole request, including its body.
+	ReadHeaderTimeout time.Duration // ReadHeaderTimeout bounds reading the request headers.
+	WriteTimeout      time.Duration // WriteTimeout bounds serving a request, so it must exceed the slowest calculation.
+	IdleTimeout       time.Duration // IdleTimeout bounds how long keep-alive connections stay open between requests.
+	ShutdownDelay     time.Duration // ShutdownDelay keeps accepting requests after readiness fails, until load balancers notice.
+	ShutdownGrace     time.Duration // ShutdownGrace bounds how long in-flight requests may take to finish.
+}
+
+// DefaultConfig returns the configuration used when nothing else is configured.
+func DefaultConfig() Config {
+	return Config{
+		Addr:              ":8080",
+		ReadTimeout:       10 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		ShutdownDelay:     0,
+		ShutdownGrace:     25 * time.Second,
+	}
+}
+
+// MaxBodyBytes returns a middleware rejecting request bodies larger than the limit.
+func MaxBodyBytes(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Server serves HTTP until its context is cancelled, then drains the in-flight requests.
+type Server struct {
+	Config  Config
+	Handler http.Handler
+	Checker *health.Checker // Checker is told when the server shuts down, so readiness fails.
+	Logger  *slog.Logger
+}
+
+// Run listens on the configured address and serves until the context is cancelled.
+func (s Server) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.Config.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, listener)
+}
+
+// Serve accepts connections on the listener until the context is cancelled, then stops accepting new
+// connections and waits up to the grace period for in-flight requests to finish.
+func (s Server) Serve(ctx context.Context, listener net.Listener) error {
+	server := &http.Server{
+		Handler:           s.Handler,
+		ReadTimeout:       s.Config.ReadTimeout,
+		ReadHeaderTimeout: s.Config.ReadHeaderTimeout,
+		WriteTimeout:      s.Config.WriteTimeout,
+		IdleTimeout:       s.Config.IdleTimeout,
+	}
+
+	// Serve in the background so the context can be watched.
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(listener)
+	}()
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Fail readiness first, then give load balancers time to stop sending requests.
+	s.Logger.Info("Server shutting down", "delay", s.Config.ShutdownDelay.String(), "grace", s.Config.ShutdownGrace.String())
+	if s.Checker != nil {
+		s.Checker.SetShuttingDown()
+	}
+	time.Sleep(s.Config.ShutdownDelay)
+
+	// Stop accepting connections and wait for the in-flight requests.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.Config.ShutdownGrace)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		// Cut the requests which did not finish in time.
+		server.Close()
+		return fmt.Errorf("requests still in flight after %s: %w", s.Config.ShutdownGrace, err)
+	}
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	s.Logger.Info("Server stopped")
+	return nil
+}
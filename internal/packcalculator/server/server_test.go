@@ -0,0 +1,110 @@
+package server_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/health"
+	"rpg/internal/packcalculator/server"
+)
+
+// blockingHandler signals when a request arrives and answers once released.
+func blockingHandler(started chan<- struct{}, release <-chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// startServer serves the handler on a random local port until the context is cancelled.
+func startServer(ctx context.Context, t *testing.T, config server.Config, handler http.Handler, checker *health.Checker) (string, <-chan error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err, "Unexpected error")
+
+	s := server.Server{Config: config, Handler: handler, Checker: checker, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Serve(ctx, listener)
+	}()
+	return "http://" + listener.Addr().String(), done
+}
+
+// TestServe_DrainsInFlightRequests verifies that a request in flight during shutdown still completes.
+func TestServe_DrainsInFlightRequests(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	checker := health.NewChecker()
+	ctx, cancel := context.WithCancel(context.Background())
+	url, done := startServer(ctx, t, server.DefaultConfig(), blockingHandler(started, release), checker)
+
+	// Send a request and shut down while it is in flight.
+	responses := make(chan int, 1)
+	go func() {
+		response, err := http.Get(url)
+		if err != nil {
+			responses <- 0
+			return
+		}
+		response.Body.Close()
+		responses <- response.StatusCode
+	}()
+	<-started
+	cancel()
+
+	// Readiness fails as soon as the shutdown begins.
+	assert.Eventually(t, func() bool {
+		w := httptest.NewRecorder()
+		checker.ReadinessHandler(w, httptest.NewRequest("GET", "/readyz", nil))
+		return w.Code == http.StatusServiceUnavailable
+	}, time.Second, 10*time.Millisecond, "Readiness did not fail")
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-responses, "In-flight request was cut")
+	assert.NoError(t, <-done, "Unexpected error")
+}
+
+// TestServe_GracePeriodExpires verifies that requests exceeding the grace period are cut.
+func TestServe_GracePeriodExpires(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	config := server.DefaultConfig()
+	config.ShutdownGrace = 50 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	url, done := startServer(ctx, t, config, blockingHandler(started, release), nil)
+
+	go func() {
+		if response, err := http.Get(url); err == nil {
+			response.Body.Close()
+		}
+	}()
+	<-started
+	cancel()
+
+	assert.ErrorIs(t, <-done, context.DeadlineExceeded, "Expected the grace period to expire")
+}
+
+// TestMaxBodyBytes verifies that larger request bodies cannot be read.
+func TestMaxBodyBytes(t *testing.T) {
+	handler := server.MaxBodyBytes(4)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		}
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader("12345")))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code, "Expected the body to be rejected")
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader("1234")))
+	assert.Equal(t, http.StatusOK, w.Code, "Expected the body to be accepted")
+}
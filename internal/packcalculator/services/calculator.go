@@ -1,15 +1,62 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
 	"sort"
 
 	"github.com/go-playground/validator"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gonum.org/v1/gonum/graph/path"
 
+	"rpg/internal/packcalculator/logging"
+	"rpg/internal/packcalculator/metrics"
 	"rpg/internal/packcalculator/models"
 	"rpg/utils"
 )
 
+// MaxQuantity is the largest order quantity or pack size accepted by the calculator. Quantities are ints, whose
+// width depends on the platform; the headroom keeps intermediate results from overflowing.
+const MaxQuantity int = math.MaxInt >> 2
+
+// LargeGraphNodes is the number of graph nodes above which a calculation logs a warning, as it is likely to be slow.
+const LargeGraphNodes = 100000
+
+// DefaultMaxNodes is the number of graph nodes or search states beyond which a calculation gives up, unless the
+// calculator sets another budget.
+const DefaultMaxNodes = 1000000
+
+// ErrQuantityOverflow is returned when a quantity exceeds MaxQuantity.
+var ErrQuantityOverflow = errors.New("quantity exceeds the supported maximum")
+
+// ErrSearchTooLarge is returned when a calculation needs more graph nodes or search states than its budget allows.
+var ErrSearchTooLarge = errors.New("calculation exceeds the search budget")
+
+// Solvers used by GraphPackCalculator.
+const (
+	SolverAStar   = "astar"   // SolverAStar finds the fewest packs on the pruned quantity graph.
+	SolverBounded = "bounded" // SolverBounded searches the quantity graph within the pack count limits.
+)
+
+// Solvers returns the names of the solvers the calculator chooses from.
+func Solvers() []string {
+	return []string{SolverAStar, SolverBounded}
+}
+
+// SolverFor returns the solver the calculator uses for the request, the bounded search when a pack size has a
+// maximum count.
+func SolverFor(request models.CalculateRequest) string {
+	for _, packSize := range request.PackSizes {
+		if packSize.MaxCount != nil {
+			return SolverBounded
+		}
+	}
+	return SolverAStar
+}
+
 // PackCalculator is an interface defining methods used in the code.
 type PackCalculator interface {
 	Calculate(quantity int) (models.RequiredPacks, error)
@@ -17,76 +64,229 @@ type PackCalculator interface {
 
 // GraphPackCalculator generates a graph of quantity permutations with the available pack sizes.
 type GraphPackCalculator struct {
-	PackSizes []int `validate:"required,min=1,dive,gt=0"` // PackSizes is a slice representing available pack sizes.
+	PackSizes []int                    `validate:"required,min=1,dive,gt=0"` // PackSizes is a slice representing available pack sizes.
+	Policy    models.FulfilmentPolicy  // Policy limits the shortfall and surplus of the packing.
+	Limits    map[int]models.PackLimit // Limits holds the minimum and maximum number of packs per size.
+	Clamp     ClampStrategy            // Clamp pre-fills large orders before the graph search; nil uses FixedClamp.
+	MaxNodes  int                      // MaxNodes caps the graph nodes and search states; zero uses DefaultMaxNodes.
 }
 
 // Calculate calculates the required number of packs based on the provided quantity and available pack sizes.
 func (c GraphPackCalculator) Calculate(quantity int) (models.RequiredPacks, error) {
+	return c.CalculateContext(context.Background(), quantity)
+}
+
+// CalculateContext is like Calculate, tracing the calculation and its graph phases as children of the context's span.
+func (c GraphPackCalculator) CalculateContext(ctx context.Context, quantity int) (packs models.RequiredPacks, err error) {
+	// Record the duration and concurrency of the calculation.
+	defer metrics.ObserveCalculation()()
+
+	// Trace the calculation with the size of the problem.
+	ctx, span := tracer().Start(ctx, "GraphPackCalculator.Calculate", trace.WithAttributes(
+		attribute.Int("order.quantity", quantity),
+		attribute.Int("pack_sizes.count", len(c.PackSizes)),
+	))
+	defer func() { endSpan(span, err) }()
+
 	// Validate the input using the validator package.
-	err := validator.New().Struct(c)
+	err = validator.New().Struct(c)
 	if err != nil {
 		return nil, err.(validator.ValidationErrors)
 	}
 
 	// Initialize the map to store the required packs.
-	packs := make(models.RequiredPacks)
+	packs = make(models.RequiredPacks)
 
 	// Check if the quantity is zero or negative, in which case no packs are required.
 	if quantity <= 0 {
 		return packs, nil
 	}
 
+	// Reject quantities whose intermediate results could overflow.
+	if err := c.checkBounds(quantity); err != nil {
+		return nil, err
+	}
+
+	// Determine how far the packing may deviate from the ordered quantity.
+	orderQuantity := quantity
+	shortfall, surplus, err := FulfilmentBounds(c.Policy, quantity)
+	if err != nil {
+		return nil, err
+	}
+
 	// Sort the available pack sizes in ascending order.
 	sizes := c.PackSizes
 	sort.Ints(sizes)
 
+	// Ship the mandatory packs up front and keep only the sizes which may still be added.
+	remaining, err := c.remainingLimits(sizes)
+	if err != nil {
+		return nil, err
+	}
+	var mandatoryTotal int
+	for size, limit := range c.Limits {
+		mandatory, ok := utils.CheckedMultiply(limit.Min, size)
+		if ok {
+			mandatoryTotal, ok = utils.CheckedSum([]int{mandatoryTotal, mandatory})
+		}
+		if !ok || mandatoryTotal > MaxQuantity {
+			return nil, fmt.Errorf("%w: %d mandatory packs of size %d", ErrQuantityOverflow, limit.Min, size)
+		}
+		packs[size] += limit.Min
+		quantity -= mandatory
+	}
+	sizes = availableSizes(sizes, remaining)
+
+	// Nothing more can be added once the minimums cover the order or every size reached its maximum.
+	if quantity <= 0 || len(sizes) == 0 {
+		if quantity > shortfall || -quantity > surplus {
+			return nil, fmt.Errorf("%w: order %d, pack limits allow %d, shortfall up to %d, surplus up to %d", ErrNoFeasiblePacking, orderQuantity, orderQuantity-quantity, shortfall, surplus)
+		}
+		return withoutEmpty(packs), nil
+	}
+
 	// Reduce the problem space when the quantity is far greater than the sum of available pack sizes.
-	if permutationClamp := utils.Sum(sizes) * HeadroomMultiplier; quantity > permutationClamp {
-		largestSize := sizes[len(sizes)-1]
-		// Subtract packs to bring the quantity down to the clamp.
-		packs[largestSize] = int(float64(quantity-permutationClamp) / float64(largestSize))
-		quantity -= packs[largestSize] * largestSize
+	clamp := c.clamp()
+	if largestSize, ok := largestUnlimitedSize(sizes, remaining); ok {
+		if clamped := clamp.Prefill(sizes, largestSize, remaining, quantity, shortfall); clamped > 0 {
+			// Subtract packs to bring the quantity down to the clamp.
+			packs[largestSize] += clamped
+			quantity -= clamped * largestSize
+			logging.FromContext(ctx).Debug("Clamped order quantity", "order", orderQuantity, "pack_size", largestSize, "packs", clamped, "remaining", quantity)
+		}
 	}
 
-	// Create a graph with the initial quantity as the root node.
-	qGraph := NewQuantityGraph(len(sizes))
+	// Create a graph with the initial quantity as the root node, exploring every permutation when limits apply
+	// or the clamp relies on it.
+	nodeCount := len(sizes)
+	if len(remaining) > 0 || clamp.Exhaustive() {
+		nodeCount = math.MaxInt
+	}
+	qGraph := NewQuantityGraph(nodeCount)
+	qGraph.MaxNodes = c.maxNodes()
 	rootNode := NewQuantityNode(quantity)
 	qGraph.AddNode(rootNode)
 
-	// Generate permutations using the described algorithm.
-	qGraph.GeneratePermutations(rootNode, sizes)
+	// Generate permutations using the described algorithm, giving up when the calculation is canceled, such as a
+	// canceled job, or the graph outgrows the budget.
+	_, phase := tracer().Start(ctx, "generate", trace.WithAttributes(attribute.Int("graph.quantity", quantity)))
+	err = qGraph.GeneratePermutations(ctx, rootNode, sizes)
+	nodes, edges := qGraph.Nodes().Len(), qGraph.Edges().Len()
+	phase.SetAttributes(attribute.Int("graph.nodes", nodes), attribute.Int("graph.edges", edges))
+	phase.End()
+	metrics.ObserveGraph(nodes, edges)
+	if nodes > LargeGraphNodes {
+		logging.FromContext(ctx).Warn("Large quantity graph", "order", orderQuantity, "quantity", quantity, "pack_sizes", sizes, "nodes", nodes, "edges", edges)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	// Aid traversal by removing unnecessary nodes.
-	candidateNode := qGraph.ClosestCandidate()
+	// Search the states of the limited sizes when maximums apply.
+	if len(remaining) > 0 {
+		_, phase = tracer().Start(ctx, "bounded_path")
+		usedSizes, ok, err := qGraph.BoundedPath(ctx, rootNode, remaining, shortfall, surplus)
+		phase.End()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: order %d, shortfall up to %d, surplus up to %d within pack limits", ErrNoFeasiblePacking, orderQuantity, shortfall, surplus)
+		}
+		for _, size := range usedSizes {
+			packs[size]++
+		}
+		return withoutEmpty(packs), nil
+	}
+
+	// Find the quantity closest to zero which the fulfilment policy allows.
+	candidateNode, ok := qGraph.ClosestCandidateWithin(shortfall, surplus)
+	if !ok {
+		return nil, fmt.Errorf("%w: order %d, shortfall up to %d, surplus up to %d", ErrNoFeasiblePacking, orderQuantity, shortfall, surplus)
+	}
+
+	// Aid traversal by removing unnecessary nodes, unless the calculation was canceled meanwhile.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	_, phase = tracer().Start(ctx, "prune")
 	qGraph.PruneNodes(candidateNode)
+	phase.End()
 
 	// Find the shortest path to the quantity closest to zero.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	_, phase = tracer().Start(ctx, "astar")
 	shortest, _ := path.AStar(rootNode, candidateNode, qGraph, nil)
 	shortestPath, _ := shortest.To(candidateNode.ID())
 	pathLength := len(shortestPath)
+	phase.End()
 
-	// Count each weighted line that forms the path as a used pack size.
+	// Count each line that forms the path as a pack sized by the difference between its quantities.
+	_, phase = tracer().Start(ctx, "path_to_packs", trace.WithAttributes(attribute.Int("path.length", pathLength)))
+	defer phase.End()
 	for i, currentNode := range shortestPath {
 		nextIndex := i + 1
 		if nextIndex >= pathLength {
 			break
 		}
 
-		lines := qGraph.WeightedLines(currentNode.ID(), shortestPath[nextIndex].ID())
-		lines.Next()
-		packs[int(lines.WeightedLine().Weight())]++
+		packs[int(currentNode.ID()-shortestPath[nextIndex].ID())]++
 	}
 
-	return packs, nil
+	return withoutEmpty(packs), nil
+}
+
+// checkBounds returns ErrQuantityOverflow when the quantity or a pack size exceeds MaxQuantity.
+func (c GraphPackCalculator) checkBounds(quantity int) error {
+	if quantity > MaxQuantity {
+		return fmt.Errorf("%w: order %d, maximum is %d", ErrQuantityOverflow, quantity, MaxQuantity)
+	}
+	for _, size := range c.PackSizes {
+		if size > MaxQuantity {
+			return fmt.Errorf("%w: pack size %d, maximum is %d", ErrQuantityOverflow, size, MaxQuantity)
+		}
+	}
+	return nil
+}
+
+// clamp returns the clamp strategy of the calculator, the fixed clamp with DefaultHeadroomMultiplier unless it is set.
+func (c GraphPackCalculator) clamp() ClampStrategy {
+	if c.Clamp == nil {
+		return FixedClamp{Multiplier: DefaultHeadroomMultiplier}
+	}
+	return c.Clamp
+}
+
+// maxNodes returns the node budget of the calculator.
+func (c GraphPackCalculator) maxNodes() int {
+	if c.MaxNodes <= 0 {
+		return DefaultMaxNodes
+	}
+	return c.MaxNodes
 }
 
 // CalculatePacks returns optimal pack sizes using GraphPackCalculator.
 func CalculatePacks(orderQuantity int, packSizes []int) (models.CalculateResponse, error) {
+	return CalculatePacksContext(context.Background(), orderQuantity, packSizes)
+}
+
+// CalculatePacksContext is like CalculatePacks, tracing the calculation as a child of the context's span.
+func CalculatePacksContext(ctx context.Context, orderQuantity int, packSizes []int) (response models.CalculateResponse, err error) {
+	ctx, span := tracer().Start(ctx, "CalculatePacks")
+	defer func() { endSpan(span, err) }()
+
 	// Create an instance of GraphPackCalculator.
 	calculator := GraphPackCalculator{PackSizes: packSizes}
 
-	// Call the Calculate method of GraphPackCalculator.
-	packs, err := calculator.Calculate(orderQuantity)
+	return calculateResponse(ctx, calculator, orderQuantity)
+}
+
+// calculateResponse runs the calculator and converts its result into a CalculateResponse.
+func calculateResponse(ctx context.Context, calculator GraphPackCalculator, orderQuantity int) (models.CalculateResponse, error) {
+	// Call the CalculateContext method of GraphPackCalculator.
+	packs, err := calculator.CalculateContext(ctx, orderQuantity)
 	if err != nil {
 		return models.CalculateResponse{}, err
 	}
@@ -94,10 +294,114 @@ func CalculatePacks(orderQuantity int, packSizes []int) (models.CalculateRespons
 	// Convert the result to the CalculateResponse structure from models.
 	var response models.CalculateResponse
 	for size, quantity := range packs {
-		response.Packs = append(response.Packs, struct {
-			PackSize int `json:"pack_size"`
-			Quantity int `json:"quantity"`
-		}{PackSize: size, Quantity: quantity})
+		response.Packs = append(response.Packs, models.Pack{PackSize: size, Quantity: quantity})
+	}
+
+	// Sort packs in ascending order of size so responses are deterministic.
+	sort.Slice(response.Packs, func(i, j int) bool {
+		return response.Packs[i].PackSize < response.Packs[j].PackSize
+	})
+
+	// Report how far the shipped quantity deviates from the order.
+	var shipped int
+	for _, pack := range response.Packs {
+		total, ok := utils.CheckedMultiply(pack.PackSize, pack.Quantity)
+		if ok {
+			shipped, ok = utils.CheckedSum([]int{shipped, total})
+		}
+		if !ok {
+			return models.CalculateResponse{}, fmt.Errorf("%w: shipped quantity of order %d", ErrQuantityOverflow, orderQuantity)
+		}
+	}
+	if orderQuantity > 0 && shipped < orderQuantity {
+		response.Shortfall = orderQuantity - shipped
+	} else if orderQuantity > 0 {
+		response.Surplus = shipped - orderQuantity
+	}
+
+	return response, nil
+}
+
+// Solver holds the settings of the calculations of orders, as configured for the server. The zero value uses the
+// fixed clamp with DefaultHeadroomMultiplier and DefaultMaxNodes.
+type Solver struct {
+	Clamp              string // Clamp names the clamp strategy of requests which name none; empty uses ClampFixed.
+	HeadroomMultiplier int    // HeadroomMultiplier is the multiplier of the fixed clamp; zero uses DefaultHeadroomMultiplier.
+	MaxNodes           int    // MaxNodes caps the graph nodes and search states; zero uses DefaultMaxNodes.
+	MaxParcels         int    // MaxParcels caps the parcels of an order with shipment constraints; zero uses DefaultMaxParcels.
+}
+
+// ClampStrategy returns the clamp strategy of the name, or the solver's own when the name is empty.
+func (s Solver) ClampStrategy(name string) (ClampStrategy, error) {
+	if name == "" {
+		name = s.Clamp
+	}
+	if name == "" {
+		name = ClampFixed
+	}
+	multiplier := s.HeadroomMultiplier
+	if multiplier == 0 {
+		multiplier = DefaultHeadroomMultiplier
+	}
+	return NewClampStrategy(name, multiplier)
+}
+
+// CalculateOrder returns optimal pack sizes for the request using the default Solver, grouped into parcels when
+// constraints are given.
+func CalculateOrder(request models.CalculateRequest) (models.CalculateResponse, error) {
+	return Solver{}.CalculateOrder(context.Background(), request)
+}
+
+// CalculateOrderContext is like CalculateOrder, tracing the calculation as a child of the context's span.
+func CalculateOrderContext(ctx context.Context, request models.CalculateRequest) (models.CalculateResponse, error) {
+	return Solver{}.CalculateOrder(ctx, request)
+}
+
+// CalculateOrder returns optimal pack sizes for the request with the solver's settings, grouped into parcels when
+// constraints are given, and traces the calculation as a child of the context's span.
+func (s Solver) CalculateOrder(ctx context.Context, request models.CalculateRequest) (response models.CalculateResponse, err error) {
+	ctx, span := tracer().Start(ctx, "CalculateOrder")
+	defer func() { endSpan(span, err) }()
+
+	// Validate physical attributes of the pack sizes.
+	if err := validator.New().Var(request.PackSizes, "dive"); err != nil {
+		return models.CalculateResponse{}, err.(validator.ValidationErrors)
+	}
+
+	// Create an instance of GraphPackCalculator honouring the fulfilment policy.
+	calculator := GraphPackCalculator{PackSizes: models.Sizes(request.PackSizes), Limits: models.Limits(request.PackSizes), MaxNodes: s.MaxNodes}
+	if request.Policy != nil {
+		calculator.Policy = *request.Policy
+	}
+	if calculator.Clamp, err = s.ClampStrategy(request.Clamp); err != nil {
+		return models.CalculateResponse{}, err
+	}
+
+	// Calculate the packs using the plain sizes.
+	response, err = calculateResponse(ctx, calculator, request.Order)
+	if err != nil {
+		return models.CalculateResponse{}, err
+	}
+
+	// Group the packs into parcels which respect the shipment constraints.
+	if request.Constraints != nil {
+		maxParcels := s.MaxParcels
+		if maxParcels <= 0 {
+			maxParcels = DefaultMaxParcels
+		}
+		response.Parcels, err = PackParcels(response.Packs, request.PackSizes, *request.Constraints, maxParcels)
+		if err != nil {
+			return models.CalculateResponse{}, err
+		}
+	}
+
+	// Describe the packs in the unit and precision of the order.
+	if request.Unit != models.UnitPiece || request.Precision > 0 {
+		response.Unit, response.Precision = request.Unit, request.Precision
+		models.DescribePacks(response.Packs, request.Unit, request.Precision)
+		for _, parcel := range response.Parcels {
+			models.DescribePacks(parcel.Packs, request.Unit, request.Precision)
+		}
 	}
 
 	return response, nil
@@ -1,11 +1,13 @@
 package services_test
 
 import (
+	"context"
 	"sort"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
+	"rpg/internal/packcalculator/models"
 	"rpg/internal/packcalculator/services"
 )
 
@@ -137,3 +139,60 @@ func TestCalculatePacks_NegativePackSizes(t *testing.T) {
 	assert.Error(t, err, "Expected error for negative pack sizes")
 	assert.Nil(t, result.Packs, "Packs should be nil for negative pack sizes error")
 }
+
+// TestCalculateOrder_Units verifies that packs are described in the unit and precision of the order.
+func TestCalculateOrder_Units(t *testing.T) {
+	request := models.CalculateRequest{
+		Order:     320,
+		Unit:      models.UnitLitre,
+		Precision: 2,
+		PackSizes: models.NewPackSizes([]int{50, 250}),
+	}
+
+	result, err := services.CalculateOrder(request)
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, []models.Pack{
+		{PackSize: 50, Quantity: 2, Size: "0.5", Unit: models.UnitLitre, Precision: 2},
+		{PackSize: 250, Quantity: 1, Size: "2.5", Unit: models.UnitLitre, Precision: 2},
+	}, result.Packs, "Incorrect packs")
+	assert.Equal(t, 30, result.Surplus, "Incorrect surplus")
+	assert.Equal(t, models.UnitLitre, result.Unit, "Incorrect unit")
+	assert.Equal(t, 2, result.Precision, "Incorrect precision")
+}
+
+// TestCalculateOrderContext_Canceled verifies that a canceled calculation stops before searching the graph.
+func TestCalculateOrderContext_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := services.CalculateOrderContext(ctx, models.CalculateRequest{Order: 263, PackSizes: models.NewPackSizes([]int{23, 31, 53})})
+
+	assert.ErrorIs(t, err, context.Canceled, "Expected the calculation to be canceled")
+}
+
+// TestCalculate_FewestPacks verifies that, among the packings shipping the smallest surplus, the graph search picks
+// the one with the fewest packs, which the line weights decide.
+func TestCalculate_FewestPacks(t *testing.T) {
+	testCases := []struct {
+		sizes    []int
+		quantity int
+	}{
+		{sizes: []int{1, 5}, quantity: 10},
+		{sizes: []int{5, 12, 13}, quantity: 60},
+		{sizes: []int{6, 9, 20}, quantity: 60},
+		{sizes: []int{23, 31, 53}, quantity: 1000},
+	}
+
+	for _, tc := range testCases {
+		calculator := services.GraphPackCalculator{PackSizes: append([]int(nil), tc.sizes...), Clamp: services.NoClamp{}}
+
+		required, err := calculator.Calculate(tc.quantity)
+
+		assert.NoError(t, err, "Unexpected error for order %d", tc.quantity)
+		surplus, packs := shippedPacking(tc.quantity, required)
+		optimalSurplus, optimalPacks := optimalPacking(tc.quantity, tc.sizes)
+		assert.Equal(t, optimalSurplus, surplus, "Incorrect surplus for order %d of sizes %v", tc.quantity, tc.sizes)
+		assert.Equal(t, optimalPacks, packs, "Incorrect pack count for order %d of sizes %v", tc.quantity, tc.sizes)
+	}
+}
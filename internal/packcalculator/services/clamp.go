@@ -0,0 +1,133 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"rpg/utils"
+)
+
+// Names of the clamp strategies, as used in the configuration and in requests.
+const (
+	ClampFixed = "fixed" // ClampFixed pre-fills the order above a multiple of the sum of the sizes.
+	ClampSafe  = "safe"  // ClampSafe pre-fills only what every optimal packing contains.
+	ClampOff   = "off"   // ClampOff searches the graph of the whole order.
+)
+
+// ErrUnknownClamp is returned for a clamp strategy name which does not exist.
+var ErrUnknownClamp = errors.New("unknown clamp strategy")
+
+// ClampStrategy decides how many packs of the largest unlimited size are added before the graph search,
+// which keeps the graph of large orders small.
+type ClampStrategy interface {
+	// Prefill returns the number of packs of the largest size to add for the quantity. The sizes are sorted,
+	// remaining holds how many more packs of each capped size may be used and shortfall is the allowed shortfall.
+	Prefill(sizes []int, largest int, remaining map[int]int, quantity, shortfall int) int
+	// Exhaustive reports whether the graph search explores every permutation of the rest of the order.
+	Exhaustive() bool
+}
+
+// NewClampStrategy returns the clamp strategy of the name; the multiplier applies to the fixed strategy.
+func NewClampStrategy(name string, multiplier int) (ClampStrategy, error) {
+	switch name {
+	case ClampFixed:
+		if multiplier < 1 {
+			return nil, fmt.Errorf("%w: multiplier %d of the fixed clamp must be positive", ErrUnknownClamp, multiplier)
+		}
+		return FixedClamp{Multiplier: multiplier}, nil
+	case ClampSafe:
+		return SafeClamp{}, nil
+	case ClampOff:
+		return NoClamp{}, nil
+	default:
+		return nil, fmt.Errorf("%w: %q, use %q, %q or %q", ErrUnknownClamp, name, ClampFixed, ClampSafe, ClampOff)
+	}
+}
+
+// FixedClamp pre-fills the order down to Multiplier times the sum of the sizes. It is fast and keeps the graph
+// small, but may miss a packing with fewer packs or less surplus for some sets of sizes.
+type FixedClamp struct {
+	Multiplier int
+}
+
+// Prefill returns the packs which bring the quantity down to the clamp.
+func (f FixedClamp) Prefill(sizes []int, largest int, remaining map[int]int, quantity, shortfall int) int {
+	sum, ok := utils.CheckedSum(sizes)
+	if !ok {
+		return 0
+	}
+	clamp, ok := utils.CheckedMultiply(sum, f.Multiplier)
+	if !ok || quantity <= clamp {
+		return 0
+	}
+	return (quantity - clamp) / largest
+}
+
+// Exhaustive returns false; the graph search stops early as it always has.
+func (FixedClamp) Exhaustive() bool {
+	return false
+}
+
+// SafeClamp pre-fills only packs which an optimal packing is guaranteed to contain and searches the rest of the
+// order exhaustively, so the result is optimal. A packing with the fewest packs never holds lcm(s, largest)/s packs
+// of a smaller size s, since lcm(s, largest)/largest packs of the largest size ship the same quantity in fewer packs.
+// Everything beyond those packs and the capped larger sizes is therefore made of the largest size. The rest of the
+// order grows with the least common multiples of the sizes, so large coprime sizes build large graphs.
+type SafeClamp struct{}
+
+// Prefill returns the packs of the largest size which every optimal packing of the quantity contains.
+func (SafeClamp) Prefill(sizes []int, largest int, remaining map[int]int, quantity, shortfall int) int {
+	bound, ok := otherSizesBound(sizes, largest, remaining)
+	if !ok || quantity-shortfall <= bound {
+		return 0
+	}
+	return (quantity - shortfall - bound) / largest
+}
+
+// Exhaustive returns true; an early stop could miss the optimal packing of the rest of the order.
+func (SafeClamp) Exhaustive() bool {
+	return true
+}
+
+// NoClamp never pre-fills the order, so the graph covers the whole quantity and is searched exhaustively. Large
+// orders build large graphs, bounded by the node budget of the calculator.
+type NoClamp struct{}
+
+// Prefill returns zero.
+func (NoClamp) Prefill(sizes []int, largest int, remaining map[int]int, quantity, shortfall int) int {
+	return 0
+}
+
+// Exhaustive returns true; without a clamp only the exhaustive search guarantees the packing with the fewest packs.
+func (NoClamp) Exhaustive() bool {
+	return true
+}
+
+// otherSizesBound returns the largest quantity an optimal packing ships in sizes other than the largest,
+// reporting false when it overflows.
+func otherSizesBound(sizes []int, largest int, remaining map[int]int) (int, bool) {
+	var bound int
+	for _, size := range sizes {
+		var contribution int
+		var ok bool
+		switch {
+		case size == largest:
+			continue
+		case size > largest:
+			// Larger sizes are capped, otherwise they would be the largest unlimited size.
+			contribution, ok = utils.CheckedMultiply(remaining[size], size)
+		default:
+			// Fewer than lcm/size packs of a smaller size: at most lcm - size.
+			var lcm int
+			lcm, ok = utils.CheckedMultiply(size/utils.GCD(size, largest), largest)
+			contribution = lcm - size
+		}
+		if !ok {
+			return 0, false
+		}
+		if bound, ok = utils.CheckedSum([]int{bound, contribution}); !ok {
+			return 0, false
+		}
+	}
+	return bound, true
+}
@@ -0,0 +1,163 @@
+package services_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/models"
+	"rpg/internal/packcalculator/services"
+)
+
+// optimalPacking returns the smallest surplus of the order and the fewest packs shipping it, by dynamic programming.
+func optimalPacking(quantity int, sizes []int) (surplus, packs int) {
+	largest := 0
+	for _, size := range sizes {
+		largest = max(largest, size)
+	}
+
+	// Count the fewest packs summing to every total up to one largest pack beyond the order.
+	fewest := make([]int, quantity+largest+1)
+	for total := 1; total < len(fewest); total++ {
+		fewest[total] = math.MaxInt
+		for _, size := range sizes {
+			if size <= total && fewest[total-size] != math.MaxInt {
+				fewest[total] = min(fewest[total], fewest[total-size]+1)
+			}
+		}
+	}
+	for total := quantity; ; total++ {
+		if fewest[total] != math.MaxInt {
+			return total - quantity, fewest[total]
+		}
+	}
+}
+
+// shippedPacking returns the surplus of the packs and their number.
+func shippedPacking(quantity int, required models.RequiredPacks) (surplus, packs int) {
+	for size, count := range required {
+		surplus += size * count
+		packs += count
+	}
+	return surplus - quantity, packs
+}
+
+// TestSafeClamp_Optimal verifies that the safe clamp finds the smallest surplus with the fewest packs.
+func TestSafeClamp_Optimal(t *testing.T) {
+	testCases := []struct {
+		name       string
+		sizes      []int
+		quantities []int
+	}{
+		{name: "Default sizes", sizes: []int{250, 500, 1000, 2000, 5000}, quantities: []int{1, 251, 501, 12001, 123456, 1000000}},
+		{name: "Coprime sizes", sizes: []int{23, 31, 53}, quantities: []int{1, 199, 245, 5400, 5406, 12345, 1000000}},
+		{name: "McNugget sizes", sizes: []int{6, 9, 20}, quantities: []int{1, 43, 44, 1001, 99999}},
+		{name: "Close sizes", sizes: []int{5, 12, 13}, quantities: []int{1, 26, 37, 5000, 77777}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, quantity := range tc.quantities {
+				calculator := services.GraphPackCalculator{PackSizes: append([]int(nil), tc.sizes...), Clamp: services.SafeClamp{}}
+
+				required, err := calculator.Calculate(quantity)
+
+				assert.NoError(t, err, "Unexpected error for order %d", quantity)
+				surplus, packs := shippedPacking(quantity, required)
+				optimalSurplus, optimalPacks := optimalPacking(quantity, tc.sizes)
+				assert.Equal(t, optimalSurplus, surplus, "Incorrect surplus for order %d", quantity)
+				assert.Equal(t, optimalPacks, packs, "Incorrect pack count for order %d", quantity)
+			}
+		})
+	}
+}
+
+// TestSafeClamp_Limits verifies that the safe clamp keeps the result of an unclamped search within pack limits.
+func TestSafeClamp_Limits(t *testing.T) {
+	limits := map[int]models.PackLimit{53: {Max: intPtr(10)}}
+	for _, quantity := range []int{1500, 2000, 2468} {
+		safe := services.GraphPackCalculator{PackSizes: []int{23, 31, 53}, Limits: limits, Clamp: services.SafeClamp{}}
+		off := services.GraphPackCalculator{PackSizes: []int{23, 31, 53}, Limits: limits, Clamp: services.NoClamp{}}
+
+		clamped, err := safe.Calculate(quantity)
+		assert.NoError(t, err, "Unexpected error for order %d", quantity)
+		unclamped, err := off.Calculate(quantity)
+		assert.NoError(t, err, "Unexpected error for order %d", quantity)
+
+		clampedSurplus, clampedPacks := shippedPacking(quantity, clamped)
+		unclampedSurplus, unclampedPacks := shippedPacking(quantity, unclamped)
+		assert.Equal(t, unclampedSurplus, clampedSurplus, "Incorrect surplus for order %d", quantity)
+		assert.Equal(t, unclampedPacks, clampedPacks, "Incorrect pack count for order %d", quantity)
+		assert.LessOrEqual(t, clamped[53], 10, "Limit exceeded for order %d", quantity)
+	}
+}
+
+// TestClampStrategies_Prefill checks how many packs of the largest size each strategy adds up front.
+func TestClampStrategies_Prefill(t *testing.T) {
+	sizes := []int{250, 500, 1000, 2000, 5000}
+
+	testCases := []struct {
+		name     string
+		clamp    services.ClampStrategy
+		quantity int
+		expected int
+	}{
+		{name: "Fixed below clamp", clamp: services.FixedClamp{Multiplier: 50}, quantity: 437500, expected: 0},
+		{name: "Fixed above clamp", clamp: services.FixedClamp{Multiplier: 50}, quantity: 1000000, expected: 112},
+		{name: "Safe below bound", clamp: services.SafeClamp{}, quantity: 21250, expected: 0},
+		{name: "Safe above bound", clamp: services.SafeClamp{}, quantity: 100000, expected: 15},
+		{name: "Off", clamp: services.NoClamp{}, quantity: 1000000, expected: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.clamp.Prefill(sizes, 5000, nil, tc.quantity, 0), "Incorrect prefill")
+		})
+	}
+}
+
+// TestNewClampStrategy verifies the strategies selected by name.
+func TestNewClampStrategy(t *testing.T) {
+	clamp, err := services.NewClampStrategy(services.ClampFixed, 10)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, services.FixedClamp{Multiplier: 10}, clamp, "Incorrect fixed clamp")
+
+	clamp, err = services.NewClampStrategy(services.ClampSafe, 10)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, services.SafeClamp{}, clamp, "Incorrect safe clamp")
+
+	clamp, err = services.NewClampStrategy(services.ClampOff, 10)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, services.NoClamp{}, clamp, "Incorrect clamp")
+
+	_, err = services.NewClampStrategy("fast", 10)
+	assert.ErrorIs(t, err, services.ErrUnknownClamp, "Expected an error for an unknown clamp")
+
+	_, err = services.NewClampStrategy(services.ClampFixed, 0)
+	assert.ErrorIs(t, err, services.ErrUnknownClamp, "Expected an error for a multiplier below one")
+}
+
+// TestCalculateOrder_Clamp verifies that the request selects the clamp strategy.
+func TestCalculateOrder_Clamp(t *testing.T) {
+	request := models.CalculateRequest{
+		Order:     5406,
+		PackSizes: []models.PackSize{{Size: 23}, {Size: 31}, {Size: 53}},
+		Clamp:     services.ClampSafe,
+	}
+
+	result, err := services.CalculateOrder(request)
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, []models.Pack{{PackSize: 53, Quantity: 102}}, result.Packs, "Incorrect packs")
+
+	request.Clamp = services.ClampOff
+	result, err = services.CalculateOrder(request)
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, []models.Pack{{PackSize: 53, Quantity: 102}}, result.Packs, "Incorrect packs without a clamp")
+
+	request.Clamp = "fast"
+	_, err = services.CalculateOrder(request)
+	assert.ErrorIs(t, err, services.ErrUnknownClamp, "Expected an error for an unknown clamp")
+}
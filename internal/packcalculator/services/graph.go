@@ -1,7 +1,11 @@
 package services
 
 import (
+	"context"
+	"fmt"
 	"sort"
+	"strconv"
+	"strings"
 
 	"gonum.org/v1/gonum/graph"
 	"gonum.org/v1/gonum/graph/multi"
@@ -9,19 +13,27 @@ import (
 
 // GraphQuantity is an interface defining methods used in the code.
 type GraphQuantity interface {
-	GeneratePermutations(node QuantityNode, sizes []int)
+	GeneratePermutations(ctx context.Context, node QuantityNode, sizes []int) error
 	ClosestCandidate() QuantityNode
+	ClosestCandidateWithin(shortfall, surplus int) (QuantityNode, bool)
+	BoundedPath(ctx context.Context, root QuantityNode, limits map[int]int, shortfall, surplus int) ([]int, bool, error)
 	PruneNodes(candidate graph.Node)
 	HasWeightedLine(from, to QuantityNode, weight float64) bool
 	AddWeightedLine(from, to QuantityNode, weight float64)
 }
 
-// HeadroomMultiplier is a constant multiplier used for reducing the problem space.
-const HeadroomMultiplier int = 50
+// DefaultHeadroomMultiplier is the multiplier of the fixed clamp, used for reducing the problem space, unless the
+// solver sets another.
+const DefaultHeadroomMultiplier = 50
+
+// PackWeight is the weight of every line, so the shortest path uses the fewest packs.
+// The pack size of a line is the difference between the quantities of its nodes.
+const PackWeight float64 = 1
 
 // QuantityGraph is a multi-graph of quantities, allowing for multiple weights (lines) between two nodes (edge).
 type QuantityGraph struct {
 	NodeCount  int
+	MaxNodes   int // MaxNodes caps the nodes generated and the states searched by BoundedPath; zero does not cap them.
 	Candidates map[int]QuantityNode
 	*multi.WeightedDirectedGraph
 }
@@ -50,29 +62,57 @@ func NewQuantityNode(quantity int) QuantityNode {
 	return QuantityNode{Quantity: quantity}
 }
 
-// GeneratePermutations generates permutations by recursively subtracting quantities.
-func (g *QuantityGraph) GeneratePermutations(node QuantityNode, sizes []int) {
+// permutationFrame is a node whose lines GeneratePermutations is adding, with the index of the next size to subtract.
+type permutationFrame struct {
+	node QuantityNode
+	next int
+}
+
+// GeneratePermutations generates permutations by repeatedly subtracting quantities, depth first. It keeps the nodes
+// being expanded on its own stack rather than recursing, so deep graphs cannot overflow the goroutine stack; their
+// depth is bounded by MaxNodes like their size. It returns the context's error once the context is done and
+// ErrSearchTooLarge once the graph would exceed MaxNodes.
+func (g *QuantityGraph) GeneratePermutations(ctx context.Context, node QuantityNode, sizes []int) error {
 	// Stop generating permutations if there are more paths to 0 than available quantities.
 	if nodesToZero := g.To(int64(0)); nodesToZero.Len() >= g.NodeCount {
-		return
+		return nil
 	}
 
-	for _, size := range sizes {
+	nodes := g.Nodes().Len()
+	stack := []permutationFrame{{node: node}}
+	for len(stack) > 0 {
+		// Stop when the calculation was canceled or timed out.
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Return to the previous node once every size was subtracted.
+		frame := &stack[len(stack)-1]
+		if frame.next >= len(sizes) {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		node, size := frame.node, sizes[frame.next]
+		frame.next++
+
 		// Find or create a node by the subtracted quantity.
 		nextQuantity := node.Quantity - size
 		nextNode := NewQuantityNode(nextQuantity)
 		if existingNode := g.Node(nextNode.ID()); existingNode == nil {
+			if g.MaxNodes > 0 && nodes >= g.MaxNodes {
+				return fmt.Errorf("%w: more than %d graph nodes", ErrSearchTooLarge, g.MaxNodes)
+			}
 			g.AddNode(nextNode)
+			nodes++
 		}
 
-		// Maintain unique weights for edges between two quantities to avoid unnecessary recalculations.
-		weight := float64(size)
-		if g.HasWeightedLine(node, nextNode, weight) {
+		// Maintain a single line between two quantities to avoid unnecessary recalculations.
+		if g.HasWeightedLine(node, nextNode, PackWeight) {
 			continue
 		}
 
 		// Link the nodes by quantity.
-		g.SetWeightedLine(g.NewWeightedLine(node, nextNode, weight))
+		g.SetWeightedLine(g.NewWeightedLine(node, nextNode, PackWeight))
 
 		// Track nodes that satisfy the required quantity, stopping at this depth.
 		if nextQuantity <= 0 {
@@ -80,9 +120,13 @@ func (g *QuantityGraph) GeneratePermutations(node QuantityNode, sizes []int) {
 			continue
 		}
 
-		// Subtract from the next quantity, increasing depth.
-		g.GeneratePermutations(nextNode, sizes)
+		// Subtract from the next quantity, increasing depth, unless there are enough paths to 0 already.
+		if nodesToZero := g.To(int64(0)); nodesToZero.Len() >= g.NodeCount {
+			continue
+		}
+		stack = append(stack, permutationFrame{node: nextNode})
 	}
+	return nil
 }
 
 // ClosestCandidate finds the candidate node with the quantity closest to zero.
@@ -106,6 +150,117 @@ func (g *QuantityGraph) ClosestCandidate() QuantityNode {
 	return g.Candidates[quantities[0]]
 }
 
+// ClosestCandidateWithin finds the node closest to zero whose quantity lies between -surplus and shortfall.
+func (g *QuantityGraph) ClosestCandidateWithin(shortfall, surplus int) (QuantityNode, bool) {
+	var closest QuantityNode
+	var found bool
+	it := g.Nodes()
+	for it.Next() {
+		node, ok := it.Node().(QuantityNode)
+		if !ok || node.Quantity > shortfall || -node.Quantity > surplus {
+			continue
+		}
+
+		// Prefer the smallest deviation, shipping the surplus rather than the shortfall on ties.
+		if !found || abs(node.Quantity) < abs(closest.Quantity) || (abs(node.Quantity) == abs(closest.Quantity) && node.Quantity < closest.Quantity) {
+			closest = node
+			found = true
+		}
+	}
+	return closest, found
+}
+
+// boundedState is a node reached with a specific usage of the limited pack sizes.
+type boundedState struct {
+	node   QuantityNode
+	usage  map[int]int
+	parent *boundedState
+}
+
+// key identifies the state by its quantity and the usage of each limited size.
+func (s *boundedState) key(limitedSizes []int) string {
+	var key strings.Builder
+	key.WriteString(strconv.Itoa(s.node.Quantity))
+	for _, size := range limitedSizes {
+		key.WriteByte(':')
+		key.WriteString(strconv.Itoa(s.usage[size]))
+	}
+	return key.String()
+}
+
+// BoundedPath finds the fewest pack sizes leading from the root to the quantity closest to zero
+// between -surplus and shortfall, never using a limited size more often than its limit allows. It returns the
+// context's error once the context is done and ErrSearchTooLarge once the states would exceed MaxNodes.
+func (g *QuantityGraph) BoundedPath(ctx context.Context, root QuantityNode, limits map[int]int, shortfall, surplus int) ([]int, bool, error) {
+	// Order the limited sizes so states have a stable key.
+	limitedSizes := make([]int, 0, len(limits))
+	for size := range limits {
+		limitedSizes = append(limitedSizes, size)
+	}
+	sort.Ints(limitedSizes)
+
+	// Explore states breadth-first so each state is first reached with the fewest packs.
+	start := &boundedState{node: root, usage: map[int]int{}}
+	visited := map[string]bool{start.key(limitedSizes): true}
+	queue := []*boundedState{start}
+	var best *boundedState
+	for len(queue) > 0 {
+		// Stop when the calculation was canceled or timed out.
+		if err := ctx.Err(); err != nil {
+			return nil, false, err
+		}
+		state := queue[0]
+		queue = queue[1:]
+
+		// Keep the state closest to zero, preferring a surplus over a shortfall of the same size.
+		if quantity := state.node.Quantity; quantity <= shortfall && -quantity <= surplus {
+			if best == nil || abs(quantity) < abs(best.node.Quantity) || (abs(quantity) == abs(best.node.Quantity) && quantity < best.node.Quantity) {
+				best = state
+			}
+		}
+
+		// Follow every line leaving the node while the limits allow it.
+		it := g.From(state.node.ID())
+		for it.Next() {
+			next, ok := it.Node().(QuantityNode)
+			if !ok {
+				continue
+			}
+			size := state.node.Quantity - next.Quantity
+			if limit, limited := limits[size]; limited && state.usage[size] >= limit {
+				continue
+			}
+
+			usage := make(map[int]int, len(state.usage)+1)
+			for usedSize, count := range state.usage {
+				usage[usedSize] = count
+			}
+			if _, limited := limits[size]; limited {
+				usage[size]++
+			}
+
+			nextState := &boundedState{node: next, usage: usage, parent: state}
+			if key := nextState.key(limitedSizes); !visited[key] {
+				if g.MaxNodes > 0 && len(visited) >= g.MaxNodes {
+					return nil, false, fmt.Errorf("%w: more than %d search states", ErrSearchTooLarge, g.MaxNodes)
+				}
+				visited[key] = true
+				queue = append(queue, nextState)
+			}
+		}
+	}
+	if best == nil {
+		return nil, false, nil
+	}
+
+	// Walk back from the best state to collect the sizes used.
+	var sizes []int
+	for state := best; state.parent != nil; state = state.parent {
+		sizes = append(sizes, state.parent.node.Quantity-state.node.Quantity)
+	}
+	return sizes, true, nil
+}
+
 // PruneNodes removes unnecessary nodes from the graph.
 func (g *QuantityGraph) PruneNodes(candidate graph.Node) {
 	// Remove other candidates from the graph.
@@ -146,3 +301,11 @@ func (g *QuantityGraph) HasWeightedLine(from, to QuantityNode, weight float64) b
 func (g *QuantityGraph) AddWeightedLine(from, to QuantityNode, weight float64) {
 	g.WeightedDirectedGraph.SetWeightedLine(g.NewWeightedLine(from, to, weight))
 }
+
+// abs returns the absolute value of a quantity.
+func abs(quantity int) int {
+	if quantity < 0 {
+		return -quantity
+	}
+	return quantity
+}
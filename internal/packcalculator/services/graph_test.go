@@ -1,7 +1,9 @@
 package services_test
 
 import (
+	"context"
 	"fmt"
+	"math"
 	"sort"
 	"testing"
 
@@ -38,7 +40,8 @@ func TestGeneratePermutations(t *testing.T) {
 		// Create a node and call the GeneratePermutations method.
 		node := services.NewQuantityNode(10)
 		sizes := []int{1, 2, 3}
-		graph.GeneratePermutations(node, sizes)
+		err := graph.GeneratePermutations(context.Background(), node, sizes)
+		assert.NoError(t, err)
 
 		// Get all nodes in the graph.
 		allNodes := graph.Nodes()
@@ -62,7 +65,8 @@ func TestGeneratePermutations(t *testing.T) {
 		// Create a node and call the GeneratePermutations method with empty sizes.
 		node := services.NewQuantityNode(10)
 		var sizes []int
-		graph.GeneratePermutations(node, sizes)
+		err := graph.GeneratePermutations(context.Background(), node, sizes)
+		assert.NoError(t, err)
 
 		// Expect that the slice of nodes is empty since sizes are empty.
 		assert.Empty(t, graph.Nodes(), "No nodes should be generated with empty sizes")
@@ -76,7 +80,8 @@ func TestGeneratePermutations(t *testing.T) {
 		// Create a node with negative quantity and call the GeneratePermutations method.
 		node := services.NewQuantityNode(-5)
 		sizes := []int{1, 2, 3}
-		graph.GeneratePermutations(node, sizes)
+		err := graph.GeneratePermutations(context.Background(), node, sizes)
+		assert.NoError(t, err)
 
 		// Expect that the nodes are added to Candidates.
 		assert.NotEmpty(t, graph.Candidates, "Nodes should be added to Candidates for negative quantity")
@@ -90,7 +95,8 @@ func TestGeneratePermutations(t *testing.T) {
 		// Create a node with zero quantity and call the GeneratePermutations method.
 		node := services.NewQuantityNode(0)
 		sizes := []int{1, 2, 3}
-		graph.GeneratePermutations(node, sizes)
+		err := graph.GeneratePermutations(context.Background(), node, sizes)
+		assert.NoError(t, err)
 
 		// Expect that the nodes are added to Candidates.
 		assert.NotEmpty(t, graph.Candidates, "Nodes should be added to Candidates for zero quantity")
@@ -173,6 +179,63 @@ func TestClosestCandidate(t *testing.T) {
 	})
 }
 
+// TestClosestCandidateWithin checks the behavior of the ClosestCandidateWithin function.
+func TestClosestCandidateWithin(t *testing.T) {
+	// Create a graph with quantities on both sides of zero.
+	graph := services.NewQuantityGraph(3)
+	for _, quantity := range []int{5, 2, -2, -4} {
+		graph.AddNode(services.NewQuantityNode(quantity))
+	}
+
+	// Subtest: surplus is preferred on ties.
+	t.Run("PreferSurplusOnTie", func(t *testing.T) {
+		candidate, ok := graph.ClosestCandidateWithin(2, 10)
+		assert.True(t, ok, "Expected a candidate")
+		assert.Equal(t, services.NewQuantityNode(-2), candidate, "Unexpected candidate node")
+	})
+
+	// Subtest: shortfall is used when surplus is not allowed.
+	t.Run("ShortfallOnly", func(t *testing.T) {
+		candidate, ok := graph.ClosestCandidateWithin(2, 0)
+		assert.True(t, ok, "Expected a candidate")
+		assert.Equal(t, services.NewQuantityNode(2), candidate, "Unexpected candidate node")
+	})
+
+	// Subtest: no node lies within the bounds.
+	t.Run("NoCandidate", func(t *testing.T) {
+		_, ok := graph.ClosestCandidateWithin(1, 1)
+		assert.False(t, ok, "Expected no candidate")
+	})
+}
+
+// TestBoundedPath checks the behavior of the BoundedPath function.
+func TestBoundedPath(t *testing.T) {
+	// Subtest: the limit forces smaller packs.
+	t.Run("LimitRespected", func(t *testing.T) {
+		graph := services.NewQuantityGraph(math.MaxInt)
+		root := services.NewQuantityNode(10)
+		graph.AddNode(root)
+		assert.NoError(t, graph.GeneratePermutations(context.Background(), root, []int{2, 5}))
+
+		sizes, ok, err := graph.BoundedPath(context.Background(), root, map[int]int{5: 1}, 0, 0)
+		assert.NoError(t, err)
+		assert.True(t, ok, "Expected a path")
+		assert.ElementsMatch(t, []int{2, 2, 2, 2, 2}, sizes, "Unexpected pack sizes")
+	})
+
+	// Subtest: no path satisfies the limit.
+	t.Run("NoPath", func(t *testing.T) {
+		graph := services.NewQuantityGraph(math.MaxInt)
+		root := services.NewQuantityNode(10)
+		graph.AddNode(root)
+		assert.NoError(t, graph.GeneratePermutations(context.Background(), root, []int{5}))
+
+		_, ok, err := graph.BoundedPath(context.Background(), root, map[int]int{5: 1}, 0, 0)
+		assert.NoError(t, err)
+		assert.False(t, ok, "Expected no path")
+	})
+}
+
 // TestPruneNodes checks the behavior of the PruneNodes function.
 func TestPruneNodes(t *testing.T) {
 	// Subtest: PruneNodes with Single Candidate.
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"rpg/internal/packcalculator/models"
+)
+
+// ErrInvalidPackLimits is returned when pack limits contradict each other or the available pack sizes.
+var ErrInvalidPackLimits = errors.New("invalid pack limits")
+
+// remainingLimits validates the limits and returns how many more packs of each capped size may be added.
+func (c GraphPackCalculator) remainingLimits(sizes []int) (map[int]int, error) {
+	remaining := make(map[int]int)
+	for size, limit := range c.Limits {
+		if !containsSize(sizes, size) {
+			return nil, fmt.Errorf("%w: pack size %d is not available", ErrInvalidPackLimits, size)
+		}
+		if limit.Min < 0 {
+			return nil, fmt.Errorf("%w: minimum count %d of pack size %d is negative", ErrInvalidPackLimits, limit.Min, size)
+		}
+		if limit.Max == nil {
+			continue
+		}
+		if *limit.Max < limit.Min {
+			return nil, fmt.Errorf("%w: maximum count %d of pack size %d is below the minimum %d", ErrInvalidPackLimits, *limit.Max, size, limit.Min)
+		}
+		remaining[size] = *limit.Max - limit.Min
+	}
+	return remaining, nil
+}
+
+// availableSizes returns the sizes which have not reached their maximum count.
+func availableSizes(sizes []int, remaining map[int]int) []int {
+	available := make([]int, 0, len(sizes))
+	for _, size := range sizes {
+		if left, limited := remaining[size]; limited && left <= 0 {
+			continue
+		}
+		available = append(available, size)
+	}
+	return available
+}
+
+// largestUnlimitedSize returns the largest of the sorted sizes which has no maximum count.
+func largestUnlimitedSize(sizes []int, remaining map[int]int) (int, bool) {
+	for i := len(sizes) - 1; i >= 0; i-- {
+		if _, limited := remaining[sizes[i]]; !limited {
+			return sizes[i], true
+		}
+	}
+	return 0, false
+}
+
+// containsSize reports whether the size is one of the sizes.
+func containsSize(sizes []int, size int) bool {
+	for _, s := range sizes {
+		if s == size {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutEmpty removes pack sizes with no packs from the result.
+func withoutEmpty(packs models.RequiredPacks) models.RequiredPacks {
+	for size, quantity := range packs {
+		if quantity == 0 {
+			delete(packs, size)
+		}
+	}
+	return packs
+}
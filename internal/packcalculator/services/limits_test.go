@@ -0,0 +1,135 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/models"
+	"rpg/internal/packcalculator/services"
+)
+
+// intPtr returns a pointer to the given integer.
+func intPtr(value int) *int {
+	return &value
+}
+
+// TestCalculateOrder_MaxCount verifies that a pack size is never used more often than its maximum.
+func TestCalculateOrder_MaxCount(t *testing.T) {
+	request := models.CalculateRequest{
+		Order: 12001,
+		PackSizes: []models.PackSize{
+			{Size: 250}, {Size: 500}, {Size: 1000}, {Size: 2000}, {Size: 5000, MaxCount: intPtr(1)},
+		},
+	}
+
+	result, err := services.CalculateOrder(request)
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, []models.Pack{
+		{PackSize: 250, Quantity: 1},
+		{PackSize: 1000, Quantity: 1},
+		{PackSize: 2000, Quantity: 3},
+		{PackSize: 5000, Quantity: 1},
+	}, result.Packs, "Incorrect packs")
+	assert.Equal(t, 249, result.Surplus, "Incorrect surplus")
+}
+
+// TestCalculateOrder_MinCount verifies that mandatory packs are always included.
+func TestCalculateOrder_MinCount(t *testing.T) {
+	// Subtest: the mandatory pack covers the whole order.
+	t.Run("Covers order", func(t *testing.T) {
+		request := models.CalculateRequest{
+			Order:     1,
+			PackSizes: []models.PackSize{{Size: 250}, {Size: 500}, {Size: 1000, MinCount: 1}},
+		}
+
+		result, err := services.CalculateOrder(request)
+
+		assert.NoError(t, err, "Unexpected error")
+		assert.Equal(t, []models.Pack{{PackSize: 1000, Quantity: 1}}, result.Packs, "Incorrect packs")
+	})
+
+	// Subtest: the remainder is packed around the mandatory pack.
+	t.Run("Remainder", func(t *testing.T) {
+		request := models.CalculateRequest{
+			Order:     300,
+			PackSizes: []models.PackSize{{Size: 100}, {Size: 250, MinCount: 1}},
+		}
+
+		result, err := services.CalculateOrder(request)
+
+		assert.NoError(t, err, "Unexpected error")
+		assert.Equal(t, []models.Pack{{PackSize: 100, Quantity: 1}, {PackSize: 250, Quantity: 1}}, result.Packs, "Incorrect packs")
+	})
+}
+
+// TestCalculateOrder_LimitsInfeasible verifies an error is returned when no packing respects the limits.
+func TestCalculateOrder_LimitsInfeasible(t *testing.T) {
+	request := models.CalculateRequest{
+		Order:     10,
+		PackSizes: []models.PackSize{{Size: 5, MaxCount: intPtr(1)}},
+	}
+
+	result, err := services.CalculateOrder(request)
+
+	assert.ErrorIs(t, err, services.ErrNoFeasiblePacking, "Expected error for unsatisfiable limits")
+	assert.Nil(t, result.Packs, "Packs should be nil for unsatisfiable limits")
+}
+
+// TestCalculate_InvalidLimits verifies an error is returned for contradicting limits.
+func TestCalculate_InvalidLimits(t *testing.T) {
+	testCases := []struct {
+		name   string
+		limits map[int]models.PackLimit
+	}{
+		{
+			name:   "Minimum above maximum",
+			limits: map[int]models.PackLimit{5: {Min: 3, Max: intPtr(1)}},
+		},
+		{
+			name:   "Unknown pack size",
+			limits: map[int]models.PackLimit{7: {Min: 1}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			calculator := services.GraphPackCalculator{PackSizes: []int{5, 10}, Limits: tc.limits}
+
+			_, err := calculator.Calculate(20)
+
+			assert.ErrorIs(t, err, services.ErrInvalidPackLimits, "Expected error for invalid limits")
+		})
+	}
+}
+
+// TestCalculate_CappedSearchBounded verifies that searching orders whose sizes are all capped stops at the budget
+// or the deadline instead of running until the search space is exhausted.
+func TestCalculate_CappedSearchBounded(t *testing.T) {
+	limits := map[int]models.PackLimit{23: {Max: intPtr(5000)}, 31: {Max: intPtr(5000)}, 53: {Max: intPtr(5000)}}
+
+	// Subtest: the search gives up once it needs more states than the budget.
+	t.Run("Budget", func(t *testing.T) {
+		calculator := services.GraphPackCalculator{PackSizes: []int{23, 31, 53}, Limits: limits, MaxNodes: 10000}
+
+		_, err := calculator.Calculate(100000)
+
+		assert.ErrorIs(t, err, services.ErrSearchTooLarge, "Expected the search budget to be exceeded")
+	})
+
+	// Subtest: the search stops when its context is done.
+	t.Run("Deadline", func(t *testing.T) {
+		calculator := services.GraphPackCalculator{PackSizes: []int{23, 31, 53}, Limits: limits}
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := calculator.CalculateContext(ctx, 100000)
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded, "Expected the deadline to stop the search")
+		assert.Less(t, time.Since(start), 5*time.Second, "The search ignored its deadline")
+	})
+}
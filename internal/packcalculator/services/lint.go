@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/go-playground/validator"
+
+	"rpg/utils"
+)
+
+// MaxLintThreshold is the largest order quantity up to which Lint checks whether each size is ever chosen.
+const MaxLintThreshold = 100000
+
+// MaxLintWork is the largest number of pack sizes times the threshold Lint checks. The check grows with the square
+// of the sizes times the threshold, so the threshold allowed shrinks as the sizes grow.
+const MaxLintWork = 1000000
+
+// ErrInvalidLintThreshold is returned when the lint threshold is negative or exceeds MaxLintThreshold or MaxLintWork.
+var ErrInvalidLintThreshold = errors.New("invalid lint threshold")
+
+// lintInput bounds the work of Lint, which grows with the square of the number of sizes times the threshold.
+type lintInput struct {
+	PackSizes []int `validate:"max=100"`
+}
+
+// DiagnosticCode identifies the kind of problem reported by Lint.
+type DiagnosticCode string
+
+// Diagnostics reported by Lint.
+const (
+	DiagnosticDuplicate     DiagnosticCode = "duplicate"      // A pack size is listed more than once.
+	DiagnosticMultiple      DiagnosticCode = "multiple"       // A pack size is an exact multiple of a smaller one.
+	DiagnosticNeverChosen   DiagnosticCode = "never_chosen"   // A pack size is never needed for orders up to the threshold.
+	DiagnosticCommonDivisor DiagnosticCode = "common_divisor" // The pack sizes share a divisor, so most orders overshoot.
+)
+
+// Diagnostic is a warning about a pack-size set.
+type Diagnostic struct {
+	Code     DiagnosticCode `json:"code"`
+	PackSize int            `json:"pack_size,omitempty"` // PackSize is the size the warning is about, if any.
+	Message  string         `json:"message"`
+}
+
+// Lint returns warnings about the pack sizes. A positive threshold also reports the sizes which are never needed
+// for orders up to that quantity, stopping when the context is done.
+func (c GraphPackCalculator) Lint(ctx context.Context, threshold int) ([]Diagnostic, error) {
+	// Validate the input using the validator package.
+	validate := validator.New()
+	if err := validate.Struct(c); err != nil {
+		return nil, err.(validator.ValidationErrors)
+	}
+	if err := validate.Struct(lintInput{PackSizes: c.PackSizes}); err != nil {
+		return nil, err.(validator.ValidationErrors)
+	}
+	if threshold < 0 || threshold > MaxLintThreshold {
+		return nil, fmt.Errorf("%w: %d is not between 0 and %d", ErrInvalidLintThreshold, threshold, MaxLintThreshold)
+	}
+	if len(c.PackSizes)*threshold > MaxLintWork {
+		return nil, fmt.Errorf("%w: %d pack sizes times threshold %d exceed %d", ErrInvalidLintThreshold, len(c.PackSizes), threshold, MaxLintWork)
+	}
+
+	// Count every size without sorting the calculator's own slice.
+	counts := make(map[int]int)
+	var sizes []int
+	for _, size := range c.PackSizes {
+		if counts[size] == 0 {
+			sizes = append(sizes, size)
+		}
+		counts[size]++
+	}
+	sort.Ints(sizes)
+
+	var diagnostics []Diagnostic
+	for _, size := range sizes {
+		if counts[size] > 1 {
+			diagnostics = append(diagnostics, Diagnostic{
+				Code:     DiagnosticDuplicate,
+				PackSize: size,
+				Message:  fmt.Sprintf("pack size %d is listed %d times", size, counts[size]),
+			})
+		}
+	}
+
+	// A multiple of a smaller size saves packs but never lets an order be fulfilled more exactly.
+	for i, size := range sizes {
+		for _, smaller := range sizes[:i] {
+			if size%smaller == 0 {
+				diagnostics = append(diagnostics, Diagnostic{
+					Code:     DiagnosticMultiple,
+					PackSize: size,
+					Message:  fmt.Sprintf("pack size %d is %d packs of size %d; it never reduces the surplus of an order", size, size/smaller, smaller),
+				})
+				break
+			}
+		}
+	}
+
+	if threshold > 0 {
+		unneeded, err := unneededSizes(ctx, sizes, threshold)
+		if err != nil {
+			return nil, err
+		}
+		for _, size := range unneeded {
+			diagnostics = append(diagnostics, Diagnostic{
+				Code:     DiagnosticNeverChosen,
+				PackSize: size,
+				Message:  fmt.Sprintf("pack size %d is never needed for orders up to %d", size, threshold),
+			})
+		}
+	}
+
+	// Only multiples of the common divisor are fulfilled exactly; every other order overshoots.
+	divisor := 0
+	for _, size := range sizes {
+		divisor = utils.GCD(divisor, size)
+	}
+	if divisor > 1 {
+		diagnostics = append(diagnostics, Diagnostic{
+			Code:    DiagnosticCommonDivisor,
+			Message: fmt.Sprintf("pack sizes share the divisor %d, so %.1f%% of order quantities overshoot", divisor, float64(divisor-1)*100/float64(divisor)),
+		})
+	}
+
+	return diagnostics, nil
+}
+
+// LintPackSizes returns warnings about the pack sizes using GraphPackCalculator.
+func LintPackSizes(ctx context.Context, packSizes []int, threshold int) ([]Diagnostic, error) {
+	return GraphPackCalculator{PackSizes: packSizes}.Lint(ctx, threshold)
+}
+
+// unneededSizes returns the sorted, distinct sizes which no order up to the threshold needs, assuming the
+// calculator minimises the surplus first and the number of packs second. It stops when the context is done.
+func unneededSizes(ctx context.Context, sizes []int, threshold int) ([]int, error) {
+	// Orders below the smallest size always ship a single pack of it.
+	if sizes[0] > threshold {
+		return sizes[1:], nil
+	}
+
+	// An order is rounded up to the next reachable total, which is never beyond the next multiple of the smallest size.
+	limit := threshold + sizes[0] - 1
+	packs, err := minimumPacks(ctx, sizes, limit, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect the totals chosen for orders up to the threshold.
+	var totals []int
+	for total := 1; total <= limit; total++ {
+		if packs[total] != math.MaxInt {
+			totals = append(totals, total)
+			if total >= threshold {
+				break
+			}
+		}
+	}
+
+	// A size is needed when some chosen total takes more packs, or becomes unreachable, without it.
+	var unneeded []int
+	for _, size := range sizes {
+		without, err := minimumPacks(ctx, sizes, limit, size)
+		if err != nil {
+			return nil, err
+		}
+		needed := false
+		for _, total := range totals {
+			if without[total] > packs[total] {
+				needed = true
+				break
+			}
+		}
+		if !needed {
+			unneeded = append(unneeded, size)
+		}
+	}
+	return unneeded, nil
+}
+
+// minimumPacks returns the fewest packs summing to every total up to the limit, or math.MaxInt when a total is
+// unreachable, ignoring the excluded size. It stops when the context is done.
+func minimumPacks(ctx context.Context, sizes []int, limit int, excluded int) ([]int, error) {
+	packs := make([]int, limit+1)
+	for total := 1; total <= limit; total++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		packs[total] = math.MaxInt
+		for _, size := range sizes {
+			if size == excluded || size > total || packs[total-size] == math.MaxInt {
+				continue
+			}
+			packs[total] = min(packs[total], packs[total-size]+1)
+		}
+	}
+	return packs, nil
+}
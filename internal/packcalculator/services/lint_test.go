@@ -0,0 +1,108 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-playground/validator"
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/services"
+)
+
+// codesFor returns the diagnostic codes reported for each pack size.
+func codesFor(diagnostics []services.Diagnostic) map[int][]services.DiagnosticCode {
+	codes := make(map[int][]services.DiagnosticCode)
+	for _, diagnostic := range diagnostics {
+		codes[diagnostic.PackSize] = append(codes[diagnostic.PackSize], diagnostic.Code)
+	}
+	return codes
+}
+
+// TestLint_DefaultSizes verifies the warnings about the default pack sizes.
+func TestLint_DefaultSizes(t *testing.T) {
+	packSizes := []int{5000, 250, 500, 1000, 2000, 500}
+	calculator := services.GraphPackCalculator{PackSizes: packSizes}
+
+	diagnostics, err := calculator.Lint(context.Background(), 1000)
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, map[int][]services.DiagnosticCode{
+		0:    {services.DiagnosticCommonDivisor},
+		500:  {services.DiagnosticDuplicate, services.DiagnosticMultiple},
+		1000: {services.DiagnosticMultiple},
+		2000: {services.DiagnosticMultiple, services.DiagnosticNeverChosen},
+		5000: {services.DiagnosticMultiple, services.DiagnosticNeverChosen},
+	}, codesFor(diagnostics), "Incorrect diagnostics")
+	assert.Contains(t, diagnostics[len(diagnostics)-1].Message, "99.6%", "Incorrect overshoot share")
+	assert.Equal(t, []int{5000, 250, 500, 1000, 2000, 500}, packSizes, "Pack sizes must not be reordered")
+}
+
+// TestLint_CustomSizes verifies that a coprime set without multiples is clean.
+func TestLint_CustomSizes(t *testing.T) {
+	diagnostics, err := services.LintPackSizes(context.Background(), []int{23, 31, 53}, 500)
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Empty(t, diagnostics, "Unexpected diagnostics")
+}
+
+// TestLint_NeverChosen verifies the sizes which are never needed below the threshold.
+func TestLint_NeverChosen(t *testing.T) {
+	// Subtest: every size above the smallest is unused when the threshold is below the smallest size.
+	t.Run("Threshold below smallest size", func(t *testing.T) {
+		diagnostics, err := services.LintPackSizes(context.Background(), []int{7, 10, 13}, 5)
+
+		assert.NoError(t, err, "Unexpected error")
+		assert.Equal(t, map[int][]services.DiagnosticCode{
+			10: {services.DiagnosticNeverChosen},
+			13: {services.DiagnosticNeverChosen},
+		}, codesFor(diagnostics), "Incorrect diagnostics")
+	})
+
+	// Subtest: a size above the threshold is still needed when it is the closest total.
+	t.Run("Size above threshold", func(t *testing.T) {
+		diagnostics, err := services.LintPackSizes(context.Background(), []int{7, 10}, 8)
+
+		assert.NoError(t, err, "Unexpected error")
+		assert.Empty(t, diagnostics, "Unexpected diagnostics")
+	})
+
+	// Subtest: without a threshold the check is skipped.
+	t.Run("No threshold", func(t *testing.T) {
+		diagnostics, err := services.LintPackSizes(context.Background(), []int{7, 10, 13}, 0)
+
+		assert.NoError(t, err, "Unexpected error")
+		assert.Empty(t, diagnostics, "Unexpected diagnostics")
+	})
+}
+
+// TestLint_InvalidInput verifies that invalid pack sizes and thresholds are rejected.
+func TestLint_InvalidInput(t *testing.T) {
+	_, err := services.LintPackSizes(context.Background(), nil, 0)
+	assert.IsType(t, validator.ValidationErrors{}, err, "Expected validation error for missing pack sizes")
+
+	_, err = services.LintPackSizes(context.Background(), []int{250}, services.MaxLintThreshold+1)
+	assert.ErrorIs(t, err, services.ErrInvalidLintThreshold, "Expected invalid threshold error")
+
+	_, err = services.LintPackSizes(context.Background(), []int{250}, -1)
+	assert.ErrorIs(t, err, services.ErrInvalidLintThreshold, "Expected invalid threshold error")
+
+	packSizes := make([]int, 20)
+	for i := range packSizes {
+		packSizes[i] = 100 + i
+	}
+	_, err = services.LintPackSizes(context.Background(), packSizes, services.MaxLintWork/20+1)
+	assert.ErrorIs(t, err, services.ErrInvalidLintThreshold, "Expected the sizes times the threshold to be refused")
+	_, err = services.LintPackSizes(context.Background(), packSizes, services.MaxLintWork/20)
+	assert.NoError(t, err, "Expected the sizes times the threshold at the limit to be checked")
+}
+
+// TestLint_Canceled verifies that Lint stops when its context is done.
+func TestLint_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := services.LintPackSizes(ctx, []int{23, 31, 53}, 1000)
+
+	assert.ErrorIs(t, err, context.Canceled, "Expected the check to stop")
+}
@@ -5,6 +5,7 @@
 package mocks
 
 import (
+	context "context"
 	reflect "reflect"
 	services "rpg/internal/packcalculator/services"
 
@@ -47,6 +48,22 @@ func (mr *MockGraphQuantityMockRecorder) AddWeightedLine(from, to, weight interf
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddWeightedLine", reflect.TypeOf((*MockGraphQuantity)(nil).AddWeightedLine), from, to, weight)
 }
 
+// BoundedPath mocks base method.
+func (m *MockGraphQuantity) BoundedPath(ctx context.Context, root services.QuantityNode, limits map[int]int, shortfall, surplus int) ([]int, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BoundedPath", ctx, root, limits, shortfall, surplus)
+	ret0, _ := ret[0].([]int)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// BoundedPath indicates an expected call of BoundedPath.
+func (mr *MockGraphQuantityMockRecorder) BoundedPath(ctx, root, limits, shortfall, surplus interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BoundedPath", reflect.TypeOf((*MockGraphQuantity)(nil).BoundedPath), ctx, root, limits, shortfall, surplus)
+}
+
 // ClosestCandidate mocks base method.
 func (m *MockGraphQuantity) ClosestCandidate() services.QuantityNode {
 	m.ctrl.T.Helper()
@@ -61,16 +78,33 @@ func (mr *MockGraphQuantityMockRecorder) ClosestCandidate() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClosestCandidate", reflect.TypeOf((*MockGraphQuantity)(nil).ClosestCandidate))
 }
 
+// ClosestCandidateWithin mocks base method.
+func (m *MockGraphQuantity) ClosestCandidateWithin(shortfall, surplus int) (services.QuantityNode, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClosestCandidateWithin", shortfall, surplus)
+	ret0, _ := ret[0].(services.QuantityNode)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// ClosestCandidateWithin indicates an expected call of ClosestCandidateWithin.
+func (mr *MockGraphQuantityMockRecorder) ClosestCandidateWithin(shortfall, surplus interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClosestCandidateWithin", reflect.TypeOf((*MockGraphQuantity)(nil).ClosestCandidateWithin), shortfall, surplus)
+}
+
 // GeneratePermutations mocks base method.
-func (m *MockGraphQuantity) GeneratePermutations(node services.QuantityNode, sizes []int) {
+func (m *MockGraphQuantity) GeneratePermutations(ctx context.Context, node services.QuantityNode, sizes []int) error {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "GeneratePermutations", node, sizes)
+	ret := m.ctrl.Call(m, "GeneratePermutations", ctx, node, sizes)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
 // GeneratePermutations indicates an expected call of GeneratePermutations.
-func (mr *MockGraphQuantityMockRecorder) GeneratePermutations(node, sizes interface{}) *gomock.Call {
+func (mr *MockGraphQuantityMockRecorder) GeneratePermutations(ctx, node, sizes interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GeneratePermutations", reflect.TypeOf((*MockGraphQuantity)(nil).GeneratePermutations), node, sizes)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GeneratePermutations", reflect.TypeOf((*MockGraphQuantity)(nil).GeneratePermutations), ctx, node, sizes)
 }
 
 // HasWeightedLine mocks base method.
@@ -0,0 +1,87 @@
+package services_test
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/models"
+	"rpg/internal/packcalculator/services"
+)
+
+// TestCalculatePacks_LargeQuantities verifies that pack sizes beyond float64 precision are packed exactly.
+func TestCalculatePacks_LargeQuantities(t *testing.T) {
+	// Both sizes exceed 2^53, so converting them to float64 would round them.
+	if strconv.IntSize < 64 {
+		t.Skip("Quantities beyond float64 precision need 64-bit integers")
+	}
+	small := 1<<(strconv.IntSize-10) + 1
+	large := 1<<(strconv.IntSize-9) + 3
+	orderQuantity := 7*small + 40*large
+
+	result, err := services.CalculatePacks(orderQuantity, []int{small, large})
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, []models.Pack{{PackSize: small, Quantity: 7}, {PackSize: large, Quantity: 40}}, result.Packs, "Incorrect packs")
+	assert.Zero(t, result.Surplus, "Incorrect surplus")
+}
+
+// TestCalculatePacks_Overflow verifies an error is returned for quantities above MaxQuantity.
+func TestCalculatePacks_Overflow(t *testing.T) {
+	testCases := []struct {
+		name          string
+		orderQuantity int
+		packSizes     []int
+	}{
+		{
+			name:          "Order above maximum",
+			orderQuantity: services.MaxQuantity + 1,
+			packSizes:     []int{250, 500},
+		},
+		{
+			name:          "Pack size above maximum",
+			orderQuantity: 1000,
+			packSizes:     []int{250, math.MaxInt},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := services.CalculatePacks(tc.orderQuantity, tc.packSizes)
+
+			assert.ErrorIs(t, err, services.ErrQuantityOverflow, "Expected overflow error")
+			assert.Nil(t, result.Packs, "Packs should be nil for overflow error")
+		})
+	}
+}
+
+// TestCalculatePacks_ClampOverflow verifies that huge pack sizes do not overflow the permutation clamp.
+func TestCalculatePacks_ClampOverflow(t *testing.T) {
+	result, err := services.CalculatePacks(services.MaxQuantity, []int{services.MaxQuantity, services.MaxQuantity - 1})
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, []models.Pack{{PackSize: services.MaxQuantity, Quantity: 1}}, result.Packs, "Incorrect packs")
+}
+
+// TestCalculate_MandatoryOverflow verifies an error is returned when the mandatory packs together exceed MaxQuantity.
+func TestCalculate_MandatoryOverflow(t *testing.T) {
+	size := services.MaxQuantity / 2
+	calculator := services.GraphPackCalculator{
+		PackSizes: []int{size, size - 1, size - 2},
+		Limits:    map[int]models.PackLimit{size: {Min: 1}, size - 1: {Min: 1}, size - 2: {Min: 1}},
+	}
+
+	_, err := calculator.Calculate(1)
+
+	assert.ErrorIs(t, err, services.ErrQuantityOverflow, "Expected overflow error")
+}
+
+// TestGeneratePermutations_Deep verifies that deep graphs are generated without exhausting the goroutine stack.
+func TestGeneratePermutations_Deep(t *testing.T) {
+	result, err := services.GraphPackCalculator{PackSizes: []int{1}, Clamp: services.NoClamp{}}.Calculate(50000)
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, models.RequiredPacks{1: 50000}, result, "Incorrect packs")
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/go-playground/validator"
+
+	"rpg/internal/packcalculator/models"
+)
+
+// ErrPackExceedsParcel is returned when a single pack cannot fit into any parcel.
+var ErrPackExceedsParcel = errors.New("pack exceeds parcel constraints")
+
+// ErrTooManyParcels is returned when the packs need more parcels than allowed.
+var ErrTooManyParcels = errors.New("too many parcels")
+
+// DefaultMaxParcels is the number of parcels beyond which grouping gives up, unless the Solver sets another.
+const DefaultMaxParcels = 10000
+
+// parcelEpsilon absorbs floating point error when checking parcel capacity.
+const parcelEpsilon = 1e-9
+
+// PackParcels groups packs into parcels that respect the shipment constraints, giving up with ErrTooManyParcels
+// when they need more than maxParcels parcels. Zero or less maxParcels does not limit them.
+func PackParcels(packs []models.Pack, packSizes []models.PackSize, constraints models.ShipmentConstraints, maxParcels int) ([]models.Parcel, error) {
+	// Validate the constraints using the validator package.
+	if err := validator.New().Struct(constraints); err != nil {
+		return nil, err.(validator.ValidationErrors)
+	}
+
+	// Index physical attributes by size, keeping the first occurrence of duplicates.
+	attributes := make(map[int]models.PackSize, len(packSizes))
+	for _, packSize := range packSizes {
+		if _, ok := attributes[packSize.Size]; !ok {
+			attributes[packSize.Size] = packSize
+		}
+	}
+
+	// Reject packs which would not fit even into an empty parcel.
+	remaining := make(map[int]int, len(packs))
+	order := make([]models.PackSize, 0, len(packs))
+	for _, pack := range packs {
+		if pack.Quantity <= 0 {
+			continue
+		}
+		packSize, ok := attributes[pack.PackSize]
+		if !ok {
+			packSize = models.PackSize{Size: pack.PackSize}
+		}
+		if constraints.MaxParcelWeight > 0 && packSize.Weight > constraints.MaxParcelWeight {
+			return nil, fmt.Errorf("%w: pack size %d weighs %g, maximum is %g", ErrPackExceedsParcel, pack.PackSize, packSize.Weight, constraints.MaxParcelWeight)
+		}
+		if constraints.MaxParcelVolume > 0 && packSize.Volume() > constraints.MaxParcelVolume {
+			return nil, fmt.Errorf("%w: pack size %d has volume %g, maximum is %g", ErrPackExceedsParcel, pack.PackSize, packSize.Volume(), constraints.MaxParcelVolume)
+		}
+		if _, ok := remaining[pack.PackSize]; !ok {
+			order = append(order, packSize)
+		}
+		remaining[pack.PackSize] += pack.Quantity
+	}
+
+	// Place the heaviest and bulkiest packs first so lighter ones fill the gaps.
+	sort.SliceStable(order, func(i, j int) bool {
+		if order[i].Weight != order[j].Weight {
+			return order[i].Weight > order[j].Weight
+		}
+		if order[i].Volume() != order[j].Volume() {
+			return order[i].Volume() > order[j].Volume()
+		}
+		return order[i].Size > order[j].Size
+	})
+
+	// Fill parcels one at a time until every pack has been placed.
+	var parcels []models.Parcel
+	for left := totalPacks(remaining); left > 0; {
+		if maxParcels > 0 && len(parcels) == maxParcels {
+			return nil, fmt.Errorf("%w: %d packs left after %d parcels", ErrTooManyParcels, left, maxParcels)
+		}
+		var parcel models.Parcel
+		var packCount int
+		for _, packSize := range order {
+			fit := parcelCapacity(parcel, packCount, packSize, constraints)
+			if fit > remaining[packSize.Size] {
+				fit = remaining[packSize.Size]
+			}
+			if fit <= 0 {
+				continue
+			}
+
+			parcel.Packs = append(parcel.Packs, models.Pack{PackSize: packSize.Size, Quantity: fit})
+			parcel.Weight += float64(fit) * packSize.Weight
+			parcel.Volume += float64(fit) * packSize.Volume()
+			remaining[packSize.Size] -= fit
+			packCount += fit
+			left -= fit
+		}
+
+		// Keep packs within a parcel in ascending order of size.
+		sort.Slice(parcel.Packs, func(i, j int) bool {
+			return parcel.Packs[i].PackSize < parcel.Packs[j].PackSize
+		})
+		parcels = append(parcels, parcel)
+	}
+
+	return parcels, nil
+}
+
+// parcelCapacity returns how many more packs of the given size fit into the parcel.
+func parcelCapacity(parcel models.Parcel, packCount int, packSize models.PackSize, constraints models.ShipmentConstraints) int {
+	capacity := math.MaxInt
+	if constraints.MaxPacksPerParcel > 0 {
+		capacity = constraints.MaxPacksPerParcel - packCount
+	}
+	if constraints.MaxParcelWeight > 0 && packSize.Weight > 0 {
+		capacity = min(capacity, int(math.Floor((constraints.MaxParcelWeight-parcel.Weight)/packSize.Weight+parcelEpsilon)))
+	}
+	if volume := packSize.Volume(); constraints.MaxParcelVolume > 0 && volume > 0 {
+		capacity = min(capacity, int(math.Floor((constraints.MaxParcelVolume-parcel.Volume)/volume+parcelEpsilon)))
+	}
+	return capacity
+}
+
+// totalPacks returns the total number of packs across all sizes.
+func totalPacks(packs map[int]int) int {
+	var total int
+	for _, quantity := range packs {
+		total += quantity
+	}
+	return total
+}
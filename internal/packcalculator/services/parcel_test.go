@@ -0,0 +1,149 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/models"
+	"rpg/internal/packcalculator/services"
+)
+
+// TestPackParcels_MaxWeight verifies that parcels never exceed the maximum weight.
+func TestPackParcels_MaxWeight(t *testing.T) {
+	packs := []models.Pack{{PackSize: 250, Quantity: 3}, {PackSize: 1000, Quantity: 2}}
+	packSizes := []models.PackSize{{Size: 250, Weight: 2.5}, {Size: 1000, Weight: 10}}
+	constraints := models.ShipmentConstraints{MaxParcelWeight: 15}
+
+	parcels, err := services.PackParcels(packs, packSizes, constraints, 0)
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, []models.Parcel{
+		{Packs: []models.Pack{{PackSize: 250, Quantity: 2}, {PackSize: 1000, Quantity: 1}}, Weight: 15},
+		{Packs: []models.Pack{{PackSize: 250, Quantity: 1}, {PackSize: 1000, Quantity: 1}}, Weight: 12.5},
+	}, parcels, "Incorrect parcels")
+}
+
+// TestPackParcels_MaxPacksPerParcel verifies that parcels never exceed the maximum number of packs.
+func TestPackParcels_MaxPacksPerParcel(t *testing.T) {
+	packs := []models.Pack{{PackSize: 23, Quantity: 2}, {PackSize: 31, Quantity: 7}}
+	constraints := models.ShipmentConstraints{MaxPacksPerParcel: 4}
+
+	parcels, err := services.PackParcels(packs, models.NewPackSizes([]int{23, 31}), constraints, 0)
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Len(t, parcels, 3, "Incorrect number of parcels")
+
+	// Check that every pack was placed and no parcel is overfilled.
+	placed := make(map[int]int)
+	for _, parcel := range parcels {
+		var count int
+		for _, pack := range parcel.Packs {
+			placed[pack.PackSize] += pack.Quantity
+			count += pack.Quantity
+		}
+		assert.LessOrEqual(t, count, 4, "Parcel exceeds the maximum number of packs")
+	}
+	assert.Equal(t, map[int]int{23: 2, 31: 7}, placed, "Not every pack was placed")
+}
+
+// TestPackParcels_MaxVolume verifies that parcels never exceed the maximum volume.
+func TestPackParcels_MaxVolume(t *testing.T) {
+	packs := []models.Pack{{PackSize: 500, Quantity: 3}}
+	packSizes := []models.PackSize{{Size: 500, Length: 2, Width: 2, Height: 2}}
+	constraints := models.ShipmentConstraints{MaxParcelVolume: 20}
+
+	parcels, err := services.PackParcels(packs, packSizes, constraints, 0)
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, []models.Parcel{
+		{Packs: []models.Pack{{PackSize: 500, Quantity: 2}}, Volume: 16},
+		{Packs: []models.Pack{{PackSize: 500, Quantity: 1}}, Volume: 8},
+	}, parcels, "Incorrect parcels")
+}
+
+// TestPackParcels_PackTooHeavy verifies an error is returned when a single pack exceeds the maximum weight.
+func TestPackParcels_PackTooHeavy(t *testing.T) {
+	packs := []models.Pack{{PackSize: 5000, Quantity: 1}}
+	packSizes := []models.PackSize{{Size: 5000, Weight: 40}}
+	constraints := models.ShipmentConstraints{MaxParcelWeight: 30}
+
+	parcels, err := services.PackParcels(packs, packSizes, constraints, 0)
+
+	assert.ErrorIs(t, err, services.ErrPackExceedsParcel, "Expected error for an overweight pack")
+	assert.Nil(t, parcels, "Parcels should be nil for an overweight pack")
+}
+
+// TestPackParcels_NegativeConstraints verifies an error is returned when a constraint is negative.
+func TestPackParcels_NegativeConstraints(t *testing.T) {
+	packs := []models.Pack{{PackSize: 250, Quantity: 1}}
+	constraints := models.ShipmentConstraints{MaxPacksPerParcel: -1}
+
+	_, err := services.PackParcels(packs, models.NewPackSizes([]int{250}), constraints, 0)
+
+	assert.Error(t, err, "Expected error for negative constraints")
+}
+
+// TestPackParcels_MaxParcels verifies that grouping gives up once the packs need more parcels than allowed, and
+// succeeds when they need exactly as many.
+func TestPackParcels_MaxParcels(t *testing.T) {
+	packs := []models.Pack{{PackSize: 1, Quantity: 5}}
+	constraints := models.ShipmentConstraints{MaxPacksPerParcel: 1}
+
+	parcels, err := services.PackParcels(packs, models.NewPackSizes([]int{1}), constraints, 5)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Len(t, parcels, 5, "Incorrect number of parcels")
+
+	parcels, err = services.PackParcels(packs, models.NewPackSizes([]int{1}), constraints, 4)
+	assert.ErrorIs(t, err, services.ErrTooManyParcels, "Expected too many parcels")
+	assert.Nil(t, parcels, "Parcels should be nil when there are too many")
+}
+
+// TestCalculateOrder_MaxParcels verifies that the solver bounds the parcels of an order by its own limit, and by
+// DefaultMaxParcels without one.
+func TestCalculateOrder_MaxParcels(t *testing.T) {
+	request := models.CalculateRequest{Order: 3, PackSizes: models.NewPackSizes([]int{1}), Constraints: &models.ShipmentConstraints{MaxPacksPerParcel: 1}}
+
+	result, err := services.Solver{MaxParcels: 3}.CalculateOrder(context.Background(), request)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Len(t, result.Parcels, 3, "Incorrect number of parcels")
+	_, err = services.Solver{MaxParcels: 2}.CalculateOrder(context.Background(), request)
+	assert.ErrorIs(t, err, services.ErrTooManyParcels, "Expected the solver's limit to apply")
+
+	request.Order = services.DefaultMaxParcels + 1
+	_, err = services.CalculateOrder(request)
+	assert.ErrorIs(t, err, services.ErrTooManyParcels, "Expected the default limit to apply")
+}
+
+// TestCalculateOrder_Constraints verifies that the calculated packs are grouped into parcels.
+func TestCalculateOrder_Constraints(t *testing.T) {
+	request := models.CalculateRequest{
+		Order:       12001,
+		PackSizes:   []models.PackSize{{Size: 250, Weight: 1}, {Size: 500, Weight: 2}, {Size: 1000, Weight: 4}, {Size: 2000, Weight: 8}, {Size: 5000, Weight: 20}},
+		Constraints: &models.ShipmentConstraints{MaxParcelWeight: 25},
+	}
+
+	result, err := services.CalculateOrder(request)
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, []models.Pack{{PackSize: 250, Quantity: 1}, {PackSize: 2000, Quantity: 1}, {PackSize: 5000, Quantity: 2}}, result.Packs, "Incorrect packs")
+	assert.Equal(t, []models.Parcel{
+		{Packs: []models.Pack{{PackSize: 250, Quantity: 1}, {PackSize: 5000, Quantity: 1}}, Weight: 21},
+		{Packs: []models.Pack{{PackSize: 5000, Quantity: 1}}, Weight: 20},
+		{Packs: []models.Pack{{PackSize: 2000, Quantity: 1}}, Weight: 8},
+	}, result.Parcels, "Incorrect parcels")
+}
+
+// TestCalculateOrder_NegativeWeight verifies an error is returned when a pack weight is negative.
+func TestCalculateOrder_NegativeWeight(t *testing.T) {
+	request := models.CalculateRequest{
+		Order:     10,
+		PackSizes: []models.PackSize{{Size: 5, Weight: -1}},
+	}
+
+	result, err := services.CalculateOrder(request)
+
+	assert.Error(t, err, "Expected error for negative weight")
+	assert.Nil(t, result.Packs, "Packs should be nil for negative weight error")
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"math/big"
+
+	"github.com/go-playground/validator"
+
+	"rpg/internal/packcalculator/models"
+)
+
+// ErrNoFeasiblePacking is returned when no combination of packs satisfies the fulfilment policy.
+var ErrNoFeasiblePacking = errors.New("no packing satisfies the fulfilment policy")
+
+// FulfilmentBounds returns the largest shortfall and surplus the policy allows for the quantity.
+func FulfilmentBounds(policy models.FulfilmentPolicy, quantity int) (shortfall, surplus int, err error) {
+	// Validate the policy using the validator package.
+	if err := validator.New().Struct(policy); err != nil {
+		return 0, 0, err.(validator.ValidationErrors)
+	}
+
+	// Exact fulfilment allows neither a shortfall nor a surplus.
+	if policy.ExactOnly {
+		return 0, 0, nil
+	}
+
+	// Never allow shipping less than nothing.
+	shortfall = min(policy.AllowedShortfall, quantity)
+
+	// Apply the tighter of the absolute and relative surplus caps.
+	surplus = math.MaxInt
+	if policy.MaxSurplus != nil {
+		surplus = *policy.MaxSurplus
+	}
+	if policy.MaxSurplusPercent != nil {
+		// Use exact rational arithmetic so large quantities keep their precision.
+		if limit := new(big.Rat).SetFloat64(*policy.MaxSurplusPercent); limit != nil {
+			limit.Mul(limit, new(big.Rat).SetInt64(int64(quantity)))
+			limit.Quo(limit, big.NewRat(100, 1))
+			if capped := new(big.Int).Quo(limit.Num(), limit.Denom()); capped.IsInt64() && capped.Int64() < int64(surplus) {
+				surplus = int(capped.Int64())
+			}
+		}
+	}
+
+	return shortfall, surplus, nil
+}
@@ -0,0 +1,156 @@
+package services_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/models"
+	"rpg/internal/packcalculator/services"
+)
+
+// TestFulfilmentBounds checks the shortfall and surplus allowed by different policies.
+func TestFulfilmentBounds(t *testing.T) {
+	maxSurplus := 100
+	maxSurplusPercent := 10.0
+
+	testCases := []struct {
+		name      string
+		policy    models.FulfilmentPolicy
+		quantity  int
+		shortfall int
+		surplus   int
+	}{
+		{
+			name:      "Default policy",
+			policy:    models.FulfilmentPolicy{},
+			quantity:  500,
+			shortfall: 0,
+			surplus:   math.MaxInt,
+		},
+		{
+			name:      "Allowed shortfall",
+			policy:    models.FulfilmentPolicy{AllowedShortfall: 5},
+			quantity:  500,
+			shortfall: 5,
+			surplus:   math.MaxInt,
+		},
+		{
+			name:      "Shortfall capped by quantity",
+			policy:    models.FulfilmentPolicy{AllowedShortfall: 50},
+			quantity:  20,
+			shortfall: 20,
+			surplus:   math.MaxInt,
+		},
+		{
+			name:      "Tighter of absolute and percent surplus",
+			policy:    models.FulfilmentPolicy{MaxSurplus: &maxSurplus, MaxSurplusPercent: &maxSurplusPercent},
+			quantity:  500,
+			shortfall: 0,
+			surplus:   50,
+		},
+		{
+			name:      "Exact only",
+			policy:    models.FulfilmentPolicy{AllowedShortfall: 5, MaxSurplus: &maxSurplus, ExactOnly: true},
+			quantity:  500,
+			shortfall: 0,
+			surplus:   0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			shortfall, surplus, err := services.FulfilmentBounds(tc.policy, tc.quantity)
+
+			assert.NoError(t, err, "Unexpected error")
+			assert.Equal(t, tc.shortfall, shortfall, "Incorrect shortfall")
+			assert.Equal(t, tc.surplus, surplus, "Incorrect surplus")
+		})
+	}
+}
+
+// TestFulfilmentBounds_Negative verifies an error is returned for negative policy values.
+func TestFulfilmentBounds_Negative(t *testing.T) {
+	_, _, err := services.FulfilmentBounds(models.FulfilmentPolicy{AllowedShortfall: -1}, 10)
+
+	assert.Error(t, err, "Expected error for negative shortfall")
+}
+
+// TestCalculateOrder_AllowedShortfall verifies that a short shipment is preferred when it is closer to the order.
+func TestCalculateOrder_AllowedShortfall(t *testing.T) {
+	request := models.CalculateRequest{
+		Order:     251,
+		PackSizes: models.NewPackSizes([]int{250, 500}),
+		Policy:    &models.FulfilmentPolicy{AllowedShortfall: 1},
+	}
+
+	result, err := services.CalculateOrder(request)
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, []models.Pack{{PackSize: 250, Quantity: 1}}, result.Packs, "Incorrect packs")
+	assert.Equal(t, 1, result.Shortfall, "Incorrect shortfall")
+	assert.Zero(t, result.Surplus, "Incorrect surplus")
+}
+
+// TestCalculateOrder_MaxSurplus verifies an error is returned when every packing exceeds the maximum surplus.
+func TestCalculateOrder_MaxSurplus(t *testing.T) {
+	maxSurplus := 100
+	request := models.CalculateRequest{
+		Order:     251,
+		PackSizes: models.NewPackSizes([]int{250, 500}),
+		Policy:    &models.FulfilmentPolicy{MaxSurplus: &maxSurplus},
+	}
+
+	result, err := services.CalculateOrder(request)
+
+	assert.ErrorIs(t, err, services.ErrNoFeasiblePacking, "Expected error for an unsatisfiable surplus")
+	assert.Nil(t, result.Packs, "Packs should be nil for an unsatisfiable surplus")
+}
+
+// TestCalculateOrder_MaxSurplusPercent verifies that a surplus within the relative cap is accepted.
+func TestCalculateOrder_MaxSurplusPercent(t *testing.T) {
+	maxSurplusPercent := 100.0
+	request := models.CalculateRequest{
+		Order:     251,
+		PackSizes: models.NewPackSizes([]int{250, 500}),
+		Policy:    &models.FulfilmentPolicy{MaxSurplusPercent: &maxSurplusPercent},
+	}
+
+	result, err := services.CalculateOrder(request)
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, []models.Pack{{PackSize: 500, Quantity: 1}}, result.Packs, "Incorrect packs")
+	assert.Equal(t, 249, result.Surplus, "Incorrect surplus")
+}
+
+// TestCalculateOrder_ExactOnly verifies exact packings are found and impossible ones are reported.
+func TestCalculateOrder_ExactOnly(t *testing.T) {
+	// Subtest: an exact packing exists.
+	t.Run("Exact packing", func(t *testing.T) {
+		request := models.CalculateRequest{
+			Order:     263,
+			PackSizes: models.NewPackSizes([]int{23, 31, 53}),
+			Policy:    &models.FulfilmentPolicy{ExactOnly: true},
+		}
+
+		result, err := services.CalculateOrder(request)
+
+		assert.NoError(t, err, "Unexpected error")
+		assert.Equal(t, []models.Pack{{PackSize: 23, Quantity: 2}, {PackSize: 31, Quantity: 7}}, result.Packs, "Incorrect packs")
+		assert.Zero(t, result.Surplus, "Incorrect surplus")
+	})
+
+	// Subtest: no exact packing exists.
+	t.Run("No exact packing", func(t *testing.T) {
+		request := models.CalculateRequest{
+			Order:     10,
+			PackSizes: models.NewPackSizes([]int{3}),
+			Policy:    &models.FulfilmentPolicy{ExactOnly: true},
+		}
+
+		_, err := services.CalculateOrder(request)
+
+		assert.ErrorIs(t, err, services.ErrNoFeasiblePacking, "Expected error for an impossible exact packing")
+	})
+}
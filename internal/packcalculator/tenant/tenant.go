@@ -0,0 +1,240 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator"
+
+	"rpg/internal/packcalculator/auth"
+	"rpg/internal/packcalculator/logging"
+	"rpg/internal/packcalculator/models"
+)
+
+// Header selects the tenant of a request whose caller is not bound to one.
+const Header = "X-Tenant-ID"
+
+// DefaultID is the tenant of requests which select none. It exists even when it is not configured.
+const DefaultID = "default"
+
+// Errors returned when a tenant cannot be selected or a request exceeds its limits.
+var (
+	ErrUnknownTenant   = errors.New("unknown tenant")
+	ErrForbiddenTenant = errors.New("tenant not permitted")
+	ErrLimitExceeded   = errors.New("tenant limit exceeded")
+)
+
+// ErrInvalidTenants is returned when the tenant settings fail validation.
+var ErrInvalidTenants = errors.New("invalid tenants")
+
+// idPattern restricts tenant IDs to names which are safe in file names, metric labels and cache keys.
+var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Settings are the solver defaults, limits and quotas of a tenant. Zero limits and quotas do not limit.
+type Settings struct {
+	ID           string  `json:"id" yaml:"id" validate:"required"`
+	Clamp        string  `json:"clamp,omitempty" yaml:"clamp,omitempty" validate:"omitempty,oneof=fixed safe off"` // Clamp is the default clamp strategy; empty uses the server's.
+	MaxOrder     int     `json:"max_order,omitempty" yaml:"max_order,omitempty" validate:"gte=0"`                  // MaxOrder is the largest order quantity in its unit.
+	MaxPackSizes int     `json:"max_pack_sizes,omitempty" yaml:"max_pack_sizes,omitempty" validate:"gte=0"`        // MaxPackSizes is the largest number of pack sizes of an order.
+	MaxProducts  int     `json:"max_products,omitempty" yaml:"max_products,omitempty" validate:"gte=0"`            // MaxProducts is the largest number of products in the catalog.
+	RateLimit    float64 `json:"rate_limit,omitempty" yaml:"rate_limit,omitempty" validate:"gte=0"`                // RateLimit is the sustained requests per second of the tenant.
+	Burst        int     `json:"burst,omitempty" yaml:"burst,omitempty" validate:"gte=0"`                          // Burst is the number of requests allowed at once.
+	DailyQuota   int     `json:"daily_quota,omitempty" yaml:"daily_quota,omitempty" validate:"gte=0"`              // DailyQuota is the number of requests per UTC day.
+
+	// WebhookSecret signs the callbacks of the tenant's jobs; empty refuses callbacks.
+	WebhookSecret string `json:"webhook_secret,omitempty" yaml:"webhook_secret,omitempty"`
+}
+
+// Tenant is a configured tenant with the limiter shared by all of its callers.
+type Tenant struct {
+	Settings
+
+	limiter *auth.Limiter
+}
+
+// Registry holds the configured tenants and selects the tenant of each request.
+type Registry struct {
+	Clock func() time.Time // Clock returns the current time; nil uses time.Now.
+
+	tenants map[string]*Tenant
+}
+
+// NewRegistry validates the settings and returns a registry of the tenants, adding the default tenant without
+// limits unless it is configured.
+func NewRegistry(settings []Settings) (*Registry, error) {
+	// Validate the input using the validator package.
+	validate := validator.New()
+	registry := &Registry{tenants: make(map[string]*Tenant, len(settings)+1)}
+	for i, tenant := range settings {
+		if err := validate.Struct(tenant); err != nil {
+			return nil, fmt.Errorf("%w: tenant %d: %v", ErrInvalidTenants, i+1, err)
+		}
+		if !idPattern.MatchString(tenant.ID) {
+			return nil, fmt.Errorf("%w: tenant ID %q must be lower case letters, digits, '-' and '_'", ErrInvalidTenants, tenant.ID)
+		}
+		if registry.tenants[tenant.ID] != nil {
+			return nil, fmt.Errorf("%w: tenant %q is listed twice", ErrInvalidTenants, tenant.ID)
+		}
+		registry.tenants[tenant.ID] = newTenant(tenant)
+	}
+	if registry.tenants[DefaultID] == nil {
+		registry.tenants[DefaultID] = newTenant(Settings{ID: DefaultID})
+	}
+	return registry, nil
+}
+
+// newTenant returns the tenant with a limiter for its quotas.
+func newTenant(settings Settings) *Tenant {
+	return &Tenant{Settings: settings, limiter: auth.NewLimiter(settings.RateLimit, settings.Burst, settings.DailyQuota)}
+}
+
+// Get returns the tenant of the ID.
+func (r *Registry) Get(id string) (*Tenant, bool) {
+	tenant, ok := r.tenants[id]
+	return tenant, ok
+}
+
+// IDs returns the IDs of the tenants in order.
+func (r *Registry) IDs() []string {
+	ids := make([]string, 0, len(r.tenants))
+	for id := range r.tenants {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Middleware selects the tenant of each request and refuses requests beyond its quotas with 429 Too Many Requests
+// and a Retry-After header. It runs after authentication, so the tenant of the caller takes precedence.
+func (r *Registry) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		tenant, err := r.resolve(req)
+		switch {
+		case errors.Is(err, ErrUnknownTenant) && req.Header.Get(Header) != "":
+			// If the header names an unknown tenant, return a Bad Request response.
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			// If the caller may not act for the tenant, return a Forbidden response.
+			logging.FromContext(req.Context()).Warn("Tenant refused", "error", err)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		// Count the request against the quotas of the tenant.
+		if retryAfter, err := tenant.limiter.Allow(r.now()); err != nil {
+			logging.FromContext(req.Context()).Warn("Request refused", "tenant", tenant.ID, "error", err)
+			w.Header().Set("Retry-After", auth.RetryAfter(retryAfter))
+			http.Error(w, fmt.Sprintf("tenant %s: %v", tenant.ID, err), http.StatusTooManyRequests)
+			return
+		}
+
+		// Tag the request and its log lines with the tenant.
+		ctx := WithTenant(req.Context(), tenant)
+		logger := logging.FromContext(ctx).With("tenant", tenant.ID)
+		next.ServeHTTP(w, req.WithContext(logging.WithLogger(ctx, logger)))
+	})
+}
+
+// resolve returns the tenant of the request. A caller bound to a tenant acts for it alone; other callers act for
+// the default tenant, or for the tenant of the header when they have the admin scope. Unauthenticated callers
+// cannot select a tenant.
+func (r *Registry) resolve(req *http.Request) (*Tenant, error) {
+	requested := strings.TrimSpace(req.Header.Get(Header))
+	principal, authenticated := auth.FromContext(req.Context())
+
+	id := DefaultID
+	switch {
+	case principal.Tenant != "":
+		if requested != "" && requested != principal.Tenant {
+			return nil, fmt.Errorf("%w: %s is bound to tenant %q", ErrForbiddenTenant, principal.Subject, principal.Tenant)
+		}
+		id = principal.Tenant
+	case requested != "":
+		if !authenticated && requested != DefaultID {
+			return nil, fmt.Errorf("%w: unauthenticated requests may not select tenant %q", ErrForbiddenTenant, requested)
+		}
+		if !principal.HasScope(auth.ScopeAdmin) && requested != DefaultID {
+			return nil, fmt.Errorf("%w: %s may not select tenant %q", ErrForbiddenTenant, principal.Subject, requested)
+		}
+		id = requested
+	}
+
+	tenant, ok := r.tenants[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownTenant, id)
+	}
+	return tenant, nil
+}
+
+// now returns the current time of the registry's clock.
+func (r *Registry) now() time.Time {
+	if r.Clock == nil {
+		return time.Now()
+	}
+	return r.Clock()
+}
+
+// Apply fills the tenant's solver settings into the request, overriding those of the request, and checks it
+// against the tenant's limits.
+func (t *Tenant) Apply(request *models.CalculateRequest) error {
+	if t.Clamp != "" {
+		request.Clamp = t.Clamp
+	}
+	if err := t.CheckPackSizes(len(request.PackSizes)); err != nil {
+		return err
+	}
+	return t.CheckOrder(request.Order, request.Precision)
+}
+
+// CheckPackSizes checks the number of pack sizes against the tenant's limit.
+func (t *Tenant) CheckPackSizes(count int) error {
+	if t.MaxPackSizes > 0 && count > t.MaxPackSizes {
+		return fmt.Errorf("%w: %d pack sizes, at most %d", ErrLimitExceeded, count, t.MaxPackSizes)
+	}
+	return nil
+}
+
+// CheckOrder checks the order quantity, scaled by 10^precision, against the tenant's limit in the order's unit.
+func (t *Tenant) CheckOrder(order, precision int) error {
+	if t.MaxOrder <= 0 {
+		return nil
+	}
+
+	// Compare the order in its unit, undoing the scaling by the precision.
+	limit := new(big.Int).Mul(big.NewInt(int64(t.MaxOrder)), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(precision)), nil))
+	if big.NewInt(int64(order)).Cmp(limit) > 0 {
+		return fmt.Errorf("%w: order %s, at most %d", ErrLimitExceeded, models.FormatDecimal(order, precision), t.MaxOrder)
+	}
+	return nil
+}
+
+// contextKey keys the values this package stores in a context.
+type contextKey int
+
+const tenantKey contextKey = iota
+
+// WithTenant returns a copy of the context carrying the tenant.
+func WithTenant(ctx context.Context, tenant *Tenant) context.Context {
+	return context.WithValue(ctx, tenantKey, tenant)
+}
+
+// FromContext returns the tenant of the context, or the default tenant without limits when none was selected.
+func FromContext(ctx context.Context) *Tenant {
+	if tenant, ok := ctx.Value(tenantKey).(*Tenant); ok {
+		return tenant
+	}
+	return &Tenant{Settings: Settings{ID: DefaultID}, limiter: auth.NewLimiter(0, 0, 0)}
+}
+
+// ID returns the ID of the tenant of the context.
+func ID(ctx context.Context) string {
+	return FromContext(ctx).ID
+}
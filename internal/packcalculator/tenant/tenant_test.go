@@ -0,0 +1,130 @@
+package tenant_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/auth"
+	"rpg/internal/packcalculator/models"
+	"rpg/internal/packcalculator/tenant"
+)
+
+// serve sends a request from the principal, if any, with the tenant header through the registry's middleware and
+// returns the response, whose body is the selected tenant.
+func serve(registry *tenant.Registry, principal *auth.Principal, header string) *httptest.ResponseRecorder {
+	handler := registry.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(tenant.ID(r.Context())))
+	}))
+
+	req := httptest.NewRequest("POST", "/calculate", nil)
+	if principal != nil {
+		req = req.WithContext(auth.WithPrincipal(req.Context(), *principal))
+	}
+	if header != "" {
+		req.Header.Set(tenant.Header, header)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+// TestNewRegistry verifies that the default tenant always exists and that invalid tenants are rejected.
+func TestNewRegistry(t *testing.T) {
+	registry, err := tenant.NewRegistry([]tenant.Settings{{ID: "north", MaxOrder: 1000}})
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, []string{"default", "north"}, registry.IDs(), "Incorrect tenants")
+
+	north, ok := registry.Get("north")
+	assert.True(t, ok, "Expected the tenant to exist")
+	assert.Equal(t, 1000, north.MaxOrder, "Incorrect settings")
+
+	for _, settings := range [][]tenant.Settings{
+		{{ID: "North Warehouse"}},
+		{{ID: "../south"}},
+		{{ID: "north"}, {ID: "north"}},
+		{{ID: "north", Clamp: "fast"}},
+	} {
+		_, err := tenant.NewRegistry(settings)
+		assert.ErrorIs(t, err, tenant.ErrInvalidTenants, "Expected an error for %v", settings)
+	}
+}
+
+// TestMiddleware_Selection verifies how the tenant of a request is selected and that callers cannot cross tenants.
+func TestMiddleware_Selection(t *testing.T) {
+	registry, err := tenant.NewRegistry([]tenant.Settings{{ID: "north"}, {ID: "south"}})
+	assert.NoError(t, err, "Unexpected error")
+	bound := &auth.Principal{Subject: "north-warehouse", Tenant: "north", Scopes: []string{auth.ScopeCalculate}}
+	unbound := &auth.Principal{Subject: "reporting", Scopes: []string{auth.ScopeCalculate}}
+	admin := &auth.Principal{Subject: "operator", Scopes: []string{auth.ScopeAdmin}}
+
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		header    string
+		status    int
+		tenant    string
+	}{
+		{"Open API without header", nil, "", http.StatusOK, "default"},
+		{"Open API naming the default tenant", nil, "default", http.StatusOK, "default"},
+		{"Open API naming a tenant", nil, "south", http.StatusForbidden, ""},
+		{"Open API with unknown tenant", nil, "west", http.StatusForbidden, ""},
+		{"Bound caller", bound, "", http.StatusOK, "north"},
+		{"Bound caller naming its tenant", bound, "north", http.StatusOK, "north"},
+		{"Bound caller naming another tenant", bound, "south", http.StatusForbidden, ""},
+		{"Unbound caller", unbound, "", http.StatusOK, "default"},
+		{"Unbound caller naming a tenant", unbound, "south", http.StatusForbidden, ""},
+		{"Admin naming a tenant", admin, "south", http.StatusOK, "south"},
+		{"Admin naming an unknown tenant", admin, "west", http.StatusBadRequest, ""},
+		{"Caller bound to an unknown tenant", &auth.Principal{Subject: "x", Tenant: "west"}, "", http.StatusForbidden, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := serve(registry, test.principal, test.header)
+			assert.Equal(t, test.status, w.Code, "Incorrect status")
+			if test.status == http.StatusOK {
+				assert.Equal(t, test.tenant, w.Body.String(), "Incorrect tenant")
+			}
+		})
+	}
+}
+
+// TestMiddleware_Quota verifies that the quota of a tenant is shared by its callers and leaves other tenants alone.
+func TestMiddleware_Quota(t *testing.T) {
+	registry, err := tenant.NewRegistry([]tenant.Settings{{ID: "north", DailyQuota: 2}, {ID: "south"}})
+	assert.NoError(t, err, "Unexpected error")
+	registry.Clock = func() time.Time { return time.Date(2024, 3, 1, 18, 0, 0, 0, time.UTC) }
+
+	assert.Equal(t, http.StatusOK, serve(registry, &auth.Principal{Subject: "a", Tenant: "north"}, "").Code)
+	assert.Equal(t, http.StatusOK, serve(registry, &auth.Principal{Subject: "b", Tenant: "north"}, "").Code)
+	w := serve(registry, &auth.Principal{Subject: "a", Tenant: "north"}, "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code, "Expected the tenant's quota to be exhausted")
+	assert.Equal(t, "21600", w.Header().Get("Retry-After"), "Expected to retry at midnight")
+
+	assert.Equal(t, http.StatusOK, serve(registry, &auth.Principal{Subject: "c", Tenant: "south"}, "").Code, "Expected other tenants to be unaffected")
+}
+
+// TestTenant_Apply verifies that the solver defaults are filled in and the limits enforced in the order's unit.
+func TestTenant_Apply(t *testing.T) {
+	registry, err := tenant.NewRegistry([]tenant.Settings{{ID: "north", Clamp: "safe", MaxOrder: 500, MaxPackSizes: 2}})
+	assert.NoError(t, err, "Unexpected error")
+	north, _ := registry.Get("north")
+
+	request := models.CalculateRequest{Order: 500, PackSizes: models.NewPackSizes([]int{23, 31})}
+	assert.NoError(t, north.Apply(&request), "Unexpected error")
+	assert.Equal(t, "safe", request.Clamp, "Expected the tenant's clamp")
+
+	request = models.CalculateRequest{Order: 4995, Precision: 1, PackSizes: models.NewPackSizes([]int{23}), Clamp: "off"}
+	assert.NoError(t, north.Apply(&request), "Expected 499.5 to be within the limit")
+	assert.Equal(t, "safe", request.Clamp, "Expected the tenant's clamp to override the request")
+
+	request = models.CalculateRequest{Order: 501, PackSizes: models.NewPackSizes([]int{23})}
+	assert.ErrorIs(t, north.Apply(&request), tenant.ErrLimitExceeded, "Expected the order limit to apply")
+
+	request = models.CalculateRequest{Order: 10, PackSizes: models.NewPackSizes([]int{23, 31, 53})}
+	assert.ErrorIs(t, north.Apply(&request), tenant.ErrLimitExceeded, "Expected the pack size limit to apply")
+}
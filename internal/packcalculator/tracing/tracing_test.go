@@ -0,0 +1,104 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"rpg/internal/packcalculator/handlers"
+	"rpg/internal/packcalculator/tracing"
+)
+
+// restoreTracerProvider restores the global tracer provider once the test finished, so the provider the test
+// installs does not leak into other tests.
+func restoreTracerProvider(t *testing.T) {
+	provider := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(provider) })
+}
+
+// TestSetup verifies that spans are exported to a local OTLP/HTTP collector.
+func TestSetup(t *testing.T) {
+	// Start a collector which records the paths it receives.
+	var mu sync.Mutex
+	var paths []string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+
+	// Setup installs a tracer provider and a propagator; restore both afterwards.
+	restoreTracerProvider(t)
+	propagator := otel.GetTextMapPropagator()
+	t.Cleanup(func() { otel.SetTextMapPropagator(propagator) })
+
+	shutdown, err := tracing.Setup(context.Background(), collector.URL)
+	assert.NoError(t, err, "Unexpected error")
+
+	_, span := otel.Tracer("test").Start(context.Background(), "span")
+	span.End()
+
+	// Shutting down flushes the batched spans.
+	assert.NoError(t, shutdown(context.Background()), "Unexpected error")
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"/v1/traces"}, paths, "Spans were not exported")
+}
+
+// TestMiddleware verifies that a request is traced from the route down to the graph phases.
+func TestMiddleware(t *testing.T) {
+	restoreTracerProvider(t)
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	defer provider.Shutdown(context.Background())
+
+	router := mux.NewRouter()
+	router.HandleFunc("/calculate", handlers.CalculateHandler).Methods("POST")
+	router.Use(tracing.Middleware)
+
+	req := httptest.NewRequest("POST", "/calculate", bytes.NewBufferString(`{"order": 12001, "pack_sizes": [250, 500, 1000, 2000, 5000]}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Index the spans by name and check their parents.
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	parents := map[string]string{
+		"CalculateHandler":              "POST /calculate",
+		"CalculateOrder":                "CalculateHandler",
+		"GraphPackCalculator.Calculate": "CalculateOrder",
+		"generate":                      "GraphPackCalculator.Calculate",
+		"prune":                         "GraphPackCalculator.Calculate",
+		"astar":                         "GraphPackCalculator.Calculate",
+		"path_to_packs":                 "GraphPackCalculator.Calculate",
+	}
+	for name, parent := range parents {
+		if assert.Contains(t, spans, name, "Missing span") && assert.Contains(t, spans, parent, "Missing span") {
+			assert.Equal(t, spans[parent].SpanContext().SpanID(), spans[name].Parent().SpanID(), "Incorrect parent of %s", name)
+		}
+	}
+
+	// The calculation carries the size of the problem.
+	attributes := make(map[string]int64)
+	for _, attribute := range spans["GraphPackCalculator.Calculate"].Attributes() {
+		attributes[string(attribute.Key)] = attribute.Value.AsInt64()
+	}
+	assert.Equal(t, int64(12001), attributes["order.quantity"], "Incorrect order quantity")
+	assert.Equal(t, int64(5), attributes["pack_sizes.count"], "Incorrect pack size count")
+}
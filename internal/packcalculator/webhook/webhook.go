@@ -0,0 +1,320 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"rpg/internal/packcalculator/metrics"
+)
+
+// Headers of a delivery.
+const (
+	HeaderID        = "X-Webhook-ID"        // HeaderID identifies the delivery; retries repeat it.
+	HeaderEvent     = "X-Webhook-Event"     // HeaderEvent names the event, such as "job.finished".
+	HeaderTimestamp = "X-Webhook-Timestamp" // HeaderTimestamp is the Unix time the delivery was signed at.
+	HeaderSignature = "X-Webhook-Signature" // HeaderSignature is "sha256=" and the hexadecimal HMAC of the timestamp and body.
+)
+
+// Errors returned by the dispatcher.
+var (
+	ErrDisabled         = errors.New("webhooks are not configured")
+	ErrInvalidURL       = errors.New("invalid callback URL")
+	ErrForbiddenAddress = errors.New("callback address not allowed")
+)
+
+// Config configures the deliveries of a dispatcher.
+type Config struct {
+	Secrets      map[string]string // Secrets sign the deliveries of each tenant; tenants without one may not register callbacks.
+	MaxAttempts  int               // MaxAttempts is the number of attempts before a delivery is dead-lettered; zero uses 1.
+	Backoff      time.Duration     // Backoff is the delay before the first retry, doubled for every further retry.
+	MaxBackoff   time.Duration     // MaxBackoff bounds the delay between retries; zero does not bound it.
+	Timeout      time.Duration     // Timeout bounds each attempt; zero uses 10 seconds.
+	Concurrency  int               // Concurrency is the number of attempts made at once; zero uses 1.
+	MaxPending   int               // MaxPending is the number of deliveries in progress, beyond which they are dead-lettered; zero does not bound it.
+	AllowedHosts []string          // AllowedHosts are the hosts callbacks may be sent to; none refuses every callback.
+	DeadLetter   io.Writer         // DeadLetter receives a JSON line for every failed delivery, if set.
+	HTTPClient   *http.Client      // HTTPClient sends the deliveries; nil uses a client without redirects which only dials public addresses.
+	Logger       *slog.Logger      // Logger logs failed deliveries; nil uses the default logger.
+}
+
+// Delivery is an event to be posted to a callback URL.
+type Delivery struct {
+	ID     string // ID identifies the delivery, such as the ID of the finished job.
+	Tenant string
+	Event  string
+	URL    string
+	Body   []byte
+}
+
+// DeadLetter is the record of a delivery which failed every attempt.
+type DeadLetter struct {
+	Time     time.Time       `json:"time"`
+	ID       string          `json:"id"`
+	Tenant   string          `json:"tenant"`
+	Event    string          `json:"event"`
+	URL      string          `json:"url"`
+	Attempts int             `json:"attempts"`
+	Status   int             `json:"status,omitempty"` // Status is the HTTP status of the last attempt, if it got a response.
+	Error    string          `json:"error"`
+	Body     json.RawMessage `json:"body"`
+}
+
+// Dispatcher posts signed deliveries to their callback URLs in the background, retrying failed attempts with
+// exponential backoff and recording the deliveries which never succeed.
+type Dispatcher struct {
+	config    Config
+	semaphore chan struct{}
+	ctx       context.Context
+	stop      context.CancelFunc
+	wg        sync.WaitGroup
+
+	mu         sync.Mutex
+	pending    int
+	closed     bool
+	deadLetter sync.Mutex
+}
+
+// New returns a dispatcher delivering with the settings.
+func New(config Config) *Dispatcher {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 1
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = 1
+	}
+	if config.HTTPClient == nil {
+		// Do not follow redirects, which could lead a callback to a host which is not allowed, nor dial internal
+		// addresses, which an allowed host could resolve to.
+		dialer := &net.Dialer{Timeout: config.Timeout, Control: dialPublic}
+		config.HTTPClient = &http.Client{
+			Transport:     &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: config.Timeout, MaxIdleConnsPerHost: config.Concurrency},
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		}
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	return &Dispatcher{config: config, semaphore: make(chan struct{}, config.Concurrency), ctx: ctx, stop: stop}
+}
+
+// Enabled reports whether the dispatcher may send deliveries of the tenant: it has a secret to sign them with and
+// hosts to send them to.
+func (d *Dispatcher) Enabled(tenant string) bool {
+	return d.config.Secrets[tenant] != "" && len(d.config.AllowedHosts) > 0
+}
+
+// ValidateURL checks that deliveries of the tenant can be sent to the callback URL: an absolute http(s) URL of an
+// allowed host.
+func (d *Dispatcher) ValidateURL(tenant, callback string) error {
+	if !d.Enabled(tenant) {
+		return fmt.Errorf("%w for tenant %q", ErrDisabled, tenant)
+	}
+	parsed, err := url.Parse(callback)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%w: %q is not an absolute http or https URL", ErrInvalidURL, callback)
+	}
+	for _, host := range d.config.AllowedHosts {
+		if strings.EqualFold(parsed.Hostname(), host) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: host %q is not allowed", ErrInvalidURL, parsed.Hostname())
+}
+
+// Send delivers in the background. A delivery which cannot be started, because its URL is invalid, too many
+// deliveries are in progress or the dispatcher is closed, is dead-lettered at once.
+func (d *Dispatcher) Send(delivery Delivery) {
+	if err := d.ValidateURL(delivery.Tenant, delivery.URL); err != nil {
+		d.fail(delivery, 0, 0, err)
+		return
+	}
+
+	d.mu.Lock()
+	if d.closed || (d.config.MaxPending > 0 && d.pending >= d.config.MaxPending) {
+		d.mu.Unlock()
+		d.fail(delivery, 0, 0, errors.New("too many deliveries in progress"))
+		return
+	}
+	d.pending++
+	d.wg.Add(1)
+	d.mu.Unlock()
+
+	go func() {
+		defer func() {
+			d.mu.Lock()
+			d.pending--
+			d.mu.Unlock()
+			d.wg.Done()
+		}()
+		d.deliver(delivery)
+	}()
+}
+
+// Close stops retrying, dead-letters the deliveries in progress and waits for them.
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+	d.stop()
+	d.wg.Wait()
+}
+
+// deliver attempts the delivery until it succeeds, fails permanently or runs out of attempts.
+func (d *Dispatcher) deliver(delivery Delivery) {
+	backoff := d.config.Backoff
+	for attempt := 1; ; attempt++ {
+		status, err := d.attempt(delivery)
+		if err == nil {
+			metrics.WebhookDeliveriesTotal.WithLabelValues("delivered").Inc()
+			return
+		}
+		if attempt >= d.config.MaxAttempts || !retryable(status) || errors.Is(err, ErrForbiddenAddress) {
+			d.fail(delivery, attempt, status, err)
+			return
+		}
+
+		// Wait before retrying, giving up when the dispatcher closes.
+		d.config.Logger.Warn("Webhook delivery failed", "id", delivery.ID, "tenant", delivery.Tenant, "attempt", attempt, "retry_in", backoff, "error", err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-d.ctx.Done():
+			timer.Stop()
+			d.fail(delivery, attempt, status, fmt.Errorf("%v; retries stopped at shutdown", err))
+			return
+		}
+		backoff *= 2
+		if d.config.MaxBackoff > 0 && backoff > d.config.MaxBackoff {
+			backoff = d.config.MaxBackoff
+		}
+	}
+}
+
+// attempt posts the delivery once and returns the status of the response, if any, and an error unless it is 2xx.
+func (d *Dispatcher) attempt(delivery Delivery) (int, error) {
+	select {
+	case d.semaphore <- struct{}{}:
+		defer func() { <-d.semaphore }()
+	case <-d.ctx.Done():
+		return 0, d.ctx.Err()
+	}
+
+	ctx, cancel := context.WithTimeout(d.ctx, d.config.Timeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(HeaderID, delivery.ID)
+	request.Header.Set(HeaderEvent, delivery.Event)
+	request.Header.Set(HeaderTimestamp, timestamp)
+	request.Header.Set(HeaderSignature, Sign(d.config.Secrets[delivery.Tenant], timestamp, delivery.Body))
+
+	response, err := d.config.HTTPClient.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 1<<16))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("callback returned %s", response.Status)
+	}
+	return response.StatusCode, nil
+}
+
+// fail logs the delivery and appends its dead letter.
+func (d *Dispatcher) fail(delivery Delivery, attempts, status int, err error) {
+	metrics.WebhookDeliveriesTotal.WithLabelValues("dead_lettered").Inc()
+	d.config.Logger.Error("Webhook delivery dead-lettered", "id", delivery.ID, "tenant", delivery.Tenant, "url", delivery.URL, "attempts", attempts, "error", err)
+	if d.config.DeadLetter == nil {
+		return
+	}
+
+	body := json.RawMessage(delivery.Body)
+	if !json.Valid(body) {
+		body = nil
+	}
+	line, _ := json.Marshal(DeadLetter{
+		Time:     time.Now().UTC(),
+		ID:       delivery.ID,
+		Tenant:   delivery.Tenant,
+		Event:    delivery.Event,
+		URL:      delivery.URL,
+		Attempts: attempts,
+		Status:   status,
+		Error:    err.Error(),
+		Body:     body,
+	})
+	d.deadLetter.Lock()
+	defer d.deadLetter.Unlock()
+	if _, err := d.config.DeadLetter.Write(append(line, '\n')); err != nil {
+		d.config.Logger.Error("Error writing webhook dead letter", "id", delivery.ID, "error", err)
+	}
+}
+
+// dialPublic refuses connections to loopback, link-local, private and unspecified addresses, so that a callback
+// cannot reach the server itself or the internal network, whatever its host resolves to.
+func dialPublic(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrForbiddenAddress, err)
+	}
+	ip := addrPort.Addr().Unmap()
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, ip)
+	}
+	return nil
+}
+
+// retryable reports whether an attempt which got the status, or no response for 0, may succeed when repeated.
+// Client errors other than timeouts and rate limiting will not.
+func retryable(status int) bool {
+	return status < 400 || status >= 500 || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests
+}
+
+// Sign returns the signature header of the body sent at the timestamp: "sha256=" and the hexadecimal HMAC-SHA256
+// of the timestamp, a dot and the body, keyed with the secret.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether the signature header matches the body and timestamp, and whether the timestamp lies within
+// the tolerance of now, which protects receivers from replayed deliveries. A zero tolerance skips the time check.
+func Verify(secret, timestamp, signature string, body []byte, now time.Time, tolerance time.Duration) bool {
+	if tolerance > 0 {
+		seconds, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return false
+		}
+		if age := now.Sub(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+			return false
+		}
+	}
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body)))
+}
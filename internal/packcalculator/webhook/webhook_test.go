@@ -0,0 +1,166 @@
+package webhook_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"rpg/internal/packcalculator/webhook"
+)
+
+// syncBuffer is a buffer which is safe for concurrent writes and reads.
+type syncBuffer struct {
+	mu     sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.buffer.Bytes()...)
+}
+
+// receiver returns a server answering with the statuses in turn, the last one repeated, and counting its requests.
+func receiver(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.True(t, webhook.Verify("secret", r.Header.Get(webhook.HeaderTimestamp), r.Header.Get(webhook.HeaderSignature), body, time.Now(), time.Minute), "Expected a valid signature")
+		assert.Equal(t, "job-1", r.Header.Get(webhook.HeaderID), "Incorrect delivery ID")
+		n := int(requests.Add(1))
+		w.WriteHeader(statuses[min(n, len(statuses))-1])
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// eventually polls the condition until it holds.
+func eventually(t *testing.T, condition func() bool, message string) {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if condition() {
+			return
+		}
+	}
+	t.Fatal(message)
+}
+
+// config returns the settings of a dispatcher signing the deliveries of tenant north and sending them to the server.
+func config(server *httptest.Server, deadLetters io.Writer, maxAttempts int) webhook.Config {
+	return webhook.Config{
+		Secrets:      map[string]string{"north": "secret"},
+		MaxAttempts:  maxAttempts,
+		Backoff:      time.Millisecond,
+		AllowedHosts: []string{"127.0.0.1"},
+		DeadLetter:   deadLetters,
+		HTTPClient:   server.Client(),
+	}
+}
+
+// delivery returns a delivery to the URL.
+func delivery(url string) webhook.Delivery {
+	return webhook.Delivery{ID: "job-1", Tenant: "north", Event: "job.finished", URL: url, Body: []byte(`{"event":"job.finished"}`)}
+}
+
+// TestSignVerify verifies that signatures match their body and timestamp only and stale timestamps are refused.
+func TestSignVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	signature := webhook.Sign("secret", "1700000000", []byte("body"))
+
+	assert.True(t, webhook.Verify("secret", "1700000000", signature, []byte("body"), now, time.Minute), "Expected the signature to match")
+	assert.False(t, webhook.Verify("secret", "1700000000", signature, []byte("other"), now, time.Minute), "Expected another body not to match")
+	assert.False(t, webhook.Verify("other", "1700000000", signature, []byte("body"), now, time.Minute), "Expected another secret not to match")
+	assert.False(t, webhook.Verify("secret", "1700000000", signature, []byte("body"), now.Add(time.Hour), time.Minute), "Expected a stale timestamp to be refused")
+}
+
+// TestDispatcher_Retry verifies that failed attempts are retried until the callback accepts the delivery.
+func TestDispatcher_Retry(t *testing.T) {
+	server, requests := receiver(t, http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusNoContent)
+	var deadLetters syncBuffer
+	dispatcher := webhook.New(config(server, &deadLetters, 5))
+	defer dispatcher.Close()
+
+	dispatcher.Send(delivery(server.URL))
+
+	eventually(t, func() bool { return requests.Load() == 3 }, "Expected three attempts")
+	dispatcher.Close()
+	assert.Empty(t, deadLetters.Bytes(), "Expected no dead letter")
+}
+
+// TestDispatcher_DeadLetter verifies that deliveries are dead-lettered once the attempts run out, or at once when
+// the callback refuses them.
+func TestDispatcher_DeadLetter(t *testing.T) {
+	for name, test := range map[string]struct {
+		status   int
+		attempts int
+	}{
+		"server error": {http.StatusBadGateway, 3},
+		"client error": {http.StatusGone, 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			server, requests := receiver(t, test.status)
+			var deadLetters syncBuffer
+			dispatcher := webhook.New(config(server, &deadLetters, 3))
+			defer dispatcher.Close()
+
+			dispatcher.Send(delivery(server.URL))
+
+			eventually(t, func() bool { return len(deadLetters.Bytes()) > 0 }, "Expected a dead letter")
+			var letter webhook.DeadLetter
+			assert.NoError(t, json.Unmarshal(deadLetters.Bytes(), &letter), "Expected a JSON line")
+			assert.Equal(t, "job-1", letter.ID, "Incorrect ID")
+			assert.Equal(t, "north", letter.Tenant, "Incorrect tenant")
+			assert.Equal(t, test.attempts, letter.Attempts, "Incorrect number of attempts")
+			assert.Equal(t, test.status, letter.Status, "Incorrect status")
+			assert.JSONEq(t, `{"event":"job.finished"}`, string(letter.Body), "Incorrect body")
+			assert.Equal(t, int32(test.attempts), requests.Load(), "Incorrect number of requests")
+		})
+	}
+}
+
+// TestDispatcher_ValidateURL verifies that callbacks need a secret of the tenant, allowed hosts, an http(s) URL and
+// an allowed host.
+func TestDispatcher_ValidateURL(t *testing.T) {
+	assert.ErrorIs(t, webhook.New(webhook.Config{AllowedHosts: []string{"hooks.example.com"}}).ValidateURL("north", "https://hooks.example.com/rpg"), webhook.ErrDisabled, "Expected webhooks without a secret to be disabled")
+	assert.ErrorIs(t, webhook.New(webhook.Config{Secrets: map[string]string{"north": "secret"}}).ValidateURL("north", "https://hooks.example.com/rpg"), webhook.ErrDisabled, "Expected webhooks without allowed hosts to be disabled")
+
+	dispatcher := webhook.New(webhook.Config{Secrets: map[string]string{"north": "secret"}, AllowedHosts: []string{"hooks.example.com"}})
+	assert.NoError(t, dispatcher.ValidateURL("north", "https://hooks.example.com/rpg"), "Expected an allowed host to be accepted")
+	assert.ErrorIs(t, dispatcher.ValidateURL("south", "https://hooks.example.com/rpg"), webhook.ErrDisabled, "Expected a tenant without a secret to be refused")
+	assert.ErrorIs(t, dispatcher.ValidateURL("north", "https://internal.local/rpg"), webhook.ErrInvalidURL, "Expected another host to be refused")
+	assert.ErrorIs(t, dispatcher.ValidateURL("north", "file:///etc/passwd"), webhook.ErrInvalidURL, "Expected another scheme to be refused")
+	assert.ErrorIs(t, dispatcher.ValidateURL("north", "/relative"), webhook.ErrInvalidURL, "Expected a relative URL to be refused")
+}
+
+// TestDispatcher_InternalAddress verifies that the default client refuses to connect to an allowed host which
+// resolves to an internal address, dead-lettering the delivery without retrying it.
+func TestDispatcher_InternalAddress(t *testing.T) {
+	server, requests := receiver(t, http.StatusNoContent)
+	var deadLetters syncBuffer
+	settings := config(server, &deadLetters, 3)
+	settings.HTTPClient = nil
+	dispatcher := webhook.New(settings)
+	defer dispatcher.Close()
+
+	dispatcher.Send(delivery(server.URL))
+
+	eventually(t, func() bool { return len(deadLetters.Bytes()) > 0 }, "Expected a dead letter")
+	var letter webhook.DeadLetter
+	assert.NoError(t, json.Unmarshal(deadLetters.Bytes(), &letter), "Expected a JSON line")
+	assert.Equal(t, 1, letter.Attempts, "Expected no retry")
+	assert.Contains(t, letter.Error, webhook.ErrForbiddenAddress.Error(), "Incorrect error")
+	assert.Equal(t, int32(0), requests.Load(), "Expected no request")
+}
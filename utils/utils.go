@@ -1,5 +1,7 @@
 package utils
 
+import "math"
+
 // Sum calculates the sum of integers in an array.
 func Sum(numbers []int) int {
 	total := 0
@@ -8,3 +10,36 @@ func Sum(numbers []int) int {
 	}
 	return total
 }
+
+// CheckedSum calculates the sum of integers in an array, reporting false if the sum overflows.
+func CheckedSum(numbers []int) (int, bool) {
+	total := 0
+	for _, num := range numbers {
+		next := total + num
+		if (num > 0 && next < total) || (num < 0 && next > total) {
+			return 0, false
+		}
+		total = next
+	}
+	return total, true
+}
+
+// CheckedMultiply multiplies two integers, reporting false if the product overflows.
+func CheckedMultiply(a, b int) (int, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	product := a * b
+	if product/b != a || (a == -1 && b == math.MinInt) || (b == -1 && a == math.MinInt) {
+		return 0, false
+	}
+	return product, true
+}
+
+// GCD returns the greatest common divisor of two non-negative integers; GCD(0, n) is n.
+func GCD(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
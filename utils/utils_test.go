@@ -1,6 +1,7 @@
 package utils_test
 
 import (
+	"math"
 	"testing"
 
 	"rpg/utils"
@@ -54,3 +55,107 @@ func TestSum(t *testing.T) {
 		})
 	}
 }
+
+// TestCheckedSum tests the CheckedSum function in the utils package.
+func TestCheckedSum(t *testing.T) {
+	// Test cases with different scenarios.
+	testCases := []struct {
+		name     string
+		numbers  []int
+		expected int
+		ok       bool
+	}{
+		{
+			name:     "Positive numbers",
+			numbers:  []int{1, 2, 3, 4, 5},
+			expected: 15,
+			ok:       true,
+		},
+		{
+			name:     "Mix of positive and negative numbers",
+			numbers:  []int{-1, 2, -3, 4, -5},
+			expected: -3,
+			ok:       true,
+		},
+		{
+			name:    "Positive overflow",
+			numbers: []int{math.MaxInt, 1},
+			ok:      false,
+		},
+		{
+			name:    "Negative overflow",
+			numbers: []int{math.MinInt, -1},
+			ok:      false,
+		},
+	}
+
+	// Iterate through each test case and run the test.
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Call the CheckedSum function with the current set of numbers.
+			result, ok := utils.CheckedSum(tc.numbers)
+
+			// Check if the result matches the expected value.
+			if ok != tc.ok || result != tc.expected {
+				t.Errorf("Expected %d (%t), but got %d (%t) for numbers %v", tc.expected, tc.ok, result, ok, tc.numbers)
+			}
+		})
+	}
+}
+
+// TestCheckedMultiply tests the CheckedMultiply function in the utils package.
+func TestCheckedMultiply(t *testing.T) {
+	// Test cases with different scenarios.
+	testCases := []struct {
+		name     string
+		a, b     int
+		expected int
+		ok       bool
+	}{
+		{name: "Small numbers", a: 6, b: 7, expected: 42, ok: true},
+		{name: "Zero", a: 0, b: math.MaxInt, expected: 0, ok: true},
+		{name: "Negative numbers", a: -6, b: 7, expected: -42, ok: true},
+		{name: "Overflow", a: math.MaxInt / 2, b: 3, ok: false},
+		{name: "Minimum times minus one", a: math.MinInt, b: -1, ok: false},
+	}
+
+	// Iterate through each test case and run the test.
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Call the CheckedMultiply function with the current pair of numbers.
+			result, ok := utils.CheckedMultiply(tc.a, tc.b)
+
+			// Check if the result matches the expected value.
+			if ok != tc.ok || result != tc.expected {
+				t.Errorf("Expected %d (%t), but got %d (%t) for %d * %d", tc.expected, tc.ok, result, ok, tc.a, tc.b)
+			}
+		})
+	}
+}
+
+// TestGCD tests the GCD function in the utils package.
+func TestGCD(t *testing.T) {
+	// Test cases with different scenarios.
+	testCases := []struct {
+		name     string
+		a, b     int
+		expected int
+	}{
+		{name: "Common divisor", a: 500, b: 250, expected: 250},
+		{name: "Coprime numbers", a: 23, b: 31, expected: 1},
+		{name: "Zero", a: 0, b: 53, expected: 53},
+	}
+
+	// Iterate through each test case and run the test.
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Call the GCD function with the current pair of numbers.
+			result := utils.GCD(tc.a, tc.b)
+
+			// Check if the result matches the expected value.
+			if result != tc.expected {
+				t.Errorf("Expected %d, but got %d for %d and %d", tc.expected, result, tc.a, tc.b)
+			}
+		})
+	}
+}